@@ -0,0 +1,38 @@
+package vips
+
+import (
+	"math"
+)
+
+// AffineMatrix is the 2x2 matrix and offset of an affine transform:
+// output pixel (x', y') comes from input (x, y) with
+//
+//	x' = A*x + B*y + Dx
+//	y' = C*x + D*y + Dy
+//
+// The zero value leaves the image alone.
+type AffineMatrix struct {
+	A, B, C, D float64
+	Dx, Dy     float64
+}
+
+// Shear returns the matrix slanting x by kx times y and y by ky times x.
+func Shear(kx, ky float64) AffineMatrix {
+	return AffineMatrix{A: 1, B: kx, C: ky, D: 1}
+}
+
+// ScaleRotate returns the matrix scaling by scale and rotating clockwise
+// by degrees, done together in a single pass.
+func ScaleRotate(scale, degrees float64) AffineMatrix {
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	return AffineMatrix{A: scale * cos, B: -scale * sin, C: scale * sin, D: scale * cos}
+}
+
+// Affine transforms buf by the matrix a, b, c, d and offset dx, dy,
+// keeping its format where it can be saved. See AffineMatrix.
+func Affine(buf []byte, a, b, c, d, dx, dy float64) ([]byte, error) {
+	return Resize(buf, Options{
+		Affine:   AffineMatrix{A: a, B: b, C: c, D: d, Dx: dx, Dy: dy},
+		Savetype: keptType(buf),
+	})
+}
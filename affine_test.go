@@ -0,0 +1,42 @@
+package vips
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"testing"
+)
+
+func TestScaleRotate(t *testing.T) {
+	m := ScaleRotate(2, 90)
+	want := AffineMatrix{A: 0, B: -2, C: 2, D: 0}
+	if math.Abs(m.A-want.A) > 1e-9 || m.B != want.B || m.C != want.C || math.Abs(m.D-want.D) > 1e-9 {
+		t.Errorf("ScaleRotate(2, 90) = %+v, want %+v", m, want)
+	}
+}
+
+func TestAffine(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{0, 0xff, 0, 0xff}}, image.ZP, draw.Src)
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	s := Shear(1, 0)
+	out, err := Affine(buf.Bytes(), s.A, s.B, s.C, s.D, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outImg, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := outImg.Bounds(); b.Dx() < 59 || b.Dx() > 61 || b.Dy() != 20 {
+		t.Errorf("Affine(shear 1) of 40x20 => %dx%d, want 60x20", b.Dx(), b.Dy())
+	}
+}
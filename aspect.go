@@ -0,0 +1,67 @@
+package vips
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// parseAspectRatio parses "16:9", "16/9" or "1.78" into width / height.
+func parseAspectRatio(s string) (float64, error) {
+	var ratio float64
+	var err error
+
+	if i := strings.IndexAny(s, ":/"); i >= 0 {
+		var w, h float64
+		w, err = strconv.ParseFloat(strings.TrimSpace(s[:i]), 64)
+		if err == nil {
+			h, err = strconv.ParseFloat(strings.TrimSpace(s[i+1:]), 64)
+		}
+		if err == nil && h != 0 {
+			ratio = w / h
+		}
+	} else {
+		ratio, err = strconv.ParseFloat(strings.TrimSpace(s), 64)
+	}
+
+	if err != nil || !(ratio > 0) || math.IsInf(ratio, 0) {
+		return 0, fmt.Errorf("vips: invalid aspect ratio %q", s)
+	}
+	return ratio, nil
+}
+
+// applyAspectRatio turns o.AspectRatio into a cropping Width and Height
+// for a source of inWidth x inHeight.
+func applyAspectRatio(o Options, inWidth, inHeight int) (Options, error) {
+	ratio, err := parseAspectRatio(o.AspectRatio)
+	if err != nil {
+		return o, err
+	}
+
+	switch {
+	case o.Width > 0 && o.Height > 0:
+		return o, nil
+	case o.Width > 0:
+		o.Height = int(math.Floor(float64(o.Width)/ratio + 0.5))
+	case o.Height > 0:
+		o.Width = int(math.Floor(float64(o.Height)*ratio + 0.5))
+	case float64(inWidth)/float64(inHeight) > ratio:
+		o.Height = inHeight
+		o.Width = int(math.Floor(float64(inHeight)*ratio + 0.5))
+	default:
+		o.Width = inWidth
+		o.Height = int(math.Floor(float64(inWidth)/ratio + 0.5))
+	}
+
+	if o.Width < 1 {
+		o.Width = 1
+	}
+	if o.Height < 1 {
+		o.Height = 1
+	}
+
+	o.Crop = true
+	debug("aspect ratio %s: %dx%d", o.AspectRatio, o.Width, o.Height)
+	return o, nil
+}
@@ -0,0 +1,53 @@
+package vips
+
+import (
+	"testing"
+)
+
+func TestParseAspectRatio(t *testing.T) {
+	var testCases = []struct {
+		value string
+		ratio float64
+		ok    bool
+	}{
+		{"16:9", 16.0 / 9, true},
+		{"4/3", 4.0 / 3, true},
+		{" 1 : 1 ", 1, true},
+		{"1.5", 1.5, true},
+		{"", 0, false},
+		{"16:0", 0, false},
+		{"-1:2", 0, false},
+		{"wide", 0, false},
+	}
+
+	for index, tc := range testCases {
+		ratio, err := parseAspectRatio(tc.value)
+		if (err == nil) != tc.ok || ratio != tc.ratio {
+			t.Errorf("%d. parseAspectRatio(%q) => %v, %v", index, tc.value, ratio, err)
+		}
+	}
+}
+
+func TestApplyAspectRatio(t *testing.T) {
+	var testCases = []struct {
+		width, height       int
+		inWidth, inHeight   int
+		outWidth, outHeight int
+	}{
+		{0, 0, 1600, 1600, 1600, 900},
+		{0, 0, 1000, 200, 356, 200},
+		{800, 0, 1600, 1600, 800, 450},
+		{0, 90, 1600, 1600, 160, 90},
+		{300, 300, 1600, 1600, 300, 300},
+	}
+
+	for index, tc := range testCases {
+		o, err := applyAspectRatio(Options{Width: tc.width, Height: tc.height, AspectRatio: "16:9"}, tc.inWidth, tc.inHeight)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o.Width != tc.outWidth || o.Height != tc.outHeight {
+			t.Errorf("%d. applyAspectRatio() => %dx%d, want %dx%d", index, o.Width, o.Height, tc.outWidth, tc.outHeight)
+		}
+	}
+}
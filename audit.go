@@ -0,0 +1,81 @@
+package vips
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// AuditRecord describes one processed image.
+type AuditRecord struct {
+	// Operation is the name of the public function, such as "resize".
+	Operation  string
+	InputHash  string
+	InputSize  int
+	OutputHash string
+	OutputSize int
+	Options    Options
+	Duration   time.Duration
+	// MemDelta is the change in libvips tracked memory over the call. It
+	// is process wide, so concurrent calls blur it.
+	MemDelta int64
+	Err      error
+}
+
+// AuditSink receives an AuditRecord for every image processed from a
+// buffer, for billing and abuse investigations. It is called
+// synchronously, so slow sinks should queue records.
+type AuditSink func(AuditRecord)
+
+var (
+	auditMu   sync.RWMutex
+	auditSink AuditSink
+)
+
+// SetAuditSink installs sink, or removes the current one when nil.
+func SetAuditSink(sink AuditSink) {
+	auditMu.Lock()
+	auditSink = sink
+	auditMu.Unlock()
+}
+
+// audit starts a record of operation on in, returning the func to defer
+// with the results of the call. Nothing is hashed while no sink is set.
+//
+//	defer audit("resize", buf, o)(&out, &err)
+func audit(operation string, in []byte, o Options) func(*[]byte, *error) {
+	auditMu.RLock()
+	sink := auditSink
+	auditMu.RUnlock()
+
+	if sink == nil {
+		return func(*[]byte, *error) {}
+	}
+
+	start := time.Now()
+	mem := MemoryStats().Mem
+
+	return func(out *[]byte, err *error) {
+		sink(AuditRecord{
+			Operation:  operation,
+			InputHash:  contentHash(in),
+			InputSize:  len(in),
+			OutputHash: contentHash(*out),
+			OutputSize: len(*out),
+			Options:    o,
+			Duration:   time.Since(start),
+			MemDelta:   MemoryStats().Mem - mem,
+			Err:        *err,
+		})
+	}
+}
+
+// contentHash is the hex SHA-256 of buf, or "" for no content.
+func contentHash(buf []byte) string {
+	if len(buf) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:])
+}
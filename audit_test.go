@@ -0,0 +1,31 @@
+package vips
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestAuditSink(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var records []AuditRecord
+	SetAuditSink(func(r AuditRecord) { records = append(records, r) })
+	defer SetAuditSink(nil)
+
+	out, err := Resize(buf, Options{Width: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 {
+		t.Fatalf("SetAuditSink() received %d records, want 1", len(records))
+	}
+	r := records[0]
+	if r.Operation != "resize" || r.InputHash != contentHash(buf) || r.OutputHash != contentHash(out) ||
+		r.InputSize != len(buf) || r.OutputSize != len(out) || r.Options.Width != 100 || r.Duration <= 0 || r.Err != nil {
+		t.Errorf("AuditRecord => %+v", r)
+	}
+}
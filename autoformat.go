@@ -0,0 +1,105 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+// autoFormats are the formats AUTO picks from by default, which every
+// client takes.
+var autoFormats = []ImageType{JPEG, PNG}
+
+// graphicSample is the side of the grid of pixels isGraphic looks at.
+const graphicSample = 64
+
+// autoSave resolves Savetype AUTO of o for image, which it replaces by a
+// copy in memory when it had to look at the pixels, or releases on errors.
+func autoSave(image *C.struct__VipsImage, o Options) (*C.struct__VipsImage, Options, error) {
+	allowed := o.AutoFormats
+	if len(allowed) == 0 {
+		allowed = autoFormats
+	}
+	alpha := C.vips_image_hasalpha(image) != 0
+
+	graphic := false
+	if pages, ok := vipsImageInt(image, "n-pages"); ok && pages > 1 {
+		// frames of animations, mostly drawn, are not looked at
+		graphic = true
+	} else if len(allowed) > 1 {
+		// the pixels are read twice, once here and once saving
+		var err error
+		if image, err = decodeToMemory(image); err != nil {
+			return nil, o, err
+		}
+		if graphic, err = isGraphic(image); err != nil {
+			C.g_object_unref(C.gpointer(image))
+			return nil, o, err
+		}
+	}
+
+	switch {
+	case graphic:
+		o.Savetype = pickFormat(allowed, alpha, PNG, WEBP)
+	case alpha:
+		o.Savetype = pickFormat(allowed, alpha, WEBP, PNG)
+	default:
+		o.Savetype = pickFormat(allowed, alpha, WEBP, JPEG)
+	}
+	// Rotate, Flip and Flop were applied to the pixels, not waiting to
+	// know the format
+	o.OrientByTag = false
+	debug("auto format %v, graphic %v, alpha %v", o.Savetype, graphic, alpha)
+
+	return image, o, nil
+}
+
+// pickFormat is the first of preferred in allowed, or else the first of
+// allowed keeping alpha when the image has it, or else the first.
+func pickFormat(allowed []ImageType, alpha bool, preferred ...ImageType) ImageType {
+	for _, t := range preferred {
+		for _, a := range allowed {
+			if a == t {
+				return t
+			}
+		}
+	}
+	for _, t := range allowed {
+		if f, ok := formatOf(t); ok && (!alpha || f.Alpha) {
+			return t
+		}
+	}
+	return allowed[0]
+}
+
+// isGraphic reports whether image, which it keeps, looks drawn rather than
+// photographed: few distinct colours over a grid of its pixels.
+func isGraphic(image *C.struct__VipsImage) (bool, error) {
+	pixels, _, _, err := vipsSamplePixels(image, graphicSample)
+	if err != nil {
+		return false, err
+	}
+	return fewColours(pixels, int(image.Bands)), nil
+}
+
+// fewColours reports whether pixels of bands bytes each have no more than
+// 256 distinct colours, or one for every 4 pixels of small samples, as
+// drawings and screenshots do and photos don't.
+func fewColours(pixels []byte, bands int) bool {
+	limit := 256
+	if n := len(pixels) / bands; n/4 < limit {
+		limit = n / 4
+	}
+
+	seen := map[uint32]bool{}
+	for i := 0; i+bands <= len(pixels); i += bands {
+		var c uint32
+		for b := 0; b < bands && b < 4; b++ {
+			c = c<<8 | uint32(pixels[i+b])
+		}
+		seen[c] = true
+		if len(seen) > limit {
+			return false
+		}
+	}
+	return true
+}
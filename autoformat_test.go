@@ -0,0 +1,97 @@
+package vips
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"testing"
+)
+
+func TestFewColours(t *testing.T) {
+	flat := bytes.Repeat([]byte{1, 2, 3, 4, 5, 6}, 1000)
+	if !fewColours(flat, 3) {
+		t.Error("fewColours() of two colours = false")
+	}
+	noise := make([]byte, 3*4096)
+	for i := range noise {
+		noise[i] = byte(i * 7919 >> 3)
+	}
+	if fewColours(noise, 3) {
+		t.Error("fewColours() of noise = true")
+	}
+}
+
+func TestAutoSavetype(t *testing.T) {
+	photo, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alpha, err := ioutil.ReadFile("testdata/6.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 200, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 200; x++ {
+			img.Set(x, y, color.RGBA{uint8(x / 100 * 200), 40, uint8(y / 100 * 200), 0xff})
+		}
+	}
+	graphic := new(bytes.Buffer)
+	if err := png.Encode(graphic, img); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		buf     []byte
+		formats []ImageType
+		want    ImageType
+	}{
+		{"photo", photo, nil, JPEG},
+		{"photo", photo, []ImageType{JPEG, WEBP}, WEBP},
+		{"graphic", graphic.Bytes(), nil, PNG},
+		{"graphic", graphic.Bytes(), []ImageType{JPEG, WEBP}, WEBP},
+		{"alpha", alpha, nil, PNG},
+		{"alpha", alpha, []ImageType{JPEG}, JPEG},
+	}
+	for _, c := range cases {
+		r, err := ResizeWithInfo(c.buf, Options{Width: 100, Savetype: AUTO, AutoFormats: c.formats})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Format != c.want {
+			t.Errorf("AUTO from %v for a %s => %v, want %v", c.formats, c.name, r.Format, c.want)
+		}
+		if m, err := Size(r.Buf); err != nil || m.Format != c.want {
+			t.Errorf("AUTO for a %s saved %v, %v, want %v", c.name, m.Format, err, c.want)
+		}
+	}
+
+	if _, err := Resize(photo, Options{Savetype: AUTO, AutoFormats: []ImageType{BMP}}); !errors.Is(err, ErrUnsupportedSaveType) {
+		t.Errorf("AUTO from BMP = %v, want %v", err, ErrUnsupportedSaveType)
+	}
+}
+
+func TestAutoSavetypeOrientByTag(t *testing.T) {
+	photo, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// AUTO may pick JPEG only after the pixels were turned, so no tag
+	r, err := ResizeWithInfo(photo, Options{Width: 100, Rotate: D90, OrientByTag: true, Savetype: AUTO})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Size(r.Buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Format != JPEG || m.Orientation > 1 || m.RawWidth != r.Width || m.RawHeight != r.Height || r.Width > r.Height {
+		t.Errorf("AUTO by tag => %v %dx%d stored %dx%d with orientation %d, want a portrait JPEG without one",
+			r.Format, r.Width, r.Height, m.RawWidth, m.RawHeight, m.Orientation)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !vips_noautoinit
+// +build !vips_noautoinit
+
+package vips
+
+// autoInitialize starts libvips on import; build with the vips_noautoinit
+// tag to leave that to TryInitialize or Initialize.
+const autoInitialize = true
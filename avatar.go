@@ -0,0 +1,124 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image/color"
+	"strings"
+	"unicode"
+)
+
+// AvatarOptions describes the avatar produced by Avatar.
+type AvatarOptions struct {
+	// Size is the width and height of the avatar, 128 by default.
+	Size int
+	// Name is rendered as initials when there is no uploaded image and
+	// picks the background colour behind them.
+	Name string
+	// Font is the Pango font description used for the initials,
+	// "sans bold" by default. The point size is derived from Size.
+	Font         string
+	Interpolator Interpolator
+}
+
+// avatarColors is the palette initials backgrounds are picked from.
+var avatarColors = []color.RGBA{
+	{0xe5, 0x39, 0x35, 0xff},
+	{0xd8, 0x1b, 0x60, 0xff},
+	{0x8e, 0x24, 0xaa, 0xff},
+	{0x5e, 0x35, 0xb1, 0xff},
+	{0x39, 0x49, 0xab, 0xff},
+	{0x1e, 0x88, 0xe5, 0xff},
+	{0x00, 0x89, 0x7b, 0xff},
+	{0x43, 0xa0, 0x47, 0xff},
+	{0xf4, 0x51, 0x1e, 0xff},
+	{0x6d, 0x4c, 0x41, 0xff},
+	{0x54, 0x6e, 0x7a, 0xff},
+}
+
+// Avatar crops buf to a circle of o.Size pixels and returns it as a PNG
+// with a transparent background. When buf is empty the initials of o.Name
+// are rendered instead, on a background colour derived from the name so
+// the same user always gets the same colour.
+func Avatar(buf []byte, o AvatarOptions) (out []byte, err error) {
+	debug("%#+v", o)
+	defer audit("avatar", buf, Options{Width: o.Size, Height: o.Size, Crop: true, Savetype: PNG})(&out, &err)
+
+	if o.Size <= 0 {
+		o.Size = 128
+	}
+	if o.Font == "" {
+		o.Font = "sans bold"
+	}
+
+	release, err := acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	var image *C.struct__VipsImage
+
+	if len(buf) == 0 {
+		font := fmt.Sprintf("%s %d", o.Font, o.Size*2/5)
+		image, err = vipsInitialsAvatar(avatarInitials(o.Name), font, o.Size, avatarColor(o.Name))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		image, _, err = resizeImage(buf, Options{
+			Width:        o.Size,
+			Height:       o.Size,
+			Crop:         true,
+			Enlarge:      true,
+			Interpolator: o.Interpolator,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		image, err = vipsCircleMask(image)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return saveImage(image, Options{Savetype: PNG})
+}
+
+// avatarInitials returns the upper-cased first letters of the first and
+// last words of name, or "?" when name has no words.
+func avatarInitials(name string) string {
+	words := strings.Fields(name)
+	if len(words) == 0 {
+		return "?"
+	}
+
+	initials := []rune{[]rune(words[0])[0]}
+	if len(words) > 1 {
+		initials = append(initials, []rune(words[len(words)-1])[0])
+	}
+
+	for i, r := range initials {
+		initials[i] = unicode.ToUpper(r)
+	}
+	return string(initials)
+}
+
+// avatarColor deterministically maps name onto avatarColors.
+func avatarColor(name string) color.RGBA {
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(strings.TrimSpace(name))))
+	return avatarColors[h.Sum32()%uint32(len(avatarColors))]
+}
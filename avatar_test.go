@@ -0,0 +1,61 @@
+package vips
+
+import (
+	"bytes"
+	"image/png"
+	"io/ioutil"
+	"testing"
+)
+
+func TestAvatarInitials(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		initials string
+	}{
+		{"", "?"},
+		{"   ", "?"},
+		{"ada", "A"},
+		{"Ada Lovelace", "AL"},
+		{"ada king lovelace", "AL"},
+		{"élodie durand", "ÉD"},
+	}
+
+	for index, tc := range testCases {
+		if initials := avatarInitials(tc.name); initials != tc.initials {
+			t.Errorf("%d. avatarInitials(%q) => %q, want %q", index, tc.name, initials, tc.initials)
+		}
+	}
+}
+
+func TestAvatarColor(t *testing.T) {
+	if avatarColor("Ada Lovelace") != avatarColor(" ada lovelace ") {
+		t.Errorf("avatarColor is not stable across case and spacing")
+	}
+}
+
+func TestAvatar(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for index, in := range [][]byte{buf, nil} {
+		out, err := Avatar(in, AvatarOptions{Size: 64, Name: "Ada Lovelace"})
+		if err != nil {
+			t.Fatalf("%d. Avatar error: %#v", index, err)
+		}
+
+		img, err := png.Decode(bytes.NewReader(out))
+		if err != nil {
+			t.Fatalf("%d. png.Decode error: %#v", index, err)
+		}
+
+		if img.Bounds().Dx() != 64 || img.Bounds().Dy() != 64 {
+			t.Errorf("%d. Avatar => %v, want 64x64", index, img.Bounds())
+		}
+
+		if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+			t.Errorf("%d. Avatar corner alpha => %d, want 0", index, a)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Job is one transform of a batch: Buf resized as with ResizeWithInfo, or,
+// when InPath is set, the file InPath written to OutPath as with
+// ResizeFile.
+type Job struct {
+	Buf             []byte
+	InPath, OutPath string
+	Options         Options
+}
+
+// JobResult is the outcome of the job Index, counting from 0 in the order
+// the jobs were received. Result is zero for file jobs and failures.
+type JobResult struct {
+	Index  int
+	Result Result
+	Err    error
+}
+
+// BatchError lists the jobs of Batch that failed.
+type BatchError struct {
+	// Failed maps the index of every failed job to its error.
+	Failed map[int]error
+}
+
+func (e *BatchError) Error() string {
+	indexes := make([]int, 0, len(e.Failed))
+	for i := range e.Failed {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return fmt.Sprintf("vips: %d jobs failed, the first, job %d: %v", len(indexes), indexes[0], e.Failed[indexes[0]])
+}
+
+// Batch runs jobs on workers goroutines, runtime.NumCPU() when workers is
+// 0 or less, and returns their results in the order of jobs. Every job is
+// run; when any fails the error is a *BatchError listing them.
+func Batch(jobs []Job, workers int) ([]Result, error) {
+	in := make(chan Job)
+	go func() {
+		for _, j := range jobs {
+			in <- j
+		}
+		close(in)
+	}()
+
+	results := make([]Result, len(jobs))
+	failed := map[int]error{}
+	for r := range BatchStream(context.Background(), in, workers) {
+		results[r.Index] = r.Result
+		if r.Err != nil {
+			failed[r.Index] = r.Err
+		}
+	}
+
+	if len(failed) > 0 {
+		return results, &BatchError{failed}
+	}
+	return results, nil
+}
+
+// BatchStream runs the jobs received on jobs on workers goroutines,
+// runtime.NumCPU() when workers is 0 or less, sending a JobResult for each
+// as it finishes. The results channel is closed once jobs is closed and
+// drained, or ctx is done and the jobs running have finished; after ctx is
+// done no more jobs are taken, so senders should stop too. Read the
+// results until the channel is closed, or the workers block.
+//
+// Each worker keeps to one OS thread and frees the libvips buffers of that
+// thread when it exits, which pools of plain goroutines calling Resize
+// leave to the thread they happen to run on.
+func BatchStream(ctx context.Context, jobs <-chan Job, workers int) <-chan JobResult {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	type indexed struct {
+		index int
+		job   Job
+	}
+	queue := make(chan indexed)
+	go func() {
+		defer close(queue)
+		for i := 0; ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case j, ok := <-jobs:
+				if !ok {
+					return
+				}
+				select {
+				case queue <- indexed{i, j}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	results := make(chan JobResult)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			runtime.LockOSThread()
+			defer runtime.UnlockOSThread()
+			defer C.vips_thread_shutdown()
+
+			for j := range queue {
+				r := JobResult{Index: j.index}
+				if j.job.InPath != "" {
+					r.Err = ResizeFile(j.job.InPath, j.job.OutPath, j.job.Options)
+				} else {
+					r.Result, r.Err = ResizeWithInfo(j.job.Buf, j.job.Options)
+				}
+				results <- r
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
@@ -0,0 +1,64 @@
+package vips
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "vips-batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out.png")
+	jobs := []Job{
+		{Buf: buf, Options: Options{Width: 100}},
+		{Buf: []byte("not an image"), Options: Options{Width: 100}},
+		{Buf: buf, Options: Options{Width: 50}},
+		{InPath: "testdata/1.jpg", OutPath: out, Options: Options{Width: 20}},
+	}
+	results, err := Batch(jobs, 2)
+
+	var berr *BatchError
+	if !errors.As(err, &berr) || len(berr.Failed) != 1 || berr.Failed[1] == nil {
+		t.Fatalf("Batch() error = %v, want job 1 failed", err)
+	}
+	if len(results) != len(jobs) || results[0].Width != 100 || results[2].Width != 50 {
+		t.Errorf("Batch() results out of order: %+v", results)
+	}
+	written, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := Size(written); err != nil || m.Width != 20 {
+		t.Errorf("Batch() file job => %+v, %v", m, err)
+	}
+
+	if _, err := Batch(jobs[:1], 0); err != nil {
+		t.Errorf("Batch() = %v", err)
+	}
+}
+
+func TestBatchStreamCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	jobs := make(chan Job)
+	n := 0
+	for range BatchStream(ctx, jobs, 2) {
+		n++
+	}
+	if n != 0 {
+		t.Errorf("BatchStream() ran %d jobs after cancel", n)
+	}
+}
@@ -0,0 +1,151 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// blurHashSize is the side of the thumbnail BlurHash encodes; the hash
+// keeps only the lowest frequencies, so more pixels change nothing.
+const blurHashSize = 32
+
+const base83 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// BlurHash encodes buf as a BlurHash, a short string clients decode into a
+// blurred placeholder while the image loads, with xComp x yComp
+// components of 1 to 9 each; 4 x 3 suits most landscape images. Alpha is
+// ignored.
+func BlurHash(buf []byte, xComp, yComp int) (string, error) {
+	if xComp < 1 || xComp > 9 || yComp < 1 || yComp > 9 {
+		return "", fmt.Errorf("%w: %dx%d BlurHash components", ErrInvalidOption, xComp, yComp)
+	}
+
+	release, err := acquire()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	image, _, err := resizeImage(buf, Options{Width: blurHashSize, Height: blurHashSize})
+	if err != nil {
+		return "", err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	pixels, err := vipsRGBA(image)
+	if err != nil {
+		return "", err
+	}
+	return blurHashOf(pixels, int(image.Xsize), int(image.Ysize), xComp, yComp), nil
+}
+
+// blurHashOf encodes width x height RGBA pixels.
+func blurHashOf(pixels []byte, width, height, xComp, yComp int) string {
+	var linear [256]float64
+	for v := range linear {
+		linear[v] = sRGBToLinear(v)
+	}
+
+	// the cosines of each component along each axis
+	cosines := func(n, size int) [][]float64 {
+		c := make([][]float64, n)
+		for i := range c {
+			c[i] = make([]float64, size)
+			for x := range c[i] {
+				c[i][x] = math.Cos(math.Pi * float64(i) * float64(x) / float64(size))
+			}
+		}
+		return c
+	}
+	cx, cy := cosines(xComp, width), cosines(yComp, height)
+
+	factors := make([][3]float64, 0, xComp*yComp)
+	for j := 0; j < yComp; j++ {
+		for i := 0; i < xComp; i++ {
+			var f [3]float64
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					basis := cx[i][x] * cy[j][y]
+					p := pixels[(y*width+x)*4:]
+					f[0] += basis * linear[p[0]]
+					f[1] += basis * linear[p[1]]
+					f[2] += basis * linear[p[2]]
+				}
+			}
+			scale := 1 / float64(width*height)
+			if i != 0 || j != 0 {
+				scale *= 2
+			}
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var b strings.Builder
+	encode83(&b, (xComp-1)+(yComp-1)*9, 1)
+
+	dc, ac := factors[0], factors[1:]
+	maximum := 1.0
+	if len(ac) > 0 {
+		actual := 0.0
+		for _, f := range ac {
+			actual = math.Max(actual, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantised := int(math.Max(0, math.Min(82, math.Floor(actual*166-0.5))))
+		maximum = float64(quantised+1) / 166
+		encode83(&b, quantised, 1)
+	} else {
+		encode83(&b, 0, 1)
+	}
+
+	encode83(&b, linearToSRGB(dc[0])<<16|linearToSRGB(dc[1])<<8|linearToSRGB(dc[2]), 4)
+	for _, f := range ac {
+		quantise := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maximum, 0.5)*9+9.5))))
+		}
+		encode83(&b, quantise(f[0])*19*19+quantise(f[1])*19+quantise(f[2]), 2)
+	}
+	return b.String()
+}
+
+// encode83 writes value as length base 83 digits.
+func encode83(b *strings.Builder, value, length int) {
+	divisor := 1
+	for i := 1; i < length; i++ {
+		divisor *= 83
+	}
+	for ; divisor > 0; divisor /= 83 {
+		b.WriteByte(base83[value/divisor%83])
+	}
+}
+
+func sRGBToLinear(v int) float64 {
+	f := float64(v) / 255
+	if f <= 0.04045 {
+		return f / 12.92
+	}
+	return math.Pow((f+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(v float64) int {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
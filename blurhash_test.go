@@ -0,0 +1,64 @@
+package vips
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestBlurHashOf(t *testing.T) {
+	white := bytes.Repeat([]byte{0xff, 0xff, 0xff, 0xff}, 8*6)
+	var testCases = []struct {
+		xComp, yComp int
+		hash         string
+	}{
+		{1, 1, "00TSUA"},
+		// sampled from the left edge, the cosines don't cancel out
+		{4, 3, "LsTSUA_3fQ_3~qt7fQt7fQfQfQfQ"},
+	}
+
+	for index, tc := range testCases {
+		if hash := blurHashOf(white, 8, 6, tc.xComp, tc.yComp); hash != tc.hash {
+			t.Errorf("%d. blurHashOf(white, %d, %d) => %s, want %s", index, tc.xComp, tc.yComp, hash, tc.hash)
+		}
+	}
+
+	// dark on the left, where the first horizontal component is positive
+	pixels := make([]byte, 0, 8*6*4)
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 8; x++ {
+			v := byte(x * 255 / 7)
+			pixels = append(pixels, v, v, v, 0xff)
+		}
+	}
+	hash := blurHashOf(pixels, 8, 6, 2, 1)
+	if v := strings.IndexByte(base83, hash[6])*83 + strings.IndexByte(base83, hash[7]); v/(19*19) >= 9 {
+		t.Errorf("blurHashOf(gradient) => %s, red of the first component %d, want below 9", hash, v/(19*19))
+	}
+}
+
+func TestBlurHash(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 120, 80))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{0x30, 0x60, 0x90, 0xff}}, image.ZP, draw.Src)
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := BlurHash(buf.Bytes(), 4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hash) != 4+2*4*3 || !strings.HasPrefix(hash, "L") {
+		t.Errorf("BlurHash() => %s", hash)
+	}
+
+	if _, err := BlurHash(buf.Bytes(), 0, 3); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("BlurHash(0, 3) => %v, want ErrInvalidOption", err)
+	}
+}
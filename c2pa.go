@@ -0,0 +1,219 @@
+package vips
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// C2PA manifests, or Content Credentials, record where an image came from
+// and how it was edited, in a JUMBF box store labelled "c2pa". JPEG keeps
+// the store in APP11 segments, split over several when it is large, and PNG
+// in a caBX chunk.
+//
+// Copying the store along is all Options.KeepC2PA does: the manifest binds
+// a hash of the source bytes, which re-encoding changes, so validators will
+// flag the copy unless a new manifest claiming the old one as an
+// ingredient is signed over it.
+
+const (
+	jpegAPP11 = 0xeb
+	jpegSOS   = 0xda
+	// jumbfMaxPacket is the most of a box one APP11 segment carries, after
+	// its length, "JP", instance and sequence number.
+	jumbfMaxPacket = 0xffff - 2 - 8
+)
+
+// c2paOf returns the JUMBF manifest store of a JPEG or PNG buf, or nil
+// when it has none.
+func c2paOf(buf []byte) []byte {
+	buf = skipJunk(buf)
+	switch {
+	case len(buf) >= 2 && bytes.Equal(buf[:2], MARKER_JPEG):
+		return jpegC2PA(buf)
+	case len(buf) >= 8 && bytes.Equal(buf[:2], MARKER_PNG):
+		return pngC2PA(buf)
+	}
+	return nil
+}
+
+// withC2PA adds the manifest store to a JPEG or PNG buf, which should have
+// none, and returns other formats unchanged.
+func withC2PA(buf, store []byte) []byte {
+	switch {
+	case len(store) == 0:
+		return buf
+	case len(buf) >= 2 && bytes.Equal(buf[:2], MARKER_JPEG):
+		return jpegWithC2PA(buf, store)
+	case len(buf) >= 8 && bytes.Equal(buf[:2], MARKER_PNG):
+		return pngWithC2PA(buf, store)
+	}
+	return buf
+}
+
+// isC2PA reports whether box is a JUMBF superbox labelled "c2pa".
+func isC2PA(box []byte) bool {
+	header := boxHeader(box)
+	if header == 0 || len(box) < header+8 || string(box[4:8]) != "jumb" {
+		return false
+	}
+	// the description box first: length, "jumd", a 16 byte type, toggles
+	// and the label
+	jumd := box[header:]
+	if len(jumd) < 8+16+1 || string(jumd[4:8]) != "jumd" {
+		return false
+	}
+	label := jumd[8+16+1:]
+	if i := bytes.IndexByte(label, 0); i >= 0 {
+		label = label[:i]
+	}
+	return string(label) == "c2pa"
+}
+
+// boxHeader is the size of the length and type fields of a box, 16 rather
+// than 8 with an extended length, or 0 when box is too short.
+func boxHeader(box []byte) int {
+	switch {
+	case len(box) < 8:
+		return 0
+	case binary.BigEndian.Uint32(box) != 1:
+		return 8
+	case len(box) < 16:
+		return 0
+	}
+	return 16
+}
+
+// jpegSegments calls f with the marker and payload of each segment of a
+// JPEG buf, and the offset the segment starts at, up to the scan.
+func jpegSegments(buf []byte, f func(offset int, marker byte, payload []byte)) {
+	for i := 2; i+4 <= len(buf) && buf[i] == 0xff; {
+		marker := buf[i+1]
+		if marker == jpegSOS {
+			return
+		}
+		// the length counts its own two bytes
+		length := int(binary.BigEndian.Uint16(buf[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(buf) {
+			return
+		}
+		f(i, marker, buf[i+4:end])
+		i = end
+	}
+}
+
+// jpegC2PA joins the APP11 packets of the c2pa box.
+func jpegC2PA(buf []byte) []byte {
+	boxes := map[uint16][]byte{}
+	var order []uint16
+	jpegSegments(buf, func(_ int, marker byte, payload []byte) {
+		if marker != jpegAPP11 || len(payload) < 8 || string(payload[:2]) != "JP" {
+			return
+		}
+		instance := binary.BigEndian.Uint16(payload[2:])
+		packet := payload[8:]
+		box, seen := boxes[instance]
+		if !seen {
+			order = append(order, instance)
+		} else if header := boxHeader(packet); header > 0 {
+			// later packets repeat the header of the box
+			packet = packet[header:]
+		}
+		boxes[instance] = append(box, packet...)
+	})
+
+	for _, instance := range order {
+		if isC2PA(boxes[instance]) {
+			return boxes[instance]
+		}
+	}
+	return nil
+}
+
+// jpegWithC2PA splits store into APP11 packets, inserted after the APP0
+// and APP1 segments at the start of buf.
+func jpegWithC2PA(buf, store []byte) []byte {
+	at := 2
+	jpegSegments(buf, func(offset int, marker byte, payload []byte) {
+		if at == offset && (marker == 0xe0 || marker == 0xe1) {
+			at = offset + 4 + len(payload)
+		}
+	})
+
+	header := store[:boxHeader(store)]
+	var segments bytes.Buffer
+	for seq, rest := uint32(1), store; len(rest) > 0; seq++ {
+		var packet []byte
+		if seq > 1 {
+			packet = header
+		}
+		n := jumbfMaxPacket - len(packet)
+		if n > len(rest) {
+			n = len(rest)
+		}
+		packet = append(append([]byte{}, packet...), rest[:n]...)
+		rest = rest[n:]
+
+		var h [12]byte
+		h[0], h[1] = 0xff, jpegAPP11
+		binary.BigEndian.PutUint16(h[2:], uint16(2+8+len(packet)))
+		copy(h[4:], "JP")
+		binary.BigEndian.PutUint16(h[6:], 1)
+		binary.BigEndian.PutUint32(h[8:], seq)
+		segments.Write(h[:])
+		segments.Write(packet)
+	}
+
+	out := make([]byte, 0, len(buf)+segments.Len())
+	out = append(out, buf[:at]...)
+	out = append(out, segments.Bytes()...)
+	return append(out, buf[at:]...)
+}
+
+// pngChunks calls f with the type and data of each chunk of a PNG buf,
+// and the offset the chunk starts at.
+func pngChunks(buf []byte, f func(offset int, typ string, data []byte)) {
+	for i := 8; i+12 <= len(buf); {
+		n := int(binary.BigEndian.Uint32(buf[i:]))
+		if n < 0 || i+12+n > len(buf) {
+			return
+		}
+		f(i, string(buf[i+4:i+8]), buf[i+8:i+8+n])
+		i += 12 + n
+	}
+}
+
+func pngC2PA(buf []byte) []byte {
+	var store []byte
+	pngChunks(buf, func(_ int, typ string, data []byte) {
+		if typ == "caBX" && store == nil && isC2PA(data) {
+			store = data
+		}
+	})
+	return store
+}
+
+// pngWithC2PA inserts store as a caBX chunk before the image data of buf.
+func pngWithC2PA(buf, store []byte) []byte {
+	at := -1
+	pngChunks(buf, func(offset int, typ string, _ []byte) {
+		if typ == "IDAT" && at < 0 {
+			at = offset
+		}
+	})
+	if at < 0 {
+		return buf
+	}
+
+	chunk := make([]byte, 12+len(store))
+	binary.BigEndian.PutUint32(chunk, uint32(len(store)))
+	copy(chunk[4:], "caBX")
+	copy(chunk[8:], store)
+	binary.BigEndian.PutUint32(chunk[8+len(store):], crc32.ChecksumIEEE(chunk[4:8+len(store)]))
+
+	out := make([]byte, 0, len(buf)+len(chunk))
+	out = append(out, buf[:at]...)
+	out = append(out, chunk...)
+	return append(out, buf[at:]...)
+}
@@ -0,0 +1,90 @@
+package vips
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+)
+
+// jumbf is a JUMBF superbox labelled label around size bytes of content.
+func jumbf(label string, size int) []byte {
+	box := func(typ string, data []byte) []byte {
+		b := make([]byte, 8, 8+len(data))
+		binary.BigEndian.PutUint32(b, uint32(8+len(data)))
+		copy(b[4:], typ)
+		return append(b, data...)
+	}
+	jumd := box("jumd", append(append(make([]byte, 16), 3), append([]byte(label), 0)...))
+	content := box("json", bytes.Repeat([]byte{'x'}, size))
+	return box("jumb", append(jumd, content...))
+}
+
+func TestC2PAOf(t *testing.T) {
+	store := jumbf("c2pa", 150000)
+	for _, name := range []string{"testdata/1.jpg", "testdata/6.png"} {
+		buf, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c2paOf(buf) != nil {
+			t.Fatalf("%s already has a manifest", name)
+		}
+
+		marked := withC2PA(buf, store)
+		if !bytes.Equal(c2paOf(marked), store) {
+			t.Errorf("c2paOf(withC2PA(%s)) lost the manifest", name)
+		}
+		if _, err := Size(marked); err != nil {
+			t.Errorf("Size() of %s with a manifest: %v", name, err)
+		}
+		if c2paOf(withC2PA(buf, jumbf("other", 10))) != nil {
+			t.Errorf("c2paOf() of %s took another JUMBF box for a manifest", name)
+		}
+	}
+}
+
+func TestKeepC2PA(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := jumbf("c2pa", 1000)
+	buf = withC2PA(buf, store)
+
+	if m, err := Size(buf); err != nil || !m.HasC2PA {
+		t.Errorf("Size() = %+v, %v, want HasC2PA", m, err)
+	}
+
+	r, err := ResizeWithInfo(buf, Options{Width: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.C2PA || c2paOf(r.Buf) != nil {
+		t.Errorf("ResizeWithInfo() => C2PA %v, manifest kept %v, want reported and stripped", r.C2PA, c2paOf(r.Buf) != nil)
+	}
+
+	for _, typ := range []ImageType{JPEG, PNG} {
+		out, err := Resize(buf, Options{Width: 100, Savetype: typ, KeepC2PA: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(c2paOf(out), store) {
+			t.Errorf("Resize(%v) with KeepC2PA lost the manifest", typ)
+		}
+	}
+}
+
+func TestJPEGSegmentsTruncated(t *testing.T) {
+	for _, length := range []byte{0, 1} {
+		buf := []byte{0xff, 0xd8, 0xff, jpegAPP11, 0, length, 0xff, 0xd9}
+		n := 0
+		jpegSegments(buf, func(int, byte, []byte) { n++ })
+		if n != 0 {
+			t.Errorf("jpegSegments() with a length of %d => %d segments, want 0", length, n)
+		}
+		if c2paOf(buf) != nil {
+			t.Errorf("c2paOf() with a segment length of %d found a manifest", length)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+	"time"
+)
+
+// Caption describes a text overlay built from the capture date stored in
+// the EXIF data of the source image, as used for photo-book exports.
+type Caption struct {
+	// DateFormat is the time layout for the capture date,
+	// "2 January 2006" by default.
+	DateFormat string
+	// Location is appended after the date. EXIF only stores coordinates,
+	// so reverse geocoding is left to the caller.
+	Location string
+	// Font is a Pango font description. By default a sans font is sized
+	// to the height of the image.
+	Font string
+	// Gravity places the caption; SOUTH is the usual choice.
+	Gravity Gravity
+	// Margin in pixels between the caption and the image edges, half the
+	// font size by default.
+	Margin int
+	// Color of the text, white by default.
+	Color color.RGBA
+	// Shadow draws a dark copy behind the text to keep it legible on
+	// bright images.
+	Shadow bool
+}
+
+// exifDateFields are tried in order to find when a photo was taken.
+var exifDateFields = []string{
+	"exif-ifd2-DateTimeOriginal",
+	"exif-ifd2-DateTimeDigitized",
+	"exif-ifd0-DateTime",
+}
+
+// parseExifDate parses the value of a libvips EXIF date field, which
+// looks like "2014:05:03 12:34:56 (2014:05:03 12:34:56, ASCII, 20 ...)".
+func parseExifDate(s string) (time.Time, bool) {
+	const layout = "2006:01:02 15:04:05"
+	if len(s) < len(layout) {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(layout, s[:len(layout)])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// captionText joins the formatted capture date and location, either of
+// which may be missing.
+func captionText(date time.Time, hasDate bool, c Caption) string {
+	var parts []string
+	if hasDate {
+		layout := c.DateFormat
+		if layout == "" {
+			layout = "2 January 2006"
+		}
+		parts = append(parts, date.Format(layout))
+	}
+	if location := strings.TrimSpace(c.Location); location != "" {
+		parts = append(parts, location)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// drawCaption renders c onto image, which it releases. Images without a
+// capture date or location are returned unchanged.
+func drawCaption(image *C.struct__VipsImage, c Caption) (*C.struct__VipsImage, error) {
+	var date time.Time
+	var hasDate bool
+	for _, field := range exifDateFields {
+		if date, hasDate = parseExifDate(vipsImageString(image, field)); hasDate {
+			break
+		}
+	}
+
+	text := captionText(date, hasDate, c)
+	if text == "" {
+		debug("no caption")
+		return image, nil
+	}
+
+	width, height := int(image.Xsize), int(image.Ysize)
+	size := height / 30
+	if size < 12 {
+		size = 12
+	}
+	if c.Font == "" {
+		c.Font = fmt.Sprintf("sans %d", size)
+	}
+	if c.Margin == 0 {
+		c.Margin = size / 2
+	}
+	if c.Color == (color.RGBA{}) {
+		c.Color = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	}
+
+	mask, err := vipsText(text, c.Font)
+	if err != nil {
+		C.g_object_unref(C.gpointer(image))
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(mask))
+
+	left, top := captionPosition(width, height, int(mask.Xsize), int(mask.Ysize), c.Margin, c.Gravity)
+	debug("caption %q at %d,%d", text, left, top)
+
+	if c.Shadow {
+		offset := size/12 + 1
+		image, err = vipsBlendMask(image, mask, left+offset, top+offset, color.RGBA{0, 0, 0, 0xff})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return vipsBlendMask(image, mask, left, top, c.Color)
+}
+
+// captionPosition places a w x h box inside a width x height image,
+// margin pixels away from the edges picked by gravity.
+func captionPosition(width, height, w, h, margin int, gravity Gravity) (int, int) {
+	left := (width - w) / 2
+	top := (height - h) / 2
+	switch gravity {
+	case NORTH:
+		top = margin
+	case SOUTH:
+		top = height - h - margin
+	case EAST:
+		left = width - w - margin
+	case WEST:
+		left = margin
+	}
+	return left, top
+}
@@ -0,0 +1,46 @@
+package vips
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseExifDate(t *testing.T) {
+	var testCases = []struct {
+		value string
+		ok    bool
+		date  time.Time
+	}{
+		{"", false, time.Time{}},
+		{"garbage", false, time.Time{}},
+		{"2014:05:03 12:34:56", true, time.Date(2014, 5, 3, 12, 34, 56, 0, time.UTC)},
+		{"2014:05:03 12:34:56 (2014:05:03 12:34:56, ASCII, 20 components, 20 bytes)", true, time.Date(2014, 5, 3, 12, 34, 56, 0, time.UTC)},
+	}
+
+	for index, tc := range testCases {
+		date, ok := parseExifDate(tc.value)
+		if ok != tc.ok || !date.Equal(tc.date) {
+			t.Errorf("%d. parseExifDate(%q) => %v, %v, want %v, %v", index, tc.value, date, ok, tc.date, tc.ok)
+		}
+	}
+}
+
+func TestCaptionText(t *testing.T) {
+	date := time.Date(2014, 5, 3, 12, 34, 56, 0, time.UTC)
+	var testCases = []struct {
+		hasDate bool
+		caption Caption
+		text    string
+	}{
+		{false, Caption{}, ""},
+		{true, Caption{}, "3 May 2014"},
+		{true, Caption{DateFormat: "2006-01-02", Location: "Lisbon"}, "2014-05-03 · Lisbon"},
+		{false, Caption{Location: " Lisbon "}, "Lisbon"},
+	}
+
+	for index, tc := range testCases {
+		if text := captionText(date, tc.hasDate, tc.caption); text != tc.text {
+			t.Errorf("%d. captionText(%#v) => %q, want %q", index, tc.caption, text, tc.text)
+		}
+	}
+}
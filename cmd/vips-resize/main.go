@@ -0,0 +1,163 @@
+// Command vips-resize batch converts images with the options of the vips
+// package, through the same ResizeFile services use:
+//
+//	vips-resize -width 800 -format webp -quality 80 -out thumbs/ photos/*.jpg
+//
+// Each input is written to the -out directory under its own name, with the
+// extension of -format or else its own.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/daddye/vips"
+)
+
+var gravities = map[string]vips.Gravity{
+	"centre": vips.CENTRE,
+	"center": vips.CENTRE,
+	"north":  vips.NORTH,
+	"east":   vips.EAST,
+	"south":  vips.SOUTH,
+	"west":   vips.WEST,
+}
+
+func main() {
+	var (
+		o        vips.Options
+		out      string
+		format   string
+		quality  string
+		gravity  string
+		rotate   int
+		caption  bool
+		location string
+		jobs     int
+	)
+	flag.IntVar(&o.Width, "width", 0, "output width")
+	flag.IntVar(&o.Height, "height", 0, "output height")
+	flag.BoolVar(&o.Crop, "crop", false, "crop to fill width x height")
+	flag.BoolVar(&o.Embed, "embed", false, "embed in width x height")
+	flag.BoolVar(&o.Enlarge, "enlarge", false, "enlarge smaller images")
+	flag.StringVar(&gravity, "gravity", "centre", "crop gravity: centre, north, east, south or west")
+	flag.StringVar(&o.AspectRatio, "aspect", "", "crop to an aspect ratio such as 16:9")
+	flag.BoolVar(&o.Trim, "trim", false, "trim margins")
+	flag.IntVar(&rotate, "rotate", 0, "rotate by 90, 180 or 270 degrees")
+	flag.Float64Var(&o.RotateDegrees, "degrees", 0, "rotate by any angle")
+	flag.BoolVar(&o.Flip, "flip", false, "mirror left to right")
+	flag.BoolVar(&o.Flop, "flop", false, "mirror top to bottom")
+	flag.StringVar(&format, "format", "", "output format by extension, such as jpg, png or webp; the input's by default")
+	flag.StringVar(&quality, "quality", "", "quality 1-100 or auto")
+	flag.IntVar((*int)(&o.Effort), "effort", 0, "encoding effort 1-10")
+	flag.BoolVar(&caption, "caption", false, "caption with the capture date")
+	flag.StringVar(&location, "location", "", "location after the caption date")
+	flag.StringVar(&out, "out", ".", "output directory")
+	flag.IntVar(&jobs, "j", 1, "images converted at once, 0 for one per CPU")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: vips-resize [flags] input...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	g, ok := gravities[strings.ToLower(gravity)]
+	if !ok {
+		fatalf("unknown gravity %q", gravity)
+	}
+	o.Gravity = g
+	o.Rotate = vips.Angle(rotate)
+	if caption || location != "" {
+		o.Caption = &vips.Caption{Location: location, Gravity: vips.SOUTH, Shadow: true}
+	}
+
+	switch quality {
+	case "":
+	case "auto":
+		o.Quality = vips.QUALITY_AUTO
+	default:
+		q, err := strconv.Atoi(quality)
+		if err != nil {
+			fatalf("bad quality %q", quality)
+		}
+		o.Quality = q
+	}
+
+	ext := ""
+	if format != "" {
+		if o.Savetype = vips.TypeOfExt(format); o.Savetype == vips.UNKNOWN {
+			fatalf("unknown format %q", format)
+		}
+		ext = o.Savetype.Ext()
+	}
+
+	if err := o.Validate(); err != nil {
+		fatalf("%v", err)
+	}
+	if err := os.MkdirAll(out, 0777); err != nil {
+		fatalf("%v", err)
+	}
+
+	var batch []vips.Job
+	var names []string
+	failed := false
+	for _, in := range flag.Args() {
+		outPath, err := outputOf(in, out, ext)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", in, err)
+			failed = true
+			continue
+		}
+		batch = append(batch, vips.Job{InPath: in, OutPath: outPath, Options: o})
+		names = append(names, in)
+	}
+
+	if _, err := vips.Batch(batch, jobs); err != nil {
+		var berr *vips.BatchError
+		if !errors.As(err, &berr) {
+			fatalf("%v", err)
+		}
+		for i, name := range names {
+			if err, ok := berr.Failed[i]; ok {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			}
+		}
+		failed = true
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// outputOf is where in is written to in dir, under its own name with ext
+// or its own extension.
+func outputOf(in, dir, ext string) (string, error) {
+	name := filepath.Base(in)
+	if ext != "" {
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + ext
+	}
+	outPath := filepath.Join(dir, name)
+
+	// the input is streamed, writing over it would corrupt it
+	if src, err := filepath.Abs(in); err == nil {
+		if dst, err := filepath.Abs(outPath); err == nil && src == dst {
+			return "", fmt.Errorf("would overwrite itself, choose another -out")
+		}
+	}
+	return outPath, nil
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "vips-resize: "+format+"\n", args...)
+	os.Exit(2)
+}
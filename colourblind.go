@@ -0,0 +1,56 @@
+package vips
+
+// ColourBlindness is a colour vision deficiency Options can simulate.
+type ColourBlindness int
+
+const (
+	// COLOURBLIND_NONE simulates nothing.
+	COLOURBLIND_NONE ColourBlindness = iota
+	// DEUTERANOPIA lacks green cones, the commonest deficiency.
+	DEUTERANOPIA
+	// PROTANOPIA lacks red cones, darkening reds.
+	PROTANOPIA
+	// TRITANOPIA lacks blue cones, confusing blues with greens.
+	TRITANOPIA
+)
+
+var colourBlindnessNames = map[ColourBlindness]string{
+	COLOURBLIND_NONE: "none",
+	DEUTERANOPIA:     "deuteranopia",
+	PROTANOPIA:       "protanopia",
+	TRITANOPIA:       "tritanopia",
+}
+
+func (c ColourBlindness) String() string {
+	if name, ok := colourBlindnessNames[c]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// cvdMatrices are the matrices of Machado, Oliveira and Fernandes (2009)
+// for each deficiency at full severity, row by row, applied to linear RGB.
+var cvdMatrices = map[ColourBlindness][9]float64{
+	DEUTERANOPIA: {
+		0.367322, 0.860646, -0.227968,
+		0.280085, 0.672501, 0.047413,
+		-0.011820, 0.042940, 0.968881,
+	},
+	PROTANOPIA: {
+		0.152286, 1.052583, -0.204868,
+		0.114503, 0.786281, 0.099216,
+		-0.003882, -0.048116, 1.051998,
+	},
+	TRITANOPIA: {
+		1.255528, -0.076749, -0.178779,
+		-0.078411, 0.930809, 0.147602,
+		0.004733, 0.691367, 0.303900,
+	},
+}
+
+// SimulateColourBlindness shows buf as seen with c at its original size,
+// keeping its format where it can be saved. See
+// Options.SimulateColourBlindness.
+func SimulateColourBlindness(buf []byte, c ColourBlindness) ([]byte, error) {
+	return Resize(buf, Options{SimulateColourBlindness: c, Savetype: keptType(buf)})
+}
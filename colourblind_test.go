@@ -0,0 +1,49 @@
+package vips
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestSimulateColourBlindness(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{0xff, 0, 0, 0x80})
+	img.SetNRGBA(1, 0, color.NRGBA{0, 0xff, 0, 0xff})
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := SimulateColourBlindness(buf.Bytes(), DEUTERANOPIA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outImg, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// red and green both turn a muddy yellow
+	red := color.NRGBAModel.Convert(outImg.At(0, 0)).(color.NRGBA)
+	green := color.NRGBAModel.Convert(outImg.At(1, 0)).(color.NRGBA)
+	for _, c := range []color.NRGBA{red, green} {
+		if d := int(c.R) - int(c.G); d < -40 || d > 40 || c.B > 0x50 {
+			t.Errorf("SimulateColourBlindness() => red %v, green %v", red, green)
+		}
+	}
+	if red.A != 0x80 || green.A != 0xff {
+		t.Errorf("SimulateColourBlindness() => alpha %d, %d, want 128, 255", red.A, green.A)
+	}
+
+	if _, err := Resize(buf.Bytes(), Options{SimulateColourBlindness: TRITANOPIA + 1}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Resize() with an unknown deficiency => %v, want ErrInvalidOption", err)
+	}
+	if s := Explain(Options{SimulateColourBlindness: PROTANOPIA}).String(); !strings.Contains(s, "simulate_colour_blindness deficiency=protanopia") {
+		t.Errorf("Explain() = %s", s)
+	}
+}
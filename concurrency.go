@@ -0,0 +1,133 @@
+package vips
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Priority orders the operations queued for a free slot while
+// Config.MaxOperations or Config.Adaptive bounds them.
+type Priority int
+
+const (
+	// PRIORITY_INTERACTIVE is for user-facing requests, admitted first.
+	PRIORITY_INTERACTIVE Priority = iota
+	// PRIORITY_BATCH is for background work, such as regenerating
+	// variants, which waits while interactive operations queue.
+	PRIORITY_BATCH
+)
+
+// adaptWindow is how many operations the adaptive limit is revised after.
+const adaptWindow = 20
+
+// limiter admits up to limit operations at once, queueing the others. In
+// adaptive mode limit grows while operations queue for a tenth of their
+// run time or more, and shrinks when they run over twice as long as the
+// fastest seen recently, a sign they slow each other down.
+type limiter struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	limit    int // 0 for unlimited
+	running  int
+	waiting  [PRIORITY_BATCH + 1]int
+	adaptive bool
+	// onLimit is told of every new adaptive limit.
+	onLimit func(limit int)
+
+	n         int
+	wait, run time.Duration
+	baseline  time.Duration
+}
+
+func newLimiter() *limiter {
+	l := &limiter{}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// configure sets a fixed limit, or starts adapting from GOMAXPROCS.
+func (l *limiter) configure(limit int, adaptive bool, onLimit func(int)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.adaptive = adaptive
+	l.onLimit = onLimit
+	l.n, l.wait, l.run, l.baseline = 0, 0, 0, 0
+	l.limit = limit
+	if adaptive {
+		l.limit = runtime.GOMAXPROCS(0)
+		onLimit(l.limit)
+	}
+	l.cond.Broadcast()
+}
+
+// acquire waits for a free slot, after every operation of a higher
+// priority p waiting, and returns the func releasing it.
+func (l *limiter) acquire(p Priority) func() {
+	queued := time.Now()
+
+	l.mu.Lock()
+	l.waiting[p]++
+	for l.limit > 0 && l.running >= l.limit || p == PRIORITY_BATCH && l.waiting[PRIORITY_INTERACTIVE] > 0 {
+		l.cond.Wait()
+	}
+	l.waiting[p]--
+	l.running++
+	// batch operations may have been waiting on this one alone
+	if p == PRIORITY_INTERACTIVE && l.waiting[p] == 0 && l.waiting[PRIORITY_BATCH] > 0 {
+		l.cond.Broadcast()
+	}
+	l.mu.Unlock()
+
+	started := time.Now()
+	return func() {
+		l.mu.Lock()
+		l.running--
+		if l.adaptive {
+			l.observe(started.Sub(queued), time.Since(started))
+		}
+		l.cond.Broadcast()
+		l.mu.Unlock()
+	}
+}
+
+// observe records an operation that queued for wait and ran for run, and
+// revises the limit every adaptWindow of them. l.mu must be held.
+func (l *limiter) observe(wait, run time.Duration) {
+	l.n++
+	l.wait += wait
+	l.run += run
+	if l.n < adaptWindow {
+		return
+	}
+
+	avgWait := l.wait / time.Duration(l.n)
+	avgRun := l.run / time.Duration(l.n)
+	l.n, l.wait, l.run = 0, 0, 0
+
+	// the baseline follows the workload up slowly, and down at once
+	if l.baseline == 0 || avgRun < l.baseline {
+		l.baseline = avgRun
+	} else {
+		l.baseline += (avgRun - l.baseline) / 10
+	}
+
+	limit := l.limit
+	switch {
+	case avgRun > 2*l.baseline && limit > 1:
+		limit--
+	case avgWait > avgRun/10 && limit < 4*runtime.GOMAXPROCS(0):
+		limit++
+	}
+	if limit != l.limit {
+		debug("adaptive limit %d -> %d", l.limit, limit)
+		l.limit = limit
+		l.onLimit(limit)
+		l.cond.Broadcast()
+	}
+}
+
+// ops admits the operations of the package.
+var ops = newLimiter()
@@ -0,0 +1,110 @@
+package vips
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter()
+	l.configure(2, false, nil)
+
+	var running, peak int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release := l.acquire(PRIORITY_INTERACTIVE)
+			defer release()
+
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		}()
+	}
+	wg.Wait()
+
+	if peak != 2 {
+		t.Errorf("limiter of 2 ran %d at once", peak)
+	}
+}
+
+func TestLimiterAdaptive(t *testing.T) {
+	var limits []int
+	l := newLimiter()
+	l.configure(0, true, func(limit int) { limits = append(limits, limit) })
+
+	start := runtime.GOMAXPROCS(0)
+	if l.limit != start || len(limits) != 1 {
+		t.Fatalf("adaptive limiter started at %d, want %d", l.limit, start)
+	}
+
+	window := func(wait, run time.Duration) {
+		for i := 0; i < adaptWindow; i++ {
+			l.observe(wait, run)
+		}
+	}
+
+	// queueing grows the limit
+	window(50*time.Millisecond, 100*time.Millisecond)
+	if l.limit != start+1 {
+		t.Errorf("limit after queueing = %d, want %d", l.limit, start+1)
+	}
+
+	// slowing down shrinks it
+	window(0, 500*time.Millisecond)
+	if l.limit != start {
+		t.Errorf("limit after slowing down = %d, want %d", l.limit, start)
+	}
+
+	// neither keeps it
+	window(0, 100*time.Millisecond)
+	if l.limit != start {
+		t.Errorf("limit when idle = %d, want %d", l.limit, start)
+	}
+}
+
+func TestLimiterPriority(t *testing.T) {
+	l := newLimiter()
+	l.configure(1, false, nil)
+
+	waiting := func(p Priority, n int) {
+		for {
+			l.mu.Lock()
+			w := l.waiting[p]
+			l.mu.Unlock()
+			if w == n {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	release := l.acquire(PRIORITY_INTERACTIVE)
+
+	order := make(chan Priority, 2)
+	run := func(p Priority) {
+		release := l.acquire(p)
+		order <- p
+		release()
+	}
+	go run(PRIORITY_BATCH)
+	waiting(PRIORITY_BATCH, 1)
+	go run(PRIORITY_INTERACTIVE)
+	waiting(PRIORITY_INTERACTIVE, 1)
+
+	release()
+	if first, second := <-order, <-order; first != PRIORITY_INTERACTIVE || second != PRIORITY_BATCH {
+		t.Errorf("admitted %v before %v, want interactive first", first, second)
+	}
+}
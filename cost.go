@@ -0,0 +1,50 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrBudgetExceeded is returned for calls that would cost more than
+// Options.MaxCost.
+var ErrBudgetExceeded = errors.New("vips: cost budget exceeded")
+
+// Cost estimates the work of a call, for metering and billing. It depends
+// on the source and Options alone, so the same call always costs the
+// same.
+type Cost struct {
+	// Megapixels of the source as decoded, before shrink-on-load.
+	Megapixels float64
+	// Operations counts the steps Explain lists, load and save included.
+	Operations int
+}
+
+// costOf is the cost of a call making o of src.
+func costOf(src source, o Options) Cost {
+	return Cost{
+		Megapixels: float64(src.width) * float64(src.height) / 1e6,
+		Operations: len(Explain(o)),
+	}
+}
+
+// exceeds reports whether c is over budget in either field; zero fields
+// of budget don't cap.
+func (c Cost) exceeds(budget Cost) bool {
+	return (budget.Megapixels > 0 && c.Megapixels > budget.Megapixels) ||
+		(budget.Operations > 0 && c.Operations > budget.Operations)
+}
+
+// checkCost fails calls that would cost more than o.MaxCost, before
+// pixels are decoded, releasing image when they do.
+func checkCost(image *C.struct__VipsImage, src source, o Options) error {
+	if c := costOf(src, o); c.exceeds(o.MaxCost) {
+		C.g_object_unref(C.gpointer(image))
+		return fmt.Errorf("%w: %.1f megapixels in %d operations, budget %+v", ErrBudgetExceeded, c.Megapixels, c.Operations, o.MaxCost)
+	}
+	return nil
+}
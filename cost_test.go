@@ -0,0 +1,62 @@
+package vips
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestCostExceeds(t *testing.T) {
+	var testCases = []struct {
+		cost, budget Cost
+		exceeds      bool
+	}{
+		{Cost{2, 5}, Cost{}, false},
+		{Cost{2, 5}, Cost{Megapixels: 2}, false},
+		{Cost{2, 5}, Cost{Megapixels: 1.5}, true},
+		{Cost{2, 5}, Cost{Operations: 5}, false},
+		{Cost{2, 5}, Cost{Megapixels: 10, Operations: 4}, true},
+	}
+
+	for index, tc := range testCases {
+		if exceeds := tc.cost.exceeds(tc.budget); exceeds != tc.exceeds {
+			t.Errorf("%d. %+v.exceeds(%+v) => %v, want %v", index, tc.cost, tc.budget, exceeds, tc.exceeds)
+		}
+	}
+}
+
+func TestResizeCost(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, image.NewGray(image.Rect(0, 0, 1000, 500))); err != nil {
+		t.Fatal(err)
+	}
+
+	o := Options{Width: 100, Savetype: PNG}
+	r, err := ResizeWithInfo(buf.Bytes(), o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Cost{0.5, len(Explain(o))}); r.Cost != want {
+		t.Errorf("ResizeWithInfo() => cost %+v, want %+v", r.Cost, want)
+	}
+
+	for _, budget := range []Cost{{Megapixels: 0.4}, {Operations: r.Cost.Operations - 1}} {
+		o.MaxCost = budget
+		if _, err := Resize(buf.Bytes(), o); !errors.Is(err, ErrBudgetExceeded) {
+			t.Errorf("Resize() within %+v => %v, want ErrBudgetExceeded", budget, err)
+		}
+	}
+	o.MaxCost = r.Cost
+	if _, err := Resize(buf.Bytes(), o); err != nil {
+		t.Errorf("Resize() within its own cost => %v", err)
+	}
+
+	if _, err := Load(buf.Bytes(), Options{MaxCost: Cost{Megapixels: 0.4}}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Load() within 0.4 megapixels => %v, want ErrBudgetExceeded", err)
+	}
+	if _, err := Resize(buf.Bytes(), Options{MaxCost: Cost{Operations: -1}}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Resize() with a negative budget => %v, want ErrInvalidOption", err)
+	}
+}
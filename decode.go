@@ -0,0 +1,112 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"errors"
+)
+
+// ErrLimitExceeded is returned for images larger than the Limits in force.
+var ErrLimitExceeded = errors.New("vips: image exceeds limits")
+
+// Limits bounds the images accepted for decoding. Zero fields are
+// unlimited.
+type Limits struct {
+	// MaxBytes is the largest encoded input accepted.
+	MaxBytes int
+	// MaxWidth and MaxHeight bound the decoded dimensions.
+	MaxWidth  int
+	MaxHeight int
+	// MaxPixels bounds width times height.
+	MaxPixels int64
+}
+
+// DefaultLimits are the limits Resize and ExtractArea decode with, unless
+// Options set their own.
+var DefaultLimits = Limits{
+	MaxBytes:  100 * 1048576, // 100Mb
+	MaxWidth:  65535,
+	MaxHeight: 65535,
+	MaxPixels: 268402689, // 16383 x 16383
+}
+
+// limitsOf is DefaultLimits with the limits set in o in their place.
+func limitsOf(o Options) Limits {
+	l := DefaultLimits
+	if o.MaxInputBytes > 0 {
+		l.MaxBytes = o.MaxInputBytes
+	}
+	if o.MaxDimension > 0 {
+		l.MaxWidth, l.MaxHeight = o.MaxDimension, o.MaxDimension
+	}
+	if o.MaxPixels > 0 {
+		l.MaxPixels = o.MaxPixels
+	}
+	return l
+}
+
+// within returns the tighter of l and m for each limit.
+func (l Limits) within(m Limits) Limits {
+	tighter := func(a, b int64) int64 {
+		if a <= 0 || (b > 0 && b < a) {
+			return b
+		}
+		return a
+	}
+	return Limits{
+		MaxBytes:  int(tighter(int64(l.MaxBytes), int64(m.MaxBytes))),
+		MaxWidth:  int(tighter(int64(l.MaxWidth), int64(m.MaxWidth))),
+		MaxHeight: int(tighter(int64(l.MaxHeight), int64(m.MaxHeight))),
+		MaxPixels: tighter(l.MaxPixels, m.MaxPixels),
+	}
+}
+
+// allows reports whether a width x height image is within l.
+func (l Limits) allows(width, height int) bool {
+	if l.MaxWidth > 0 && width > l.MaxWidth {
+		return false
+	}
+	if l.MaxHeight > 0 && height > l.MaxHeight {
+		return false
+	}
+	return l.MaxPixels <= 0 || int64(width)*int64(height) <= l.MaxPixels
+}
+
+// Decode detects the format of buf and decodes every pixel under l, then
+// discards the result. It encodes nothing and leaves package settings,
+// the audit sink and the library lifecycle as it found them, which makes
+// it the entry point for fuzzing harnesses:
+//
+//	func Fuzz(data []byte) int {
+//		if vips.Decode(data, vips.Limits{MaxPixels: 1 << 16}) != nil {
+//			return 0
+//		}
+//		return 1
+//	}
+func Decode(buf []byte, l Limits) error {
+	if len(buf) == 0 {
+		return ErrEmptyBuffer
+	}
+
+	release, err := acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	image, _, err := loadBuffer(buf, l, true)
+	if err != nil {
+		return err
+	}
+
+	return vipsDecodeAll(image)
+}
@@ -0,0 +1,91 @@
+package vips
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Decode(buf, DefaultLimits); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, l := range []Limits{
+		{MaxBytes: len(buf) - 1},
+		{MaxWidth: 1},
+		{MaxHeight: 1},
+		{MaxPixels: 1},
+	} {
+		if err := Decode(buf, l); err != ErrLimitExceeded {
+			t.Errorf("Decode(%+v) = %v, want ErrLimitExceeded", l, err)
+		}
+	}
+
+	for _, bad := range [][]byte{nil, []byte("not an image")} {
+		if err := Decode(bad, Limits{}); err == nil {
+			t.Errorf("Decode(%d bytes of garbage) did not fail", len(bad))
+		}
+	}
+}
+
+func TestLimitsAllows(t *testing.T) {
+	l := Limits{MaxWidth: 100, MaxHeight: 50, MaxPixels: 4000}
+	cases := []struct {
+		width, height int
+		want          bool
+	}{
+		{100, 40, true},
+		{101, 1, false},
+		{1, 51, false},
+		{100, 41, false},
+	}
+	for _, c := range cases {
+		if got := l.allows(c.width, c.height); got != c.want {
+			t.Errorf("allows(%d, %d) = %v, want %v", c.width, c.height, got, c.want)
+		}
+	}
+	if !(Limits{}).allows(1<<20, 1<<20) {
+		t.Errorf("zero Limits refused an image")
+	}
+}
+
+func TestLimitsOf(t *testing.T) {
+	l := limitsOf(Options{MaxDimension: 100, MaxPixels: 5000})
+	if l.MaxWidth != 100 || l.MaxHeight != 100 || l.MaxPixels != 5000 || l.MaxBytes != DefaultLimits.MaxBytes {
+		t.Errorf("limitsOf() = %+v", l)
+	}
+
+	m := l.within(Limits{MaxWidth: 50, MaxPixels: 10000})
+	if m.MaxWidth != 50 || m.MaxHeight != 100 || m.MaxPixels != 5000 {
+		t.Errorf("within() = %+v", m)
+	}
+	if (Limits{}).within(l) != l {
+		t.Errorf("no limits within %+v = %+v", l, (Limits{}).within(l))
+	}
+}
+
+func TestResizeLimits(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, o := range []Options{
+		{Width: 100, MaxInputBytes: 10},
+		{Width: 100, MaxDimension: 10},
+		{Width: 100, MaxPixels: 100},
+	} {
+		if _, err := Resize(buf, o); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("Resize(%+v) = %v, want %v", o, err, ErrLimitExceeded)
+		}
+	}
+	if _, err := Resize(buf, Options{Width: 100, MaxDimension: 100000}); err != nil {
+		t.Errorf("Resize() within limits = %v", err)
+	}
+}
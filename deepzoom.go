@@ -0,0 +1,76 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"errors"
+)
+
+// Layout is the directory structure of a tile pyramid.
+type Layout int
+
+const (
+	// LAYOUT_DZ is Deep Zoom, as read by OpenSeadragon.
+	LAYOUT_DZ      Layout = C.VIPS_FOREIGN_DZ_LAYOUT_DZ
+	LAYOUT_ZOOMIFY Layout = C.VIPS_FOREIGN_DZ_LAYOUT_ZOOMIFY
+	LAYOUT_GOOGLE  Layout = C.VIPS_FOREIGN_DZ_LAYOUT_GOOGLE
+)
+
+// DeepZoomOptions describes a tile pyramid written by DZSave.
+type DeepZoomOptions struct {
+	// Path names the pyramid without extension: for Deep Zoom, "out/big"
+	// writes out/big.dzi and the tiles under out/big_files.
+	Path string
+	// TileSize is the tile edge in pixels, 254 by default, and Overlap the
+	// pixels tiles share with their neighbours, 1 by default.
+	TileSize int
+	Overlap  int
+	// Suffix is the tile format with saver options, ".jpeg" by default,
+	// such as ".jpeg[Q=85]" or ".png".
+	Suffix string
+	Layout Layout
+	// Zip writes the pyramid into Path.zip rather than a directory.
+	Zip bool
+}
+
+// DZSave cuts buf into a pyramid of tiles for deep-zoom viewers such as
+// OpenSeadragon, so very large images can be served as static files.
+func DZSave(buf []byte, o DeepZoomOptions) error {
+	if len(buf) == 0 {
+		return ErrEmptyBuffer
+	}
+	if o.Path == "" {
+		return errors.New("vips: deep zoom needs a path")
+	}
+	if o.TileSize == 0 {
+		o.TileSize = 254
+	}
+	if o.Overlap == 0 {
+		o.Overlap = 1
+	}
+	if o.Suffix == "" {
+		o.Suffix = ".jpeg"
+	}
+
+	release, err := acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	image, _, err := loadBuffer(buf, DefaultLimits, false)
+	if err != nil {
+		return err
+	}
+
+	return vipsDZSave(image, o)
+}
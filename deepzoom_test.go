@@ -0,0 +1,38 @@
+package vips
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDZSave(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "vips")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "pyramid")
+	if err := DZSave(buf, DeepZoomOptions{Path: path, TileSize: 128}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path + ".dzi"); err != nil {
+		t.Errorf("DZSave() wrote no descriptor: %v", err)
+	}
+	tiles, _ := filepath.Glob(filepath.Join(path+"_files", "0", "*.jpeg"))
+	if len(tiles) != 1 {
+		t.Errorf("DZSave() level 0 has %d tiles, want 1", len(tiles))
+	}
+
+	if err := DZSave(buf, DeepZoomOptions{}); err == nil {
+		t.Errorf("DZSave() without a path did not fail")
+	}
+}
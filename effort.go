@@ -0,0 +1,44 @@
+package vips
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// Effort trades encoding CPU for smaller files, from EFFORT_FASTEST to
+// EFFORT_SMALLEST. It sets the WebP method, PNG zlib level and JPEG XL
+// effort; JPEG has no such knob, and formats added with RegisterFormat,
+// such as AVIF, take their speed from their Suffix, like
+// ".avif[speed=8]". Settings made per format in Options take precedence.
+type Effort int
+
+const (
+	// EFFORT_DEFAULT uses the global effort, or the libvips defaults when
+	// none is set.
+	EFFORT_DEFAULT  Effort = 0
+	EFFORT_FASTEST  Effort = 1
+	EFFORT_SMALLEST Effort = 10
+)
+
+var defaultEffort int32
+
+// SetEffort sets the effort of operations whose Options leave it at
+// EFFORT_DEFAULT, so the trade-off can be tuned for the whole process.
+func SetEffort(e Effort) {
+	atomic.StoreInt32(&defaultEffort, int32(e))
+}
+
+// effortOf is the effort o asks for, EFFORT_DEFAULT when neither it nor
+// SetEffort chose one.
+func effortOf(o Options) Effort {
+	if o.Effort != EFFORT_DEFAULT {
+		return o.Effort
+	}
+	return Effort(atomic.LoadInt32(&defaultEffort))
+}
+
+// scale maps e onto lo-hi, lo being fastest.
+func (e Effort) scale(lo, hi int) int {
+	t := float64(e-EFFORT_FASTEST) / float64(EFFORT_SMALLEST-EFFORT_FASTEST)
+	return lo + int(math.Floor(t*float64(hi-lo)+0.5))
+}
@@ -0,0 +1,32 @@
+package vips
+
+import (
+	"testing"
+)
+
+func TestEffort(t *testing.T) {
+	defer SetEffort(EFFORT_DEFAULT)
+
+	cases := []struct {
+		global, o         Effort
+		compression, webp int
+	}{
+		{EFFORT_DEFAULT, EFFORT_DEFAULT, 6, 4},
+		{EFFORT_DEFAULT, EFFORT_FASTEST, 1, 0},
+		{EFFORT_DEFAULT, EFFORT_SMALLEST, 9, 6},
+		{EFFORT_FASTEST, EFFORT_DEFAULT, 1, 0},
+		{EFFORT_FASTEST, EFFORT_SMALLEST, 9, 6},
+	}
+	for _, c := range cases {
+		SetEffort(c.global)
+		o := saveDefaults(Options{Effort: c.o})
+		if o.PNG.Compression != c.compression || o.WebP.ReductionEffort != c.webp {
+			t.Errorf("effort %d over %d => PNG %d, WebP %d, want %d, %d", c.o, c.global, o.PNG.Compression, o.WebP.ReductionEffort, c.compression, c.webp)
+		}
+	}
+
+	SetEffort(EFFORT_SMALLEST)
+	if o := saveDefaults(Options{PNG: PNGOptions{Compression: 2}}); o.PNG.Compression != 2 {
+		t.Errorf("effort overrode PNG compression %d", o.PNG.Compression)
+	}
+}
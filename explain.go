@@ -0,0 +1,200 @@
+package vips
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Op is one step Resize takes, with the parameters it takes it with.
+type Op struct {
+	Name string
+	// Params are "name=value" pairs.
+	Params []string
+}
+
+// Plan is the steps Resize takes for some Options, in order.
+type Plan []Op
+
+// Explain lists the steps Resize takes for o, so support can tell why an
+// output looks the way it does. Which steps run depends on o alone; how
+// far the image is shrunk, and whether JPEG shrink-on-load applies, is
+// only decided once the source is decoded.
+func Explain(o Options) Plan {
+	var p Plan
+	add := func(name string, params ...string) {
+		p = append(p, Op{name, params})
+	}
+	param := func(name string, value interface{}) string {
+		return fmt.Sprintf("%s=%v", name, value)
+	}
+
+	load := []string{param("fail", o.FailOnError)}
+	if l := limitsOf(o); l != (Limits{}) {
+		load = append(load, param("max_bytes", l.MaxBytes), param("max_size", fmt.Sprintf("%dx%d", l.MaxWidth, l.MaxHeight)), param("max_pixels", l.MaxPixels))
+	}
+	for _, d := range o.DecodeFallbacks {
+		load = append(load, param("fallback", d))
+	}
+	add("load", load...)
+	if o.MaxMemory > 0 {
+		add("limit_memory", param("max", o.MaxMemory), param("spill", o.SpillToDisc))
+	}
+	add("extract_page", param("slice", o.Slice))
+	if o.CropMargins != (Margins{}) {
+		m := o.CropMargins
+		add("crop_margins", param("top", m.Top), param("right", m.Right), param("bottom", m.Bottom), param("left", m.Left), param("percent", m.Percent))
+	}
+
+	if o.Trim {
+		add("trim", param("background", hexColor(o.TrimBackground)), param("threshold", o.TrimThreshold))
+	}
+	if o.AspectRatio != "" {
+		add("aspect_ratio", param("ratio", o.AspectRatio))
+	}
+	if o.CropRegion != nil {
+		add("crop_region", param("align_mcu", o.AlignMCU))
+	}
+	if o.Stretch != STRETCH_NONE {
+		add("stretch", param("curve", o.Stretch))
+	}
+	add("resize",
+		param("size", fmt.Sprintf("%dx%d", o.Width, o.Height)),
+		param("crop", o.Crop),
+		param("embed", o.Embed),
+		param("enlarge", o.Enlarge),
+		param("interpolator", interpolatorOf(o.Interpolator)))
+	switch {
+	case o.Crop:
+		add("crop", param("gravity", o.Gravity), param("left", o.LeftPos), param("top", o.TopPos), param("align_mcu", o.AlignMCU))
+	case o.Embed:
+		add("embed", param("extend", o.Extend), param("background", hexColor(o.Background)))
+	}
+	if orientationOf(o) != (orientation{}) {
+		add("orient", param("rotate", o.Rotate), param("flip", o.Flip), param("flop", o.Flop), param("by_tag", orientsByTag(o)))
+	}
+	add("colourspace", param("to", "srgb"))
+
+	if math.Mod(o.RotateDegrees, 360) != 0 {
+		add("rotate", param("degrees", o.RotateDegrees), param("background", hexColor(o.Background)))
+	}
+	if o.Affine != (AffineMatrix{}) {
+		add("affine", param("matrix", o.Affine))
+	}
+	if o.Flatten {
+		add("flatten", param("background", hexColor(o.Background)))
+	}
+	if o.Median > 0 {
+		add("median", param("size", o.Median))
+	}
+	if len(o.Convolve.Values) > 0 {
+		add("convolve", param("kernel", fmt.Sprintf("%dx%d", o.Convolve.Width, o.Convolve.Height)), param("scale", o.Convolve.scale()), param("offset", o.Convolve.Offset))
+	}
+	if o.Gamma != 0 || o.Contrast != 0 || o.Brightness != 0 {
+		add("adjust", param("gamma", o.Gamma), param("contrast", o.Contrast), param("brightness", o.Brightness))
+	}
+	if o.Modulate != (Modulation{}) {
+		add("modulate", param("brightness", o.Modulate.Brightness), param("saturation", o.Modulate.Saturation), param("hue", o.Modulate.Hue))
+	}
+	if o.Invert {
+		add("invert")
+	}
+	if o.Tint.A != 0 {
+		add("tint", param("colour", hexColor(o.Tint)))
+	}
+	if o.Grain > 0 {
+		add("grain", param("sigma", o.Grain), param("seed", o.Seed))
+	}
+	if o.Caption != nil {
+		add("caption", param("location", o.Caption.Location), param("gravity", o.Caption.Gravity))
+	}
+	if o.SimulateColourBlindness != COLOURBLIND_NONE {
+		add("simulate_colour_blindness", param("deficiency", o.SimulateColourBlindness))
+	}
+	if o.Watermark != nil {
+		strength := o.Watermark.Strength
+		if strength == 0 {
+			strength = WATERMARK_STRENGTH
+		}
+		add("watermark", param("payload", o.Watermark.Payload), param("strength", strength))
+	}
+	switch {
+	case keepsGrey(o):
+		add("colourspace", param("to", "b-w if the source was grey with alpha"))
+	case o.Colourspace != COLOURSPACE_SRGB:
+		add("colourspace", param("to", o.Colourspace))
+	}
+	if o.BandFormat != FORMAT_DEFAULT {
+		add("cast", param("format", o.BandFormat))
+	}
+
+	f := saverOf(o.Savetype)
+	save := []string{param("format", f.Name)}
+	switch {
+	case o.Savetype == AUTO:
+		formats := o.AutoFormats
+		if len(formats) == 0 {
+			formats = autoFormats
+		}
+		save = []string{param("format", AUTO), param("from", formats)}
+	case autoQuality(f, o):
+		target := o.TargetPSNR
+		if target == 0 {
+			target = DEFAULT_TARGET_PSNR
+		}
+		save = append(save, param("quality", "auto"), param("target_psnr", target))
+	default:
+		d := saveDefaults(o)
+		switch f.Type {
+		case JPEG:
+			save = append(save, param("quality", d.JPEG.Quality), param("interlace", d.JPEG.Interlace), param("keep_metadata", d.JPEG.KeepMetadata))
+		case PNG:
+			save = append(save, param("compression", d.PNG.Compression))
+		case WEBP:
+			save = append(save, param("quality", d.WebP.Quality), param("effort", d.WebP.ReductionEffort))
+		}
+	}
+	add("save", save...)
+	if o.KeepC2PA {
+		add("copy_c2pa")
+	}
+
+	return p
+}
+
+// String lists the steps of p one to a line.
+func (p Plan) String() string {
+	var b strings.Builder
+	for _, op := range p {
+		b.WriteString(op.Name)
+		for _, param := range op.Params {
+			b.WriteString(" " + param)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// DOT renders p as a Graphviz graph, one box per step, for
+// "dot -Tsvg".
+func (p Plan) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph resize {\n\trankdir=LR;\n\tnode [shape=record];\n")
+	for i, op := range p {
+		label := op.Name
+		for _, param := range op.Params {
+			label += `|` + dotEscape(param)
+		}
+		fmt.Fprintf(&b, "\tn%d [label=\"{%s}\"];\n", i, label)
+		if i > 0 {
+			fmt.Fprintf(&b, "\tn%d -> n%d;\n", i-1, i)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotEscape escapes the characters record labels treat specially.
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `{`, `\{`, `}`, `\}`, `|`, `\|`, `<`, `\<`, `>`, `\>`).Replace(s)
+}
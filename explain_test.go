@@ -0,0 +1,36 @@
+package vips
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	p := Explain(Options{Width: 200, Height: 100, Crop: true, Gravity: NORTH, Invert: true, Savetype: PNG})
+
+	var names []string
+	for _, op := range p {
+		names = append(names, op.Name)
+	}
+	got := strings.Join(names, " ")
+	if !strings.HasPrefix(got, "load ") || !strings.Contains(got, "resize crop colourspace") ||
+		!strings.Contains(got, "invert") || !strings.HasSuffix(got, "save") {
+		t.Errorf("Explain() = %s", got)
+	}
+	if strings.Contains(got, "tint") || strings.Contains(got, "embed") {
+		t.Errorf("Explain() lists steps that don't run: %s", got)
+	}
+
+	s := p.String()
+	if !strings.Contains(s, "resize size=200x100 crop=true") || !strings.Contains(s, "save format=png compression=6") {
+		t.Errorf("String() = %s", s)
+	}
+
+	dot := p.DOT()
+	if !strings.HasPrefix(dot, "digraph resize {") || !strings.Contains(dot, "n0 -> n1;") || !strings.Contains(dot, `{save|format=png`) {
+		t.Errorf("DOT() = %s", dot)
+	}
+	if got := dotEscape(`a{b}|"c"`); got != `a\{b\}\|\"c\"` {
+		t.Errorf("dotEscape() = %s", got)
+	}
+}
@@ -0,0 +1,47 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"fmt"
+)
+
+// ExtractArea crops the width x height area at left, top out of buf
+// without any scaling, and encodes it in the format of buf (JPEG for
+// formats that cannot be saved).
+func ExtractArea(buf []byte, left, top, width, height int) (out []byte, err error) {
+	defer audit("extract_area", buf, Options{LeftPos: float32(left), TopPos: float32(top), Width: width, Height: height})(&out, &err)
+
+	release, err := acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	image, typ, err := loadBuffer(buf, DefaultLimits, false)
+	if err != nil {
+		return nil, err
+	}
+
+	inWidth, inHeight := int(image.Xsize), int(image.Ysize)
+	if left < 0 || top < 0 || width <= 0 || height <= 0 || left+width > inWidth || top+height > inHeight {
+		C.g_object_unref(C.gpointer(image))
+		return nil, fmt.Errorf("vips: area %dx%d+%d+%d is outside the %dx%d image", width, height, left, top, inWidth, inHeight)
+	}
+
+	image, err = vipsExtractArea(image, left, top, width, height)
+	if err != nil {
+		return nil, err
+	}
+
+	return saveImage(image, Options{Savetype: typ})
+}
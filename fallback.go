@@ -0,0 +1,96 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import "errors"
+
+// Decoder is a way of decoding a source, for Options.DecodeFallbacks and
+// Result.Decoder.
+type Decoder int
+
+const (
+	// DECODER_NATIVE is the loader of the detected format, strict when
+	// FailOnError is set.
+	DECODER_NATIVE Decoder = iota
+	// DECODER_LENIENT is the loader of the detected format decoding damaged
+	// sources as far as they go, a fallback for FailOnError.
+	DECODER_LENIENT
+	// DECODER_MAGICK is ImageMagick, which opens some files the native
+	// loaders refuse.
+	DECODER_MAGICK
+)
+
+func (d Decoder) String() string {
+	switch d {
+	case DECODER_NATIVE:
+		return "native"
+	case DECODER_LENIENT:
+		return "lenient"
+	case DECODER_MAGICK:
+		return "magick"
+	}
+	return "unknown"
+}
+
+// loadWithFallbacks decodes every pixel of buf with the native loader and,
+// when that fails, with each of o.DecodeFallbacks in turn. Sources over
+// the limits of o are not retried. It returns the decoded image, in
+// memory, and the decoder that succeeded; on failure the error is that of
+// the native loader.
+func loadWithFallbacks(buf []byte, o Options) (*C.struct__VipsImage, ImageType, Decoder, error) {
+	l := limitsOf(o)
+
+	image, typ, err := loadBuffer(buf, l, o.FailOnError)
+	if err == nil {
+		if image, err = decodeToMemory(image); err == nil {
+			return image, typ, DECODER_NATIVE, nil
+		}
+	}
+	if errors.Is(err, ErrLimitExceeded) || errors.Is(err, ErrEmptyBuffer) {
+		return nil, typ, DECODER_NATIVE, err
+	}
+
+	for _, d := range o.DecodeFallbacks {
+		var fallback *C.struct__VipsImage
+		var ferr error
+		switch d {
+		case DECODER_LENIENT:
+			if !o.FailOnError {
+				continue
+			}
+			fallback, _, ferr = loadBuffer(buf, l, false)
+		case DECODER_MAGICK:
+			if fallback, ferr = loadMagickBuffer(skipJunk(buf), o.FailOnError); ferr == nil && !l.allows(int(fallback.Xsize), int(fallback.Ysize)) {
+				C.g_object_unref(C.gpointer(fallback))
+				return nil, typ, d, ErrLimitExceeded
+			}
+		default:
+			continue
+		}
+		if ferr == nil {
+			fallback, ferr = decodeToMemory(fallback)
+		}
+		if ferr == nil {
+			debug("decoded with %v after %v", d, err)
+			return fallback, typ, d, nil
+		}
+		C.vips_error_clear()
+	}
+
+	return nil, typ, DECODER_NATIVE, err
+}
+
+// decodeToMemory decodes every pixel of image, so damage shows now rather
+// than when saving, and releases it.
+func decodeToMemory(image *C.struct__VipsImage) (*C.struct__VipsImage, error) {
+	defer C.g_object_unref(C.gpointer(image))
+
+	memory := C.vips_image_copy_memory(image)
+	if memory == nil {
+		return nil, catchVipsError()
+	}
+	return memory, nil
+}
@@ -0,0 +1,42 @@
+package vips
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestDecodeFallbacks(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := buf[:len(buf)/2]
+
+	r, err := ResizeWithInfo(buf, Options{Width: 100, DecodeFallbacks: []Decoder{DECODER_MAGICK}})
+	if err != nil || r.Decoder != DECODER_NATIVE {
+		t.Errorf("Resize() of a sound JPEG = %v with %v, want native", err, r.Decoder)
+	}
+
+	r, err = ResizeWithInfo(truncated, Options{Width: 100, FailOnError: true, DecodeFallbacks: []Decoder{DECODER_LENIENT}})
+	if err != nil || r.Decoder != DECODER_LENIENT {
+		t.Errorf("Resize() of a truncated JPEG = %v with %v, want lenient", err, r.Decoder)
+	}
+	if r.Width != 100 {
+		t.Errorf("lenient Resize() => %d wide, want 100", r.Width)
+	}
+
+	_, err = ResizeWithInfo(truncated, Options{Width: 100, FailOnError: true, DecodeFallbacks: []Decoder{DECODER_NATIVE}})
+	if err == nil {
+		t.Errorf("Resize() of a truncated JPEG without a lenient fallback did not fail")
+	}
+
+	_, err = ResizeWithInfo(buf, Options{Width: 100, MaxPixels: 10, DecodeFallbacks: []Decoder{DECODER_MAGICK}})
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Resize() over the limits = %v, want %v", err, ErrLimitExceeded)
+	}
+
+	if err := (Options{DecodeFallbacks: []Decoder{7}}).Validate(); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Validate() of an unknown decoder = %v", err)
+	}
+}
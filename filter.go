@@ -0,0 +1,74 @@
+package vips
+
+import (
+	"fmt"
+)
+
+// Kernel is a convolution matrix. Each output pixel is the sum of the
+// Values, row by row, times the pixels under them, divided by Scale and
+// plus Offset.
+type Kernel struct {
+	Width, Height int
+	Values        []float64
+	// Scale defaults to the sum of Values, or 1 when they sum to 0, so
+	// blurs keep brightness and edge detectors are not divided by zero.
+	Scale  float64
+	Offset float64
+}
+
+var (
+	// KERNEL_SHARPEN strengthens edges.
+	KERNEL_SHARPEN = Kernel{Width: 3, Height: 3, Values: []float64{
+		0, -1, 0,
+		-1, 5, -1,
+		0, -1, 0,
+	}}
+	// KERNEL_EDGE finds edges in every direction (Laplacian).
+	KERNEL_EDGE = Kernel{Width: 3, Height: 3, Values: []float64{
+		-1, -1, -1,
+		-1, 8, -1,
+		-1, -1, -1,
+	}}
+	// KERNEL_BOX_BLUR averages each pixel with its neighbours.
+	KERNEL_BOX_BLUR = Kernel{Width: 3, Height: 3, Values: []float64{
+		1, 1, 1,
+		1, 1, 1,
+		1, 1, 1,
+	}}
+)
+
+func (k Kernel) validate() error {
+	if k.Width < 1 || k.Height < 1 || len(k.Values) != k.Width*k.Height {
+		return fmt.Errorf("vips: kernel of %dx%d needs %d values, has %d", k.Width, k.Height, k.Width*k.Height, len(k.Values))
+	}
+	return nil
+}
+
+func (k Kernel) scale() float64 {
+	if k.Scale != 0 {
+		return k.Scale
+	}
+	sum := 0.0
+	for _, v := range k.Values {
+		sum += v
+	}
+	if sum == 0 {
+		return 1
+	}
+	return sum
+}
+
+// Median applies a size x size median filter to buf at its original size,
+// keeping its format where it can be saved.
+func Median(buf []byte, size int) ([]byte, error) {
+	return Resize(buf, Options{Median: size, Savetype: keptType(buf)})
+}
+
+// Convolve filters buf with k at its original size, keeping its format
+// where it can be saved.
+func Convolve(buf []byte, k Kernel) ([]byte, error) {
+	if err := k.validate(); err != nil {
+		return nil, err
+	}
+	return Resize(buf, Options{Convolve: k, Savetype: keptType(buf)})
+}
@@ -0,0 +1,46 @@
+package vips
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestKernel(t *testing.T) {
+	if s := KERNEL_BOX_BLUR.scale(); s != 9 {
+		t.Errorf("box blur scale = %v, want 9", s)
+	}
+	if s := KERNEL_EDGE.scale(); s != 1 {
+		t.Errorf("edge scale = %v, want 1", s)
+	}
+	if err := (Kernel{Width: 2, Height: 2, Values: []float64{1, 2, 3}}).validate(); err == nil {
+		t.Errorf("validate() accepted a kernel with missing values")
+	}
+	if _, err := Convolve([]byte{0xff, 0xd8}, Kernel{Width: 3, Height: 3}); err == nil {
+		t.Errorf("Convolve() accepted an empty kernel")
+	}
+}
+
+func TestMedian(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 9, 9))
+	img.SetGray(4, 4, color.Gray{0xff})
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Median(buf.Bytes(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outImg, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := outImg.At(4, 4).RGBA(); r != 0 {
+		t.Errorf("Median() kept a single white pixel: %v", outImg.At(4, 4))
+	}
+}
@@ -0,0 +1,102 @@
+package vips
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// FINGERPRINT_VERSION is the version of the encoding Fingerprint hashes,
+// raised whenever equal Options would hash differently than before.
+const FINGERPRINT_VERSION = 1
+
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+// funcs numbers the funcs writeCanonical meets, none of which write alike.
+var funcs uint64
+
+// Fingerprint is a hash of o for cache keys and idempotency tokens that
+// holds across releases, prefixed with FINGERPRINT_VERSION as "v1-". Fields
+// are hashed by name, so their order doesn't matter, and those left zero
+// not at all, so fields added later with a zero default don't change it.
+// Enumerations with names are hashed by name, and pointers by what they
+// point to. Funcs can't be compared: closures from the same literal share
+// their code whatever they capture, so Options with a CropRegion fingerprint
+// differently on every call and are never taken for one another.
+func (o Options) Fingerprint() string {
+	sum := sha256.Sum256([]byte(o.canonical()))
+	return fmt.Sprintf("v%d-%s", FINGERPRINT_VERSION, hex.EncodeToString(sum[:]))
+}
+
+// canonical is the text Fingerprint hashes.
+func (o Options) canonical() string {
+	var b strings.Builder
+	writeCanonical(&b, reflect.ValueOf(o))
+	return b.String()
+}
+
+// writeCanonical writes v so that equal values write alike: structs as
+// their exported fields that aren't zero, sorted by name.
+func writeCanonical(b *strings.Builder, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			b.WriteString("nil")
+			return
+		}
+		writeCanonical(b, v.Elem())
+		return
+	}
+
+	if v.Type().Implements(stringerType) {
+		b.WriteString(strconv.Quote(v.Interface().(fmt.Stringer).String()))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		var names []string
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.PkgPath == "" && !isZero(v.Field(i)) {
+				names = append(names, f.Name)
+			}
+		}
+		sort.Strings(names)
+		b.WriteString("{")
+		for _, name := range names {
+			b.WriteString(name + "=")
+			writeCanonical(b, v.FieldByName(name))
+			b.WriteString(";")
+		}
+		b.WriteString("}")
+	case reflect.Slice, reflect.Array:
+		b.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			writeCanonical(b, v.Index(i))
+		}
+		b.WriteString("]")
+	case reflect.Func:
+		fmt.Fprintf(b, "func#%d", atomic.AddUint64(&funcs, 1))
+	case reflect.String:
+		b.WriteString(strconv.Quote(v.String()))
+	default:
+		fmt.Fprintf(b, "%v", v.Interface())
+	}
+}
+
+// isZero reports whether v is zero, taking empty slices for nil ones.
+func isZero(v reflect.Value) bool {
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Map {
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
@@ -0,0 +1,58 @@
+package vips
+
+import (
+	"bytes"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	o := Options{Width: 100, Savetype: PNG, Caption: &Caption{Location: "Lisbon"}}
+	const canonical = `{Caption={Location="Lisbon";};Savetype="png";Width=100;}`
+	if got := o.canonical(); got != canonical {
+		t.Errorf("canonical() = %s, want %s", got, canonical)
+	}
+	// fingerprints must not change across releases
+	const fingerprint = "v1-d2d2bd304186c5de1efc02661271f7a39e7da63e0f92b876d487e692d9278fe9"
+	if got := o.Fingerprint(); got != fingerprint {
+		t.Errorf("Fingerprint() = %s, want %s", got, fingerprint)
+	}
+
+	// pointers, empty slices and unexported fields make no difference
+	same := Options{Width: 100, Savetype: PNG, Caption: &Caption{Location: "Lisbon"}, AutoFormats: []ImageType{}, into: new(bytes.Buffer)}
+	if same.Fingerprint() != fingerprint {
+		t.Errorf("Fingerprint() = %s for equal options", same.Fingerprint())
+	}
+
+	seen := map[string]Options{fingerprint: o}
+	for _, other := range []Options{
+		{},
+		{Width: 200, Savetype: PNG, Caption: &Caption{Location: "Lisbon"}},
+		{Width: 100, Savetype: JPEG, Caption: &Caption{Location: "Lisbon"}},
+		{Width: 100, Savetype: PNG, Caption: &Caption{Location: "Porto"}},
+		{Width: 100, Savetype: PNG},
+		{Width: 100, Savetype: PNG, Caption: &Caption{Location: "Lisbon"}, Watermark: &Watermark{Payload: 1}},
+		{Background: color.RGBA{1, 2, 3, 4}},
+		{Convolve: KERNEL_SHARPEN},
+	} {
+		f := other.Fingerprint()
+		if !strings.HasPrefix(f, "v1-") {
+			t.Errorf("Fingerprint() = %s, want a v1- prefix", f)
+		}
+		if prev, ok := seen[f]; ok {
+			t.Errorf("Fingerprint() collides for %+v and %+v", prev, other)
+		}
+		seen[f] = other
+	}
+
+	// closures over different regions share their code
+	region := func(r Rect) CropRegionProvider {
+		return func(width, height int) (Rect, error) { return r, nil }
+	}
+	a := Options{Width: 100, CropRegion: region(Rect{Width: 10, Height: 10})}
+	b := Options{Width: 100, CropRegion: region(Rect{Left: 50, Width: 10, Height: 10})}
+	if f := a.Fingerprint(); f == b.Fingerprint() || f == a.Fingerprint() {
+		t.Errorf("Fingerprint() repeats for options with a CropRegion")
+	}
+}
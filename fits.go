@@ -0,0 +1,89 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"bytes"
+)
+
+// FITS is the type of FITS files, the format of telescope and other
+// scientific data. It needs libvips built with cfitsio.
+var FITS ImageType
+
+// Stretch maps data of any range, such as FITS counts or 16 bit scans,
+// onto 0-255 for display. The minimum of the image becomes black and the
+// maximum white.
+type Stretch int
+
+const (
+	// STRETCH_NONE leaves values alone; out of range ones are clipped when
+	// saved as 8 bit.
+	STRETCH_NONE Stretch = iota
+	STRETCH_LINEAR
+	// STRETCH_LOG and STRETCH_SQRT brighten faint detail, as astronomy
+	// viewers do.
+	STRETCH_LOG
+	STRETCH_SQRT
+)
+
+// BandFormat is the numeric format of pixel values.
+type BandFormat int
+
+const (
+	// FORMAT_DEFAULT keeps the format the pipeline produced.
+	FORMAT_DEFAULT BandFormat = iota
+	FORMAT_UCHAR
+	FORMAT_USHORT
+	FORMAT_SHORT
+	FORMAT_UINT
+	FORMAT_INT
+	FORMAT_FLOAT
+	FORMAT_DOUBLE
+)
+
+var bandFormats = map[BandFormat]C.VipsBandFormat{
+	FORMAT_UCHAR:  C.VIPS_FORMAT_UCHAR,
+	FORMAT_USHORT: C.VIPS_FORMAT_USHORT,
+	FORMAT_SHORT:  C.VIPS_FORMAT_SHORT,
+	FORMAT_UINT:   C.VIPS_FORMAT_UINT,
+	FORMAT_INT:    C.VIPS_FORMAT_INT,
+	FORMAT_FLOAT:  C.VIPS_FORMAT_FLOAT,
+	FORMAT_DOUBLE: C.VIPS_FORMAT_DOUBLE,
+}
+
+// loadFITSBuffer decodes buf through a temporary file, as libvips only
+// reads and writes FITS files.
+func loadFITSBuffer(buf []byte, fail bool) (*C.struct__VipsImage, error) {
+	return loadViaFile(buf, "vips-*.fits", vipsFITSLoad)
+}
+
+func saveFITSBuffer(image *C.struct__VipsImage, o Options) ([]byte, error) {
+	return saveViaFile("vips-*.fits", func(path string) error {
+		return vipsFITSSave(image, path)
+	})
+}
+
+func saveFITSFile(image *C.struct__VipsImage, path string, o Options) error {
+	return vipsFITSSave(image, path)
+}
+
+func init() {
+	FITS, _ = registerFormat(&Format{
+		Name:       "fits",
+		MIME:       "image/fits",
+		Extensions: []string{".fits", ".fit", ".fts"},
+		Match: func(buf []byte) bool {
+			return bytes.HasPrefix(buf, []byte("SIMPLE  ="))
+		},
+		Alpha:    true,
+		lossless: true,
+		loadOp:   "fitsload",
+		saveOp:   "fitssave",
+		load:     loadFITSBuffer,
+		save:     saveFITSBuffer,
+		saveFile: saveFITSFile,
+	})
+}
@@ -0,0 +1,62 @@
+package vips
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestStretch(t *testing.T) {
+	img := image.NewGray16(image.Rect(0, 0, 10, 1))
+	for x := 0; x < 10; x++ {
+		img.SetGray16(x, 0, color.Gray16{uint16(1000 + 100*x)})
+	}
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Resize(buf.Bytes(), Options{Stretch: STRETCH_LINEAR, Savetype: PNG})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outImg, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _, _, _ := outImg.At(0, 0).RGBA()
+	last, _, _, _ := outImg.At(9, 0).RGBA()
+	if first>>8 != 0 || last>>8 != 0xff {
+		t.Errorf("Resize(STRETCH_LINEAR) => %d..%d, want 0..255", first>>8, last>>8)
+	}
+}
+
+func TestFITS(t *testing.T) {
+	if FITS.String() != "fits" || TypeOfExt(".fits") != FITS || detectType([]byte("SIMPLE  =                    T")) != FITS {
+		t.Errorf("FITS format not registered: %v", FITS)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, image.NewGray(image.Rect(0, 0, 8, 4))); err != nil {
+		t.Fatal(err)
+	}
+
+	fits, err := Resize(buf.Bytes(), Options{Savetype: FITS, BandFormat: FORMAT_FLOAT})
+	if err != nil {
+		t.Skip("no FITS support in this libvips:", err)
+	}
+	if detectType(fits) != FITS {
+		t.Fatalf("Resize(FITS) wrote %v", detectType(fits))
+	}
+
+	r, err := ResizeWithInfo(fits, Options{Stretch: STRETCH_LOG, Savetype: PNG})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Width != 8 || r.Height != 4 {
+		t.Errorf("FITS round trip => %dx%d, want 8x4", r.Width, r.Height)
+	}
+}
@@ -0,0 +1,429 @@
+package vips
+
+/*
+#include <stdlib.h>
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// Format describes how an image format is recognised, loaded and saved.
+// JPEG, PNG, WebP and BMP are built in; RegisterFormat adds others libvips
+// was built with, such as TIFF or HEIF, without touching Resize.
+type Format struct {
+	// Type is assigned by RegisterFormat.
+	Type ImageType
+	// Name is the lower case name ImageType.String returns.
+	Name string
+	// MIME is the media type, such as "image/png".
+	MIME string
+	// Extensions are the file name extensions, dot included, ResizeFile
+	// infers the output format from. The first is the preferred one.
+	Extensions []string
+	// Match reports whether buf starts with the magic bytes of the format.
+	// Formats without one are never detected, but can still be saved.
+	Match func(buf []byte) bool
+	// Suffix picks the libvips saver for the format, like ".tif", and may
+	// carry saver options in brackets, like ".tif[compression=lzw]".
+	// Registered formats are loaded by libvips' own detection.
+	Suffix string
+	// Alpha reports whether the format can save transparency.
+	Alpha bool
+
+	// loadOp and saveOp name the libvips operations the format needs,
+	// looked up when libvips starts; "" needs none. Formats registered by
+	// suffix are checked for a saver of it.
+	loadOp, saveOp string
+	// canLoad and canSave are what the lookup found.
+	canLoad, canSave bool
+	// lossless formats make poor photos, being many times larger.
+	lossless bool
+
+	load     func(buf []byte, fail bool) (*C.struct__VipsImage, error)
+	save     func(image *C.struct__VipsImage, o Options) ([]byte, error)
+	saveFile func(image *C.struct__VipsImage, path string, o Options) error
+}
+
+var (
+	formatsMu sync.RWMutex
+	// formats in the order they are matched
+	formats = []*Format{
+		{
+			Type:       JPEG,
+			Name:       "jpeg",
+			MIME:       "image/jpeg",
+			Extensions: []string{".jpg", ".jpeg"},
+			Match: func(buf []byte) bool {
+				return len(buf) >= 2 && bytes.Equal(buf[:2], MARKER_JPEG)
+			},
+			loadOp:   "jpegload_buffer",
+			saveOp:   "jpegsave_buffer",
+			load:     loadJpegBuffer,
+			save:     saveJpegBuffer,
+			saveFile: saveJpegFile,
+		},
+		{
+			Type:       PNG,
+			Name:       "png",
+			MIME:       "image/png",
+			Extensions: []string{".png"},
+			Match: func(buf []byte) bool {
+				return len(buf) >= 2 && bytes.Equal(buf[:2], MARKER_PNG)
+			},
+			Alpha:    true,
+			lossless: true,
+			loadOp:   "pngload_buffer",
+			saveOp:   "pngsave_buffer",
+			load:     loadPngBuffer,
+			save:     savePngBuffer,
+			saveFile: savePngFile,
+		},
+		{
+			Type:       WEBP,
+			Name:       "webp",
+			MIME:       "image/webp",
+			Extensions: []string{".webp"},
+			Match: func(buf []byte) bool {
+				return len(buf) >= 12 && bytes.Equal(buf[:4], MARKER_RIFF) && bytes.Equal(buf[8:12], MARKER_WEBP)
+			},
+			Alpha:    true,
+			loadOp:   "webpload_buffer",
+			saveOp:   "webpsave_buffer",
+			load:     loadWebpBuffer,
+			save:     saveWebpBuffer,
+			saveFile: saveWebpFile,
+		},
+		{
+			Type:       BMP,
+			Name:       "bmp",
+			MIME:       "image/bmp",
+			Extensions: []string{".bmp"},
+			Match:      matchBmp,
+			loadOp:     "magickload_buffer",
+			load:       loadBmpBuffer,
+		},
+	}
+)
+
+// bmpHeaderSizes are the sizes of the DIB headers following the 14 byte
+// file header, from BITMAPCOREHEADER to BITMAPV5HEADER.
+var bmpHeaderSizes = map[uint32]bool{12: true, 40: true, 52: true, 56: true, 64: true, 108: true, 124: true}
+
+// matchBmp checks the DIB header size too, as "BM" alone starts plenty of
+// text.
+func matchBmp(buf []byte) bool {
+	return len(buf) >= 18 && bytes.Equal(buf[:2], MARKER_BMP) && bmpHeaderSizes[binary.LittleEndian.Uint32(buf[14:18])]
+}
+
+// RegisterFormat adds f to the formats detected, loaded and saved, after
+// those already registered, and returns the ImageType to select it with in
+// Options.Savetype.
+func RegisterFormat(f Format) (ImageType, error) {
+	if f.Name == "" || f.Suffix == "" {
+		return UNKNOWN, errors.New("vips: format needs a name and a suffix")
+	}
+
+	suffix := f.Suffix
+	f.load = loadBufferAuto
+	f.save = func(image *C.struct__VipsImage, o Options) ([]byte, error) {
+		return saveBufferSuffix(image, suffix)
+	}
+	f.saveFile = func(image *C.struct__VipsImage, path string, o Options) error {
+		return saveFileSuffix(image, path, suffix)
+	}
+	return registerFormat(&f)
+}
+
+// registerFormat appends f with the next free type. Formats without save
+// functions can only be loaded.
+func registerFormat(f *Format) (ImageType, error) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	next := UNKNOWN
+	for _, g := range formats {
+		if g.Name == f.Name {
+			return UNKNOWN, errors.New("vips: format " + f.Name + " already registered")
+		}
+		if g.Type > next {
+			next = g.Type
+		}
+	}
+
+	f.Type = next + 1
+	if formatsDetected {
+		f.detect()
+	}
+	formats = append(formats, f)
+
+	return f.Type, nil
+}
+
+// Formats returns the registered formats in the order they are matched.
+func Formats() []Format {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	list := make([]Format, len(formats))
+	for i, f := range formats {
+		list[i] = *f
+	}
+	return list
+}
+
+// maxJunk bounds how far into a buffer an image may start.
+const maxJunk = 64
+
+// matchType finds the registered format whose magic bytes start buf.
+func matchType(buf []byte) ImageType {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	return matchTypeLocked(buf)
+}
+
+func matchTypeLocked(buf []byte) ImageType {
+	for _, f := range formats {
+		if f.Match != nil && f.Match(buf) {
+			return f.Type
+		}
+	}
+	return UNKNOWN
+}
+
+// skipJunk returns buf from where a registered format first matches within
+// maxJunk bytes, past a byte order mark or the few junk bytes some cameras
+// and upload tools put before the image. Unmatched buffers are returned
+// unchanged.
+func skipJunk(buf []byte) []byte {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	for i := 0; i < len(buf) && i <= maxJunk; i++ {
+		if matchTypeLocked(buf[i:]) != UNKNOWN {
+			return buf[i:]
+		}
+	}
+	return buf
+}
+
+func formatOf(t ImageType) (*Format, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	for _, f := range formats {
+		if f.Type == t {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// saverOf is the format to save t as, falling back to JPEG for unknown,
+// load-only and unavailable formats.
+func saverOf(t ImageType) *Format {
+	if f, ok := formatOf(t); ok && f.canSave {
+		return f
+	}
+	f, _ := formatOf(JPEG)
+	return f
+}
+
+// keptType is the format of buf for operations keeping it, or UNKNOWN,
+// saving as JPEG, when that format can't be saved.
+func keptType(buf []byte) ImageType {
+	t := detectType(buf)
+	if f, ok := formatOf(t); ok && f.canSave {
+		return t
+	}
+	return UNKNOWN
+}
+
+// MIME returns the media type of t, or "" when t is not a registered
+// format.
+func (t ImageType) MIME() string {
+	if f, ok := formatOf(t); ok {
+		return f.MIME
+	}
+	return ""
+}
+
+// Ext returns the preferred file name extension of t, dot included, or ""
+// when t is not a registered format.
+func (t ImageType) Ext() string {
+	if f, ok := formatOf(t); ok && len(f.Extensions) > 0 {
+		return f.Extensions[0]
+	}
+	return ""
+}
+
+// TypeOfMIME finds the format with media type mime, ignoring case and
+// parameters, or UNKNOWN when there is none.
+func TypeOfMIME(mime string) ImageType {
+	if i := strings.IndexByte(mime, ';'); i >= 0 {
+		mime = mime[:i]
+	}
+	mime = strings.ToLower(strings.TrimSpace(mime))
+
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	for _, f := range formats {
+		if f.MIME != "" && f.MIME == mime {
+			return f.Type
+		}
+	}
+	return UNKNOWN
+}
+
+// TypeOfExt finds the format using a file name extension such as ".png",
+// with or without the dot and in any case, or UNKNOWN when there is none.
+func TypeOfExt(ext string) ImageType {
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	for _, f := range formats {
+		for _, e := range f.Extensions {
+			if e == ext {
+				return f.Type
+			}
+		}
+	}
+	return UNKNOWN
+}
+
+// TypeOf finds the format of an encoded image by its leading bytes, or
+// UNKNOWN when no registered format matches.
+func TypeOf(buf []byte) ImageType {
+	return detectType(buf)
+}
+
+// loadViaFile loads buf with load, for formats libvips only reads from
+// files, through a temporary file named after pattern.
+func loadViaFile(buf []byte, pattern string, load func(path string) (*C.struct__VipsImage, error)) (*C.struct__VipsImage, error) {
+	f, err := ioutil.TempFile("", pattern)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(buf)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return load(f.Name())
+}
+
+// saveViaFile returns what save writes to a temporary file named after
+// pattern, for formats libvips only writes to files.
+func saveViaFile(pattern string, save func(path string) error) ([]byte, error) {
+	f, err := ioutil.TempFile("", pattern)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	if err := save(f.Name()); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(f.Name())
+}
+
+// formatsDetected is set once libvips started and the formats were looked
+// up in it.
+var formatsDetected bool
+
+// detectFormats looks up the operations of every format in libvips.
+func detectFormats() {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	for _, f := range formats {
+		f.detect()
+	}
+	formatsDetected = true
+}
+
+func (f *Format) detect() {
+	f.canLoad = f.load != nil && (f.loadOp == "" || hasOperation(f.loadOp))
+	f.canSave = f.save != nil
+	switch {
+	case f.saveOp != "":
+		f.canSave = f.canSave && hasOperation(f.saveOp)
+	case f.Suffix != "":
+		f.canSave = f.canSave && hasSaver(f.Suffix)
+	}
+}
+
+// HasFormat reports whether t is registered and the linked libvips can
+// load or save it.
+func HasFormat(t ImageType) bool {
+	f, ok := formatOf(t)
+	return ok && (f.canLoad || f.canSave)
+}
+
+// SupportedLoaders returns the formats the linked libvips can load, so
+// applications can advertise what uploads they accept.
+func SupportedLoaders() []ImageType {
+	return supported(func(f *Format) bool { return f.canLoad })
+}
+
+// SupportedSavers returns the formats the linked libvips can save.
+func SupportedSavers() []ImageType {
+	return supported(func(f *Format) bool { return f.canSave })
+}
+
+func supported(can func(f *Format) bool) []ImageType {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	var types []ImageType
+	for _, f := range formats {
+		if can(f) {
+			types = append(types, f.Type)
+		}
+	}
+	return types
+}
+
+// hasOperation reports whether the linked libvips has the operation
+// nickname, such as "jxlload_buffer".
+func hasOperation(nickname string) bool {
+	cbase := C.CString("VipsOperation")
+	defer C.free(unsafe.Pointer(cbase))
+	cname := C.CString(nickname)
+	defer C.free(unsafe.Pointer(cname))
+
+	return C.vips_type_find(cbase, cname) != 0
+}
+
+// hasSaver reports whether the linked libvips can save to a buffer as
+// suffix, such as ".tif".
+func hasSaver(suffix string) bool {
+	csuffix := C.CString(suffix)
+	defer C.free(unsafe.Pointer(csuffix))
+
+	found := C.vips_foreign_find_save_buffer(csuffix) != nil
+	C.vips_error_clear()
+	return found
+}
@@ -0,0 +1,254 @@
+package vips
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestBuiltinFormats(t *testing.T) {
+	cases := []struct {
+		buf  []byte
+		want ImageType
+	}{
+		{[]byte{0xff, 0xd8, 0xff}, JPEG},
+		{[]byte{0x89, 0x50, 0x4e, 0x47}, PNG},
+		{[]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), WEBP},
+		{[]byte("RIFF\x00\x00\x00\x00WAVE"), UNKNOWN},
+		{nil, UNKNOWN},
+	}
+	for _, c := range cases {
+		if got := detectType(c.buf); got != c.want {
+			t.Errorf("detectType(%q) = %v, want %v", c.buf, got, c.want)
+		}
+	}
+
+	if got := TypeOfExt(".JPEG"); got != JPEG {
+		t.Errorf("TypeOfExt(.JPEG) = %v, want jpeg", got)
+	}
+	if got := WEBP.String(); got != "webp" {
+		t.Errorf("WEBP.String() = %q", got)
+	}
+}
+
+func TestRegisterFormat(t *testing.T) {
+	magic := []byte("II*\x00")
+	typ, err := RegisterFormat(Format{
+		Name:       "tiff-test",
+		Extensions: []string{".tif-test"},
+		Match:      func(buf []byte) bool { return bytes.HasPrefix(buf, magic) },
+		Suffix:     ".tif",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		formatsMu.Lock()
+		formats = formats[:len(formats)-1]
+		formatsMu.Unlock()
+	}()
+
+	if typ <= WEBP {
+		t.Errorf("RegisterFormat() = %d, want a new type", typ)
+	}
+	if got := detectType(append(magic, 0)); got != typ {
+		t.Errorf("detectType() = %v, want %v", got, typ)
+	}
+	if got := TypeOfExt(".tif-test"); got != typ {
+		t.Errorf("TypeOfExt() = %v, want %v", got, typ)
+	}
+	if typ.String() != "tiff-test" {
+		t.Errorf("String() = %q", typ.String())
+	}
+
+	if _, err := RegisterFormat(Format{Name: "tiff-test", Suffix: ".tif"}); err == nil {
+		t.Errorf("RegisterFormat() accepted a duplicate name")
+	}
+	if _, err := RegisterFormat(Format{Name: "nosuffix"}); err == nil {
+		t.Errorf("RegisterFormat() accepted a format without a suffix")
+	}
+}
+
+func TestMIME(t *testing.T) {
+	cases := []struct {
+		typ       ImageType
+		mime, ext string
+	}{
+		{JPEG, "image/jpeg", ".jpg"},
+		{PNG, "image/png", ".png"},
+		{WEBP, "image/webp", ".webp"},
+		{UNKNOWN, "", ""},
+	}
+	for _, c := range cases {
+		if got := c.typ.MIME(); got != c.mime {
+			t.Errorf("%v.MIME() = %q, want %q", c.typ, got, c.mime)
+		}
+		if got := c.typ.Ext(); got != c.ext {
+			t.Errorf("%v.Ext() = %q, want %q", c.typ, got, c.ext)
+		}
+	}
+
+	if got := TypeOfMIME("Image/WebP; q=0.9"); got != WEBP {
+		t.Errorf("TypeOfMIME() = %v, want webp", got)
+	}
+	if got := TypeOfMIME("image/gif"); got != UNKNOWN {
+		t.Errorf("TypeOfMIME(image/gif) = %v, want unknown", got)
+	}
+	if got := TypeOfExt("jpeg"); got != JPEG {
+		t.Errorf("TypeOfExt(jpeg) = %v, want jpeg", got)
+	}
+}
+
+func TestDetectTypeJunk(t *testing.T) {
+	jpeg := []byte{0xff, 0xd8, 0xff, 0xe0}
+	cases := []struct {
+		buf  []byte
+		want ImageType
+	}{
+		{append([]byte{0xef, 0xbb, 0xbf}, jpeg...), JPEG},
+		{append(make([]byte, 10), jpeg...), JPEG},
+		{append(make([]byte, maxJunk+1), jpeg...), UNKNOWN},
+		{[]byte("<html></html>"), UNKNOWN},
+	}
+	for _, c := range cases {
+		if got := detectType(c.buf); got != c.want {
+			t.Errorf("detectType(% x) = %v, want %v", c.buf, got, c.want)
+		}
+	}
+
+	if got := skipJunk(append([]byte{1, 2}, jpeg...)); !bytes.Equal(got, jpeg) {
+		t.Errorf("skipJunk() = % x, want % x", got, jpeg)
+	}
+}
+
+func TestDetectTypeSniffed(t *testing.T) {
+	typ, err := RegisterFormat(Format{Name: "gif-test", MIME: "image/gif", Suffix: ".gif"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		formatsMu.Lock()
+		formats = formats[:len(formats)-1]
+		formatsMu.Unlock()
+	}()
+
+	if got := detectType([]byte("GIF89a\x01\x00\x01\x00")); got != typ {
+		t.Errorf("detectType() of a GIF = %v, want %v from content sniffing", got, typ)
+	}
+}
+
+// bmp24 is a width x height 24 bit BMP of one colour.
+func bmp24(width, height int, r, g, b byte) []byte {
+	stride := (width*3 + 3) &^ 3
+	buf := make([]byte, 54+stride*height)
+	le := binary.LittleEndian
+	copy(buf, MARKER_BMP)
+	le.PutUint32(buf[2:], uint32(len(buf)))
+	le.PutUint32(buf[10:], 54)
+	le.PutUint32(buf[14:], 40)
+	le.PutUint32(buf[18:], uint32(width))
+	le.PutUint32(buf[22:], uint32(height))
+	le.PutUint16(buf[26:], 1)
+	le.PutUint16(buf[28:], 24)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			copy(buf[54+y*stride+3*x:], []byte{b, g, r})
+		}
+	}
+	return buf
+}
+
+func TestBMP(t *testing.T) {
+	buf := bmp24(8, 4, 255, 0, 0)
+	if detectType(buf) != BMP || TypeOfExt(".bmp") != BMP || BMP.MIME() != "image/bmp" {
+		t.Errorf("BMP not detected: %v", detectType(buf))
+	}
+	if matchBmp([]byte("BMW owners club")) {
+		t.Errorf("matchBmp() matched text")
+	}
+	if err := (Options{Savetype: BMP}).Validate(); !errors.Is(err, ErrUnsupportedSaveType) {
+		t.Errorf("BMP accepted as a save type: %v", err)
+	}
+
+	r, err := ResizeWithInfo(buf, Options{Width: 4, Savetype: PNG})
+	if err != nil {
+		t.Skip("no ImageMagick support in this libvips:", err)
+	}
+	if r.Width != 4 || r.Height != 2 {
+		t.Errorf("Resize() of a BMP => %dx%d, want 4x2", r.Width, r.Height)
+	}
+}
+
+func TestJP2KAndJXL(t *testing.T) {
+	cases := []struct {
+		buf  []byte
+		want ImageType
+	}{
+		{append(MARKER_JP2, 0, 0), JP2K},
+		{append(MARKER_J2K, 0, 0), JP2K},
+		{append(MARKER_JXL, 0, 0), JXL},
+		{append(MARKER_JXL_CONTAINER, 0, 0), JXL},
+	}
+	for _, c := range cases {
+		if got := detectType(c.buf); got != c.want {
+			t.Errorf("detectType(% x) = %v, want %v", c.buf, got, c.want)
+		}
+	}
+
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, typ := range []ImageType{JP2K, JXL} {
+		f, _ := formatOf(typ)
+		if !f.canSave {
+			if _, err := Resize(buf, Options{Width: 50, Savetype: typ}); !errors.Is(err, ErrUnsupportedSaveType) {
+				t.Errorf("Resize() to unavailable %v = %v, want %v", typ, err, ErrUnsupportedSaveType)
+			}
+			continue
+		}
+
+		out, err := Resize(buf, Options{Width: 50, Quality: 80, Savetype: typ})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if detectType(out) != typ {
+			t.Errorf("Resize(%v) wrote %v", typ, detectType(out))
+		}
+		if typ == JP2K && !bytes.HasPrefix(out, MARKER_JP2) {
+			t.Errorf("Resize(%v) wrote a bare codestream, want a JP2 file", typ)
+		}
+		if r, err := ResizeWithInfo(out, Options{Savetype: PNG}); err != nil || r.Width != 50 {
+			t.Errorf("%v round trip => %d wide, %v", typ, r.Width, err)
+		}
+	}
+}
+
+func TestSupportedFormats(t *testing.T) {
+	has := func(types []ImageType, t ImageType) bool {
+		for _, u := range types {
+			if u == t {
+				return true
+			}
+		}
+		return false
+	}
+
+	loaders, savers := SupportedLoaders(), SupportedSavers()
+	for _, typ := range []ImageType{JPEG, PNG, WEBP} {
+		if !has(loaders, typ) || !has(savers, typ) || !HasFormat(typ) {
+			t.Errorf("%v missing from loaders %v or savers %v", typ, loaders, savers)
+		}
+	}
+	if has(savers, BMP) || has(savers, NIFTI) {
+		t.Errorf("load-only formats among savers %v", savers)
+	}
+	if HasFormat(UNKNOWN) || HasFormat(ImageType(1000)) {
+		t.Errorf("HasFormat() reported unregistered formats")
+	}
+	if HasFormat(JXL) != hasOperation("jxlload_buffer") {
+		t.Errorf("HasFormat(JXL) = %v, libvips disagrees", HasFormat(JXL))
+	}
+}
@@ -0,0 +1,98 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// FromImage copies img, from the standard library or packages drawing on
+// it, into an Image. Gray images stay one band of grey; others become
+// 8-bit sRGB with alpha, which the image/color models of 16 bits lose.
+func FromImage(img image.Image) (*Image, error) {
+	b := img.Bounds()
+	if b.Empty() {
+		return nil, fmt.Errorf("%w: empty image %v", ErrInvalidDimensions, b)
+	}
+
+	var (
+		pixels         []byte
+		bands          int
+		interpretation C.VipsInterpretation
+	)
+	switch m := img.(type) {
+	case *image.Gray:
+		pixels, bands, interpretation = packed(m.Pix, m.Stride, b.Dx(), b.Dy()), 1, C.VIPS_INTERPRETATION_B_W
+	case *image.NRGBA:
+		pixels, bands, interpretation = packed(m.Pix, m.Stride, 4*b.Dx(), b.Dy()), 4, C.VIPS_INTERPRETATION_sRGB
+	default:
+		n := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(n, n.Bounds(), img, b.Min, draw.Src)
+		pixels, bands, interpretation = n.Pix, 4, C.VIPS_INTERPRETATION_sRGB
+	}
+
+	release, err := acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	out, err := vipsFromPixels(pixels, b.Dx(), b.Dy(), bands, FORMAT_UCHAR, interpretation)
+	if err != nil {
+		return nil, err
+	}
+	return newImage(out, sourceOf(out), UNKNOWN), nil
+}
+
+// packed is the rows of height rows of width bytes, stride apart from
+// the start of pix, without the gaps between them.
+func packed(pix []byte, stride, width, height int) []byte {
+	if stride == width {
+		return pix[:width*height]
+	}
+	out := make([]byte, 0, width*height)
+	for y := 0; y < height; y++ {
+		out = append(out, pix[y*stride:y*stride+width]...)
+	}
+	return out
+}
+
+// ToImage copies the pixels of i into an 8-bit sRGB image with alpha, for
+// the standard library and packages drawing on it.
+func (i *Image) ToImage() (image.Image, error) {
+	release, err := acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	ref, err := i.ref()
+	if err != nil {
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(ref))
+
+	pixels, err := vipsRGBA(ref)
+	if err != nil {
+		return nil, err
+	}
+	width, height := int(ref.Xsize), int(ref.Ysize)
+	return &image.NRGBA{Pix: pixels, Stride: 4 * width, Rect: image.Rect(0, 0, width, height)}, nil
+}
@@ -0,0 +1,51 @@
+package vips
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFromImage(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			src.Set(x, y, color.NRGBA{uint8(x * 6), uint8(y * 8), 100, 200})
+		}
+	}
+
+	// a sub-image, with rows further apart than its width
+	for _, img := range []image.Image{src, src.SubImage(image.Rect(10, 5, 30, 25)), image.NewGray(image.Rect(0, 0, 8, 8))} {
+		i, err := FromImage(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := img.Bounds()
+		if i.Width() != b.Dx() || i.Height() != b.Dy() {
+			t.Errorf("FromImage() => %dx%d, want %dx%d", i.Width(), i.Height(), b.Dx(), b.Dy())
+		}
+
+		out, err := i.ToImage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for y := 0; y < b.Dy(); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				want := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y))
+				if got := out.At(x, y); got != want {
+					t.Fatalf("ToImage() at %d, %d = %v, want %v", x, y, got, want)
+				}
+			}
+		}
+		i.Close()
+	}
+
+	i, err := FromImage(image.NewRGBA(image.Rect(0, 0, 64, 64)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer i.Close()
+	if r, err := i.Resize(Options{Width: 32, Savetype: PNG}); err != nil || r.Width != 32 {
+		t.Errorf("Resize() of a FromImage() = %d wide, %v", r.Width, err)
+	}
+}
@@ -0,0 +1,22 @@
+package vips
+
+import (
+	"math/rand"
+)
+
+// GRAIN_TILE is the size of the square of noise Options.Grain repeats
+// across the image.
+const GRAIN_TILE = 256
+
+// grainTile is GRAIN_TILE x GRAIN_TILE of gaussian noise of deviation
+// sigma from seed. It is generated here rather than by libvips, so a seed
+// gives the same noise whatever version libvips is and however it splits
+// the image between threads.
+func grainTile(seed int64, sigma float64) []float64 {
+	r := rand.New(rand.NewSource(seed))
+	noise := make([]float64, GRAIN_TILE*GRAIN_TILE)
+	for i := range noise {
+		noise[i] = r.NormFloat64() * sigma
+	}
+	return noise
+}
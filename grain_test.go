@@ -0,0 +1,44 @@
+package vips
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestGrainTile(t *testing.T) {
+	a, b := grainTile(1, 8), grainTile(1, 8)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("grainTile() differs at %d with the same seed", i)
+		}
+	}
+	if c := grainTile(2, 8); c[0] == a[0] && c[1] == a[1] {
+		t.Errorf("grainTile() is the same with another seed")
+	}
+}
+
+func TestGrain(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resize := func(seed int64) []byte {
+		out, err := Resize(buf, Options{Width: 300, Grain: 10, Seed: seed})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	if !bytes.Equal(resize(7), resize(7)) {
+		t.Errorf("Resize() with the same seed differs")
+	}
+	if bytes.Equal(resize(7), resize(8)) {
+		t.Errorf("Resize() with another seed is the same")
+	}
+	if err := (Options{Grain: -1}).Validate(); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Validate() of negative grain = %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+// isGreyAlpha reports whether image is a single band of grey with alpha,
+// such as a grayscale sticker.
+func isGreyAlpha(image *C.struct__VipsImage) bool {
+	return image.Bands == 2 && (image.Type == C.VIPS_INTERPRETATION_B_W || image.Type == C.VIPS_INTERPRETATION_GREY16)
+}
+
+// keepsGrey reports whether the steps of o leave a grey image grey and its
+// output format keeps alpha, so it can be saved as grey with alpha instead
+// of being expanded to RGBA.
+func keepsGrey(o Options) bool {
+	bg := o.Background
+	return o.Colourspace == COLOURSPACE_SRGB && o.Tint.A == 0 && o.Caption == nil &&
+		bg.R == bg.G && bg.G == bg.B && saverOf(o.Savetype).Alpha
+}
@@ -0,0 +1,54 @@
+package vips
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestGreyAlpha(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			src.Set(x, y, color.NRGBA{uint8(x * 6), uint8(x * 6), uint8(x * 6), uint8(y * 6)})
+		}
+	}
+	rgba := new(bytes.Buffer)
+	if err := png.Encode(rgba, src); err != nil {
+		t.Fatal(err)
+	}
+
+	ga, err := ResizeWithInfo(rgba.Bytes(), Options{Width: 40, Colourspace: COLOURSPACE_B_W, Savetype: PNG})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ga.Channels != 2 {
+		t.Fatalf("grey source has %d channels, want 2", ga.Channels)
+	}
+
+	cases := []struct {
+		o        Options
+		channels int
+	}{
+		{Options{Width: 20, Savetype: PNG}, 2},
+		{Options{Width: 20, Savetype: WEBP}, 2},
+		{Options{Width: 20, Savetype: PNG, Tint: color.RGBA{255, 0, 0, 255}}, 4},
+		{Options{Width: 20, Savetype: PNG, Flatten: true}, 3},
+		{Options{Width: 20, Savetype: JPEG}, 3},
+	}
+	for _, c := range cases {
+		r, err := ResizeWithInfo(ga.Buf, c.o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Channels != c.channels {
+			t.Errorf("Resize(%+v) of grey with alpha => %d channels, want %d", c.o, r.Channels, c.channels)
+		}
+	}
+
+	if rgb, _ := ResizeWithInfo(rgba.Bytes(), Options{Width: 20, Savetype: PNG}); rgb.Channels != 4 {
+		t.Errorf("Resize() of RGBA => %d channels, want 4", rgb.Channels)
+	}
+}
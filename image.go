@@ -0,0 +1,195 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrClosed is returned by operations on a closed Image.
+var ErrClosed = errors.New("vips: image closed")
+
+// Image is a source decoded once and held by libvips, so several results
+// can be made from it without decoding it again. Close it when done, and
+// before Shutdown. Images forgotten are freed by the garbage collector,
+// but only when it next runs, which the pixels held outside the Go heap do
+// nothing to bring about; SetLeakDetection logs them.
+type Image struct {
+	mu    sync.Mutex
+	image *C.struct__VipsImage
+	src   source
+	typ   ImageType
+	// stack is where the Image was made, with leak detection on.
+	stack []byte
+}
+
+// newImage wraps image, taking over its reference.
+func newImage(image *C.struct__VipsImage, src source, typ ImageType) *Image {
+	i := &Image{image: image, src: src, typ: typ}
+	if atomic.LoadInt32(&reportLeaks) != 0 {
+		i.stack = make([]byte, 4096)
+		i.stack = i.stack[:runtime.Stack(i.stack, false)]
+	}
+	runtime.SetFinalizer(i, (*Image).finalize)
+	return i
+}
+
+// Load decodes buf into an Image, within the limits, MaxMemory and
+// MaxCost megapixels of o and following its FailOnError. The pixels are
+// decoded into memory, or to disc when MaxMemory and SpillToDisc say so.
+func Load(buf []byte, o Options) (*Image, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	release, err := acquireAt(o.Priority)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	image, typ, err := loadBuffer(buf, limitsOf(o), o.FailOnError)
+	if err != nil {
+		return nil, err
+	}
+	src := sourceOf(image)
+	// only the decoding is paid for here
+	if err = checkCost(image, src, Options{MaxCost: Cost{Megapixels: o.MaxCost.Megapixels}}); err != nil {
+		return nil, err
+	}
+	image, spilled, err := limitMemory(image, o)
+	if err != nil {
+		return nil, err
+	}
+	if !spilled {
+		// loaders read their source once, front to back
+		if image, err = decodeToMemory(image); err != nil {
+			return nil, err
+		}
+	}
+
+	return newImage(image, src, typ), nil
+}
+
+// Width is the width of i in pixels, 0 once closed.
+func (i *Image) Width() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.image == nil {
+		return 0
+	}
+	return int(i.image.Xsize)
+}
+
+// Height is the height of i in pixels, 0 once closed.
+func (i *Image) Height() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.image == nil {
+		return 0
+	}
+	return int(i.image.Ysize)
+}
+
+// Bands is the number of bands of i, alpha included, 0 once closed.
+func (i *Image) Bands() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.image == nil {
+		return 0
+	}
+	return int(i.image.Bands)
+}
+
+// Resize is ResizeWithInfo of the source of i, which is left as it was.
+// JPEG shrink-on-load does not apply, the source being decoded already,
+// nor do the limits and KeepC2PA of o.
+func (i *Image) Resize(o Options) (Result, error) {
+	if err := o.Validate(); err != nil {
+		return Result{}, err
+	}
+
+	release, err := acquireAt(o.Priority)
+	if err != nil {
+		return Result{}, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	image, err := i.ref()
+	if err != nil {
+		return Result{}, err
+	}
+
+	timeout := startDeadline(o.Timeout)
+	defer timeout.stop()
+
+	image, err = transformImage(image, i.typ, o, nil)
+	if err != nil {
+		return Result{}, err
+	}
+	timeout.watch(image)
+
+	r, err := saveResult(image, i.src, o)
+	return r, timeout.check(err)
+}
+
+// ref returns a reference to the VipsImage of i, for the caller to
+// release.
+func (i *Image) ref() (*C.struct__VipsImage, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.image == nil {
+		return nil, ErrClosed
+	}
+	C.g_object_ref(C.gpointer(i.image))
+	return i.image, nil
+}
+
+// Close frees i. Closing it again does nothing.
+func (i *Image) Close() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	runtime.SetFinalizer(i, nil)
+	i.free()
+	return nil
+}
+
+func (i *Image) finalize() {
+	if i.stack != nil {
+		logf(LEVEL_WARNING, "vips: Image freed by the garbage collector rather than Close, made at\n%s", i.stack)
+	}
+	i.free()
+}
+
+// free releases the VipsImage of i. After Shutdown it is leaked on purpose:
+// vips_shutdown leaves live images alone, but unreffing them into a shut
+// down libvips is not safe.
+func (i *Image) free() {
+	if i.image == nil {
+		return
+	}
+	lifecycle.RLock()
+	if initialized {
+		C.g_object_unref(C.gpointer(i.image))
+	}
+	lifecycle.RUnlock()
+	i.image = nil
+}
@@ -0,0 +1,85 @@
+package vips
+
+import (
+	"errors"
+	"io/ioutil"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestImage(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := Load(buf, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	width := img.Width()
+
+	// the source is read again for every result
+	for _, w := range []int{100, 50} {
+		r, err := img.Resize(Options{Width: w})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Width != w {
+			t.Errorf("Resize() => %d wide, want %d", r.Width, w)
+		}
+	}
+	if img.Width() != width {
+		t.Errorf("Resize() changed the Image from %d to %d wide", width, img.Width())
+	}
+
+	if err := img.Close(); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+	if err := img.Close(); err != nil {
+		t.Errorf("Close() again = %v", err)
+	}
+	if _, err := img.Resize(Options{Width: 100}); !errors.Is(err, ErrClosed) {
+		t.Errorf("Resize() after Close() = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestImageFinalizer(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu       sync.Mutex
+		reported bool
+	)
+	SetLogger(func(level Level, msg string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if level == LEVEL_WARNING && strings.Contains(msg, "rather than Close") {
+			reported = true
+		}
+	})
+	defer SetLogger(nil)
+	SetLeakDetection(true)
+	defer SetLeakDetection(false)
+
+	if _, err := Load(buf, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		done := reported
+		mu.Unlock()
+		if done {
+			return
+		}
+	}
+	t.Error("Image left to the garbage collector was not reported")
+}
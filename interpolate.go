@@ -0,0 +1,26 @@
+package vips
+
+import "sync/atomic"
+
+var defaultInterpolator int32
+
+// interpolatorOf is the interpolator to use for i, resolving
+// INTERPOLATOR_DEFAULT through Config.Interpolator to BICUBIC.
+func interpolatorOf(i Interpolator) Interpolator {
+	if i == INTERPOLATOR_DEFAULT {
+		i = Interpolator(atomic.LoadInt32(&defaultInterpolator))
+	}
+	if _, ok := interpolations[i]; !ok || i == INTERPOLATOR_DEFAULT {
+		return BICUBIC
+	}
+	return i
+}
+
+// interpolate is the libvips interpolator i stands for in transforms that
+// take one; kernels have none and fall back to bicubic.
+func (i Interpolator) interpolate() string {
+	if i == LANCZOS3 {
+		return BICUBIC.String()
+	}
+	return i.String()
+}
@@ -0,0 +1,44 @@
+package vips
+
+import (
+	"io/ioutil"
+	"sync/atomic"
+	"testing"
+)
+
+func TestInterpolatorOf(t *testing.T) {
+	defer atomic.StoreInt32(&defaultInterpolator, 0)
+
+	if got := interpolatorOf(INTERPOLATOR_DEFAULT); got != BICUBIC {
+		t.Errorf("interpolatorOf(default) = %v, want bicubic", got)
+	}
+	if got := interpolatorOf(Interpolator(99)); got != BICUBIC {
+		t.Errorf("interpolatorOf(99) = %v, want bicubic", got)
+	}
+
+	atomic.StoreInt32(&defaultInterpolator, int32(LANCZOS3))
+	if got := interpolatorOf(INTERPOLATOR_DEFAULT); got != LANCZOS3 {
+		t.Errorf("interpolatorOf(default) = %v, want the configured lanczos3", got)
+	}
+	if got := interpolatorOf(NOHALO); got != NOHALO {
+		t.Errorf("interpolatorOf(nohalo) = %v, want nohalo", got)
+	}
+	if got := LANCZOS3.interpolate(); got != "bicubic" {
+		t.Errorf("LANCZOS3.interpolate() = %q, want bicubic", got)
+	}
+}
+
+func TestResizeLanczos3(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := ResizeWithInfo(buf, Options{Width: 123, Height: 77, Interpolator: LANCZOS3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Width > 123 || r.Height > 77 || (r.Width != 123 && r.Height != 77) {
+		t.Errorf("Resize() with lanczos3 => %dx%d", r.Width, r.Height)
+	}
+}
@@ -0,0 +1,36 @@
+package vips
+
+import (
+	"bytes"
+)
+
+// ResizeInto is Resize writing the result into dst, which is reset first,
+// rather than into a new slice, so services can reuse buffers, such as
+// from a sync.Pool, and spare the garbage collector. JPEG, PNG and WebP
+// results are copied from libvips straight into dst; other formats, and
+// results edited after encoding, such as with an orientation tag or C2PA
+// manifest, are copied in once more. dst is left empty on errors.
+func ResizeInto(buf []byte, dst *bytes.Buffer, o Options) (err error) {
+	debug("%#+v", o)
+	var out []byte
+	defer audit("resize", buf, o)(&out, &err)
+
+	o.into = dst
+	r, err := resize(buf, o)
+	if err != nil {
+		dst.Reset()
+		return err
+	}
+	if !within(r.Buf, dst) {
+		dst.Reset()
+		dst.Write(r.Buf)
+	}
+	out = dst.Bytes()
+	return nil
+}
+
+// within reports whether buf is the content of b.
+func within(buf []byte, b *bytes.Buffer) bool {
+	content := b.Bytes()
+	return len(buf) == len(content) && (len(buf) == 0 || &buf[0] == &content[0])
+}
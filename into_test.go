@@ -0,0 +1,49 @@
+package vips
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestResizeInto(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, o := range []Options{
+		{Width: 100},
+		{Width: 100, Savetype: PNG},
+		{Width: 100, Savetype: WEBP},
+		// encoded once more for the orientation tag
+		{Width: 100, Rotate: D90, OrientByTag: true},
+	} {
+		want, err := Resize(buf, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dst := bytes.NewBufferString("left over")
+		if err := ResizeInto(buf, dst, o); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(dst.Bytes(), want) {
+			t.Errorf("ResizeInto(%+v) differs from Resize()", o)
+		}
+	}
+
+	// a buffer large enough is reused
+	dst := bytes.NewBuffer(make([]byte, 0, 1<<20))
+	start := &dst.Bytes()[:1][0]
+	if err := ResizeInto(buf, dst, Options{Width: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if &dst.Bytes()[0] != start {
+		t.Error("ResizeInto() did not reuse the buffer")
+	}
+
+	if err := ResizeInto([]byte("not an image"), dst, Options{}); err == nil || dst.Len() != 0 {
+		t.Errorf("ResizeInto() of garbage = %v, leaving %d bytes", err, dst.Len())
+	}
+}
@@ -0,0 +1,7 @@
+package vips
+
+// Invert returns the negative of buf at its original size, keeping its
+// format where it can be saved. Alpha is left alone.
+func Invert(buf []byte) ([]byte, error) {
+	return Resize(buf, Options{Invert: true, Savetype: keptType(buf)})
+}
@@ -0,0 +1,52 @@
+package vips
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+)
+
+func TestInvert(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.NRGBA{0xff, 0x40, 0, 0x80}}, image.ZP, draw.Src)
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Invert(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outImg, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := color.NRGBA{0, 0xbf, 0xff, 0x80}
+	if got := color.NRGBAModel.Convert(outImg.At(5, 5)); got != want {
+		t.Errorf("Invert() => %v, want %v", got, want)
+	}
+}
+
+func TestInvertLoadOnly(t *testing.T) {
+	buf := bmp24(8, 4, 255, 0, 0)
+	if keptType(buf) != UNKNOWN {
+		t.Errorf("keptType(BMP) => %v, want UNKNOWN", keptType(buf))
+	}
+
+	out, err := Invert(buf)
+	if errors.Is(err, ErrUnsupportedSaveType) {
+		t.Fatalf("Invert() of a BMP => %v", err)
+	}
+	if err != nil {
+		t.Skip("no ImageMagick support in this libvips:", err)
+	}
+	if detectType(out) != JPEG {
+		t.Errorf("Invert() of a BMP => %v, want JPEG", detectType(out))
+	}
+}
@@ -0,0 +1,280 @@
+package vips
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
+	"sync"
+)
+
+// isolatedWorkerFlag is the hidden argument an Isolator starts its worker
+// processes with.
+const isolatedWorkerFlag = "-vips-isolated-worker"
+
+// isolatedSandboxEnv tells a worker to apply Sandbox before serving.
+const isolatedSandboxEnv = "VIPS_ISOLATED_SANDBOX"
+
+// ErrWorkerCrashed is returned for operations whose worker process died,
+// typically because libvips crashed on a malicious file.
+var ErrWorkerCrashed = errors.New("vips: isolated worker crashed")
+
+type isolatedRequest struct {
+	Buf     []byte
+	Options Options
+}
+
+type isolatedResponse struct {
+	Buf []byte
+	Err string
+	// Sentinel is the text of the sentinel Err wraps, if any.
+	Sentinel string
+}
+
+// isolatedSentinels are the errors that still match with errors.Is once
+// they come back from a worker.
+var isolatedSentinels = []error{
+	ErrEmptyBuffer,
+	ErrUnknownFormat,
+	ErrInvalidDimensions,
+	ErrUnsupportedSaveType,
+	ErrInvalidOption,
+	ErrLimitExceeded,
+	ErrTimeout,
+	ErrBudgetExceeded,
+	ErrNotInitialized,
+	ErrDraining,
+}
+
+// isolatedError is an error of a worker, unwrapping to its sentinel.
+type isolatedError struct {
+	msg      string
+	sentinel error
+}
+
+func (e *isolatedError) Error() string { return e.msg }
+
+func (e *isolatedError) Unwrap() error { return e.sentinel }
+
+// errorOf rebuilds the error a worker sent in resp.
+func (resp *isolatedResponse) errorOf() error {
+	for _, sentinel := range isolatedSentinels {
+		if sentinel.Error() == resp.Sentinel {
+			return &isolatedError{resp.Err, sentinel}
+		}
+	}
+	return errors.New(resp.Err)
+}
+
+// ServeIsolated turns the process into an Isolator worker when it was
+// started as one, and returns immediately otherwise. Call it first thing
+// in main, before flags are parsed, in every binary using an Isolator.
+func ServeIsolated() {
+	if len(os.Args) < 2 || os.Args[1] != isolatedWorkerFlag {
+		return
+	}
+
+	if os.Getenv(isolatedSandboxEnv) != "" {
+		if err := Sandbox(); err != nil {
+			fmt.Fprintln(os.Stderr, "vips: sandbox:", err)
+			os.Exit(1)
+		}
+	}
+
+	dec := gob.NewDecoder(os.Stdin)
+	enc := gob.NewEncoder(os.Stdout)
+	for {
+		var req isolatedRequest
+		if err := dec.Decode(&req); err != nil {
+			os.Exit(0)
+		}
+
+		var resp isolatedResponse
+		buf, err := Resize(req.Buf, req.Options)
+		if err != nil {
+			resp.Err = err.Error()
+			for _, sentinel := range isolatedSentinels {
+				if errors.Is(err, sentinel) {
+					resp.Sentinel = sentinel.Error()
+					break
+				}
+			}
+		} else {
+			resp.Buf = buf
+		}
+
+		if err := enc.Encode(&resp); err != nil {
+			os.Exit(1)
+		}
+	}
+}
+
+// Isolator runs transforms in helper processes re-executing the current
+// binary, talking to them over pipes, so a libvips crash on a hostile
+// file kills a worker rather than the whole server. Workers are started
+// on demand and replaced after a crash. The zero value is ready to use.
+type Isolator struct {
+	// Size is the number of worker processes, 1 by default.
+	Size int
+	// Command builds a worker command. By default it runs the current
+	// executable, which must call ServeIsolated.
+	Command func() *exec.Cmd
+	// Sandbox makes workers apply Sandbox before serving, so decoders run
+	// without write access to the filesystem, sockets or exec. Workers
+	// that cannot be sandboxed exit, failing their operations. That denies
+	// the temporary files libvips decodes large random-access images
+	// through and spills to with Options.SpillToDisc, so those fail too,
+	// as do FITS, NIfTI and matrix sources, which libvips reads from files.
+	Sandbox bool
+
+	once sync.Once
+	pool chan *isolatedWorker
+}
+
+type isolatedWorker struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	enc   *gob.Encoder
+	dec   *gob.Decoder
+}
+
+// Resize is vips.Resize running in a worker process. Funcs can't be sent
+// to one, so Options with a CropRegion fail with ErrInvalidOption.
+func (i *Isolator) Resize(buf []byte, o Options) ([]byte, error) {
+	if name, ok := funcField(reflect.ValueOf(o)); ok {
+		return nil, fmt.Errorf("%w: %s can't be sent to an isolated worker", ErrInvalidOption, name)
+	}
+	i.init()
+
+	w := <-i.pool
+	if w == nil {
+		var err error
+		if w, err = i.start(); err != nil {
+			i.pool <- nil
+			return nil, err
+		}
+	}
+
+	var resp isolatedResponse
+	err := w.enc.Encode(&isolatedRequest{Buf: buf, Options: o})
+	if err == nil {
+		err = w.dec.Decode(&resp)
+	}
+	if err != nil {
+		w.stop()
+		i.pool <- nil
+		return nil, fmt.Errorf("%w: %v", ErrWorkerCrashed, w.cmd.ProcessState)
+	}
+
+	i.pool <- w
+
+	if resp.Err != "" {
+		return nil, resp.errorOf()
+	}
+	return resp.Buf, nil
+}
+
+// funcField finds a func set in v, which gob would silently leave out,
+// returning its name.
+func funcField(v reflect.Value) (string, bool) {
+	switch v.Kind() {
+	case reflect.Func:
+		return v.Type().Name(), !v.IsNil()
+	case reflect.Ptr:
+		if v.IsNil() {
+			return "", false
+		}
+		return funcField(v.Elem())
+	case reflect.Struct:
+		for n := 0; n < v.NumField(); n++ {
+			f := v.Type().Field(n)
+			if f.PkgPath != "" {
+				continue
+			}
+			if _, ok := funcField(v.Field(n)); ok {
+				return f.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Close stops the worker processes, waiting for busy ones. Later calls
+// start new workers.
+func (i *Isolator) Close() error {
+	i.init()
+	workers := make([]*isolatedWorker, cap(i.pool))
+	for n := range workers {
+		workers[n] = <-i.pool
+	}
+	for _, w := range workers {
+		if w != nil {
+			w.stop()
+		}
+		i.pool <- nil
+	}
+	return nil
+}
+
+func (i *Isolator) init() {
+	i.once.Do(func() {
+		size := i.Size
+		if size < 1 {
+			size = 1
+		}
+		i.pool = make(chan *isolatedWorker, size)
+		for n := 0; n < size; n++ {
+			i.pool <- nil
+		}
+	})
+}
+
+func (i *Isolator) start() (*isolatedWorker, error) {
+	var cmd *exec.Cmd
+	if i.Command != nil {
+		cmd = i.Command()
+	} else {
+		path, err := os.Executable()
+		if err != nil {
+			return nil, err
+		}
+		cmd = exec.Command(path, isolatedWorkerFlag)
+	}
+	cmd.Stderr = os.Stderr
+	if i.Sandbox {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, isolatedSandboxEnv+"=1")
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return &isolatedWorker{
+		cmd:   cmd,
+		stdin: stdin,
+		enc:   gob.NewEncoder(stdin),
+		dec:   gob.NewDecoder(stdout),
+	}, nil
+}
+
+// stop closes the pipe the worker reads from, which makes it exit, and
+// reaps it. Crashed workers are already gone.
+func (w *isolatedWorker) stop() {
+	w.stdin.Close()
+	w.cmd.Process.Kill()
+	w.cmd.Wait()
+}
@@ -0,0 +1,106 @@
+package vips
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	ServeIsolated()
+	os.Exit(m.Run())
+}
+
+func TestIsolator(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := &Isolator{Size: 2}
+	defer i.Close()
+
+	for n := 0; n < 3; n++ {
+		out, err := i.Resize(buf, Options{Width: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) == 0 {
+			t.Errorf("Isolator.Resize() returned no image")
+		}
+	}
+
+	if _, err := i.Resize([]byte("not an image"), Options{}); err == nil {
+		t.Errorf("Isolator.Resize() of garbage did not fail")
+	}
+
+	// sentinels survive the trip from the worker
+	for _, tc := range []struct {
+		buf     []byte
+		options Options
+		want    error
+	}{
+		{nil, Options{}, ErrEmptyBuffer},
+		{buf, Options{Width: -1}, ErrInvalidDimensions},
+		{buf, Options{Quality: 101}, ErrInvalidOption},
+	} {
+		if _, err := i.Resize(tc.buf, tc.options); !errors.Is(err, tc.want) {
+			t.Errorf("Isolator.Resize(%+v) => %v, want %v", tc.options, err, tc.want)
+		}
+	}
+
+	// gob leaves funcs out, which would ignore the region
+	region := func(width, height int) (Rect, error) { return Rect{Width: 10, Height: 10}, nil }
+	if _, err := i.Resize(buf, Options{CropRegion: region}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Isolator.Resize() with a CropRegion => %v, want %v", err, ErrInvalidOption)
+	}
+
+	// workers start again after Close
+	i.Close()
+	done := make(chan error, 1)
+	go func() {
+		_, err := i.Resize(buf, Options{Width: 100})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Isolator.Resize() after Close => %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Errorf("Isolator.Resize() after Close blocked")
+	}
+}
+
+func TestIsolatorCrash(t *testing.T) {
+	i := &Isolator{Command: func() *exec.Cmd { return exec.Command("false") }}
+	defer i.Close()
+
+	if _, err := i.Resize([]byte{1}, Options{}); err == nil {
+		t.Errorf("Isolator.Resize() with a dead worker did not fail")
+	}
+}
+
+func TestIsolatorSandbox(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := &Isolator{Sandbox: true}
+	defer i.Close()
+
+	out, err := i.Resize(buf, Options{Width: 100})
+	if errors.Is(err, ErrWorkerCrashed) {
+		t.Skip("sandbox not supported:", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) == 0 {
+		t.Errorf("Isolator.Resize() returned no image")
+	}
+}
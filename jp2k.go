@@ -0,0 +1,56 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// JP2K is the type of JPEG 2000 images, in JP2 files or bare codestreams.
+// It needs libvips 8.11 or later built with OpenJPEG; otherwise JP2K
+// sources fail with ErrUnknownFormat and Savetype JP2K with
+// ErrUnsupportedSaveType.
+var JP2K ImageType
+
+var (
+	MARKER_JP2 = []byte{0x00, 0x00, 0x00, 0x0c, 0x6a, 0x50, 0x20, 0x20, 0x0d, 0x0a, 0x87, 0x0a}
+	MARKER_J2K = []byte{0xff, 0x4f, 0xff, 0x51}
+)
+
+func matchJP2K(buf []byte) bool {
+	return bytes.HasPrefix(buf, MARKER_JP2) || bytes.HasPrefix(buf, MARKER_J2K)
+}
+
+// jp2kSuffix picks the JP2 file format, matching the MIME type, rather
+// than a bare codestream. Saving goes through the generic savers, so the
+// package still links against libvips without OpenJPEG.
+func jp2kSuffix(o Options) string {
+	return fmt.Sprintf(".jp2[Q=%d]", o.Quality)
+}
+
+func saveJP2KBuffer(image *C.struct__VipsImage, o Options) ([]byte, error) {
+	return saveBufferSuffix(image, jp2kSuffix(o))
+}
+
+func saveJP2KFile(image *C.struct__VipsImage, path string, o Options) error {
+	return saveFileSuffix(image, path, jp2kSuffix(o))
+}
+
+func init() {
+	JP2K, _ = registerFormat(&Format{
+		Name:       "jp2k",
+		MIME:       "image/jp2",
+		Extensions: []string{".jp2", ".j2k", ".jpf", ".jpx"},
+		Match:      matchJP2K,
+		Alpha:      true,
+		loadOp:     "jp2kload_buffer",
+		saveOp:     "jp2ksave_buffer",
+		load:       loadBufferAuto,
+		save:       saveJP2KBuffer,
+		saveFile:   saveJP2KFile,
+	})
+}
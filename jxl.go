@@ -0,0 +1,59 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// JXL is the type of JPEG XL images. It needs libvips 8.11 or later built
+// with libjxl; otherwise JXL sources fail with ErrUnknownFormat and
+// Savetype JXL with ErrUnsupportedSaveType. Options.Effort sets the encoder
+// effort.
+var JXL ImageType
+
+var (
+	MARKER_JXL           = []byte{0xff, 0x0a}
+	MARKER_JXL_CONTAINER = []byte{0x00, 0x00, 0x00, 0x0c, 0x4a, 0x58, 0x4c, 0x20, 0x0d, 0x0a, 0x87, 0x0a}
+)
+
+func matchJXL(buf []byte) bool {
+	return bytes.HasPrefix(buf, MARKER_JXL) || bytes.HasPrefix(buf, MARKER_JXL_CONTAINER)
+}
+
+// jxlSuffix is the saver suffix for o, for the generic savers.
+func jxlSuffix(o Options) string {
+	// libjxl efforts run 1-9, 7 by default
+	effort := 7
+	if e := effortOf(o); e != EFFORT_DEFAULT {
+		effort = e.scale(1, 9)
+	}
+	return fmt.Sprintf(".jxl[Q=%d,effort=%d]", o.Quality, effort)
+}
+
+func saveJXLBuffer(image *C.struct__VipsImage, o Options) ([]byte, error) {
+	return saveBufferSuffix(image, jxlSuffix(o))
+}
+
+func saveJXLFile(image *C.struct__VipsImage, path string, o Options) error {
+	return saveFileSuffix(image, path, jxlSuffix(o))
+}
+
+func init() {
+	JXL, _ = registerFormat(&Format{
+		Name:       "jxl",
+		MIME:       "image/jxl",
+		Extensions: []string{".jxl"},
+		Match:      matchJXL,
+		Alpha:      true,
+		loadOp:     "jxlload_buffer",
+		saveOp:     "jxlsave_buffer",
+		load:       loadBufferAuto,
+		save:       saveJXLBuffer,
+		saveFile:   saveJXLFile,
+	})
+}
@@ -0,0 +1,93 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Level is the severity of a logged message.
+type Level int
+
+const (
+	LEVEL_DEBUG Level = iota
+	LEVEL_INFO
+	LEVEL_WARNING
+	LEVEL_ERROR
+)
+
+func (l Level) String() string {
+	switch l {
+	case LEVEL_DEBUG:
+		return "debug"
+	case LEVEL_INFO:
+		return "info"
+	case LEVEL_WARNING:
+		return "warning"
+	default:
+		return "error"
+	}
+}
+
+// Logger receives the package's debug output and the warnings libvips
+// logs through glib, which otherwise go to stderr.
+type Logger func(level Level, msg string)
+
+var (
+	loggerMu sync.RWMutex
+	logger   Logger
+	// logHandler is the glib handler id while a logger is set.
+	logHandler C.guint
+)
+
+// SetLogger installs l, or removes the current one when nil, handing glib
+// output back to stderr.
+func SetLogger(l Logger) {
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+
+	switch {
+	case l != nil && logger == nil:
+		logHandler = vipsLogHandlerSet()
+	case l == nil && logger != nil:
+		vipsLogHandlerUnset(logHandler)
+	}
+	logger = l
+}
+
+func logf(level Level, format string, args ...interface{}) {
+	loggerMu.RLock()
+	l := logger
+	loggerMu.RUnlock()
+
+	if l != nil {
+		l(level, fmt.Sprintf(format, args...))
+	}
+}
+
+func debug(format string, args ...interface{}) {
+	logf(LEVEL_DEBUG, format, args...)
+}
+
+// levelOf maps glib log level flags onto a Level.
+func levelOf(flags int) Level {
+	switch {
+	case flags&(C.G_LOG_LEVEL_ERROR|C.G_LOG_LEVEL_CRITICAL) != 0:
+		return LEVEL_ERROR
+	case flags&C.G_LOG_LEVEL_WARNING != 0:
+		return LEVEL_WARNING
+	case flags&(C.G_LOG_LEVEL_MESSAGE|C.G_LOG_LEVEL_INFO) != 0:
+		return LEVEL_INFO
+	default:
+		return LEVEL_DEBUG
+	}
+}
+
+//export govipsLog
+func govipsLog(domain *C.char, level C.int, msg *C.char) {
+	logf(levelOf(int(level)), "%s: %s", C.GoString(domain), C.GoString(msg))
+}
@@ -0,0 +1,66 @@
+package vips
+
+import (
+	"io/ioutil"
+	"sync"
+	"testing"
+)
+
+func TestSetLogger(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu     sync.Mutex
+		levels = map[Level]int{}
+	)
+	SetLogger(func(level Level, msg string) {
+		mu.Lock()
+		levels[level]++
+		mu.Unlock()
+	})
+	defer SetLogger(nil)
+
+	if _, err := Resize(buf, Options{Width: 100}); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if levels[LEVEL_DEBUG] == 0 {
+		t.Errorf("no debug output logged, got %v", levels)
+	}
+	mu.Unlock()
+
+	SetLogger(nil)
+	mu.Lock()
+	levels = map[Level]int{}
+	mu.Unlock()
+	if _, err := Resize(buf, Options{Width: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if len(levels) != 0 {
+		t.Errorf("logged %v after the logger was removed", levels)
+	}
+}
+
+func TestLevelOf(t *testing.T) {
+	// glib's G_LOG_LEVEL_* flags
+	cases := []struct {
+		flags int
+		want  Level
+	}{
+		{1 << 2, LEVEL_ERROR},
+		{1 << 3, LEVEL_ERROR},
+		{1 << 4, LEVEL_WARNING},
+		{1 << 5, LEVEL_INFO},
+		{1 << 6, LEVEL_INFO},
+		{1 << 7, LEVEL_DEBUG},
+		{1<<4 | 1<<1, LEVEL_WARNING},
+	}
+	for _, c := range cases {
+		if got := levelOf(c.flags); got != c.want {
+			t.Errorf("levelOf(%#x) = %v, want %v", c.flags, got, c.want)
+		}
+	}
+}
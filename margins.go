@@ -0,0 +1,60 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"math"
+)
+
+// Margins are the widths of fixed borders to cut off each edge of the
+// source, such as scanner edges or letterboxing: in pixels, or with
+// Percent in percent of the source width for Left and Right and of its
+// height for Top and Bottom.
+type Margins struct {
+	Top, Right, Bottom, Left float64
+	Percent                  bool
+}
+
+// window is the part of a width x height image inside m.
+func (m Margins) window(width, height int) (Rect, error) {
+	px := func(margin float64, size int) int {
+		if m.Percent {
+			margin *= float64(size) / 100
+		}
+		return int(math.Floor(margin + 0.5))
+	}
+
+	left, right := px(m.Left, width), px(m.Right, width)
+	top, bottom := px(m.Top, height), px(m.Bottom, height)
+	r := Rect{left, top, width - left - right, height - top - bottom}
+	if r.Width < 1 || r.Height < 1 {
+		return Rect{}, fmt.Errorf("%w: margins %+v leave nothing of %dx%d", ErrInvalidDimensions, m, width, height)
+	}
+	return r, nil
+}
+
+func (m Margins) validate() error {
+	if m.Top < 0 || m.Right < 0 || m.Bottom < 0 || m.Left < 0 {
+		return fmt.Errorf("negative margins %+v", m)
+	}
+	if m.Percent && (m.Top+m.Bottom >= 100 || m.Left+m.Right >= 100) {
+		return fmt.Errorf("margins %+v leave nothing", m)
+	}
+	return nil
+}
+
+// cropMargins cuts m off image and releases it.
+func cropMargins(image *C.struct__VipsImage, m Margins) (*C.struct__VipsImage, error) {
+	r, err := m.window(int(image.Xsize), int(image.Ysize))
+	if err != nil {
+		C.g_object_unref(C.gpointer(image))
+		return nil, err
+	}
+	debug("crop margins %+v: %+v", m, r)
+
+	return vipsExtractArea(image, r.Left, r.Top, r.Width, r.Height)
+}
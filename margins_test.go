@@ -0,0 +1,52 @@
+package vips
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestMarginsWindow(t *testing.T) {
+	cases := []struct {
+		m    Margins
+		want Rect
+	}{
+		{Margins{}, Rect{0, 0, 200, 100}},
+		{Margins{10, 20, 30, 40, false}, Rect{40, 10, 140, 60}},
+		{Margins{Top: 10, Bottom: 10, Percent: true}, Rect{0, 10, 200, 80}},
+		{Margins{Left: 2.5, Right: 2.5, Percent: true}, Rect{5, 0, 190, 100}},
+	}
+	for _, c := range cases {
+		if got, err := c.m.window(200, 100); err != nil || got != c.want {
+			t.Errorf("%+v.window(200, 100) = %+v, %v, want %+v", c.m, got, err, c.want)
+		}
+	}
+	if _, err := (Margins{Left: 150, Right: 50}).window(200, 100); !errors.Is(err, ErrInvalidDimensions) {
+		t.Errorf("window() of margins as wide as the image = %v, want %v", err, ErrInvalidDimensions)
+	}
+}
+
+func TestCropMargins(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Size(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := ResizeWithInfo(buf, Options{CropMargins: Margins{Top: 10, Right: 20, Bottom: 30, Left: 40}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Width != m.RawWidth-60 || r.Height != m.RawHeight-40 {
+		t.Errorf("Resize() => %dx%d, want %dx%d", r.Width, r.Height, m.RawWidth-60, m.RawHeight-40)
+	}
+
+	for _, bad := range []Margins{{Top: -1}, {Left: 50, Right: 50, Percent: true}} {
+		if _, err := Resize(buf, Options{CropMargins: bad}); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Resize() with margins %+v = %v, want %v", bad, err, ErrInvalidOption)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MATRIX is the type of libvips matrix files: a "width height [scale
+// offset]" header line followed by rows of numbers. Masks and kernels
+// are often exchanged with analysis tools in it.
+var MATRIX ImageType
+
+// CSV is the type of comma separated values, one image row per line.
+// It has no header, so it is never detected; ParseKernel reads it.
+// Both it and MATRIX save a single band, so convert colour images with
+// COLOURSPACE_B_W first.
+var CSV ImageType
+
+func loadMatrixBuffer(buf []byte, fail bool) (*C.struct__VipsImage, error) {
+	return loadViaFile(buf, "vips-*.mat", func(path string) (*C.struct__VipsImage, error) {
+		return vipsMatrixLoad(path, false)
+	})
+}
+
+func saveMatrixBuffer(image *C.struct__VipsImage, o Options) ([]byte, error) {
+	return saveViaFile("vips-*.mat", func(path string) error {
+		return vipsMatrixSave(image, path, false)
+	})
+}
+
+func saveMatrixFile(image *C.struct__VipsImage, path string, o Options) error {
+	return vipsMatrixSave(image, path, false)
+}
+
+func loadCSVBuffer(buf []byte, fail bool) (*C.struct__VipsImage, error) {
+	return loadViaFile(buf, "vips-*.csv", func(path string) (*C.struct__VipsImage, error) {
+		return vipsMatrixLoad(path, true)
+	})
+}
+
+func saveCSVBuffer(image *C.struct__VipsImage, o Options) ([]byte, error) {
+	return saveViaFile("vips-*.csv", func(path string) error {
+		return vipsMatrixSave(image, path, true)
+	})
+}
+
+func saveCSVFile(image *C.struct__VipsImage, path string, o Options) error {
+	return vipsMatrixSave(image, path, true)
+}
+
+// matchMatrix reports whether buf starts with a matrix header.
+func matchMatrix(buf []byte) bool {
+	if len(buf) > 256 {
+		buf = buf[:256]
+	}
+	line, err := bufio.NewReader(bytes.NewReader(buf)).ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) != 2 && len(fields) != 4 {
+		return false
+	}
+	for i, f := range fields {
+		if i < 2 {
+			if n, err := strconv.Atoi(f); err != nil || n < 1 {
+				return false
+			}
+		} else if _, err := strconv.ParseFloat(f, 64); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseKernel reads a single band matrix or CSV file, as written by
+// MarshalMatrix and MarshalCSV or by analysis tools, into a kernel for
+// Options.Convolve.
+func ParseKernel(buf []byte) (k Kernel, err error) {
+	release, err := acquire()
+	if err != nil {
+		return Kernel{}, err
+	}
+	defer release()
+
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	var image *C.struct__VipsImage
+	if matchMatrix(buf) {
+		image, err = loadMatrixBuffer(buf, false)
+	} else {
+		image, err = loadCSVBuffer(buf, false)
+	}
+	if err != nil {
+		return Kernel{}, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	if image.Bands != 1 {
+		return Kernel{}, fmt.Errorf("vips: kernel has %d bands, want 1", image.Bands)
+	}
+	values, err := vipsMatrixValues(image)
+	if err != nil {
+		return Kernel{}, err
+	}
+
+	return Kernel{
+		Width:  int(image.Xsize),
+		Height: int(image.Ysize),
+		Values: values,
+		Scale:  float64(C.vips_image_get_scale(image)),
+		Offset: float64(C.vips_image_get_offset(image)),
+	}, nil
+}
+
+// MarshalMatrix writes k as a libvips matrix file, scale and offset
+// included.
+func (k Kernel) MarshalMatrix() ([]byte, error) {
+	return k.marshal("vips-*.mat", false)
+}
+
+// MarshalCSV writes the values of k as CSV, one row per line. Scale and
+// offset are lost.
+func (k Kernel) MarshalCSV() ([]byte, error) {
+	return k.marshal("vips-*.csv", true)
+}
+
+func (k Kernel) marshal(pattern string, csv bool) ([]byte, error) {
+	if err := k.validate(); err != nil {
+		return nil, err
+	}
+
+	release, err := acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	return saveViaFile(pattern, func(path string) error {
+		return vipsMatrixSaveKernel(k, path, csv)
+	})
+}
+
+func init() {
+	MATRIX, _ = registerFormat(&Format{
+		Name:       "matrix",
+		MIME:       "text/x-vips-matrix",
+		Extensions: []string{".mat"},
+		Match:      matchMatrix,
+		lossless:   true,
+		loadOp:     "matrixload",
+		saveOp:     "matrixsave",
+		load:       loadMatrixBuffer,
+		save:       saveMatrixBuffer,
+		saveFile:   saveMatrixFile,
+	})
+	CSV, _ = registerFormat(&Format{
+		Name:       "csv",
+		MIME:       "text/csv",
+		Extensions: []string{".csv"},
+		lossless:   true,
+		loadOp:     "csvload",
+		saveOp:     "csvsave",
+		load:       loadCSVBuffer,
+		save:       saveCSVBuffer,
+		saveFile:   saveCSVFile,
+	})
+}
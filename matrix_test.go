@@ -0,0 +1,81 @@
+package vips
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+func TestMatchMatrix(t *testing.T) {
+	cases := map[string]bool{
+		"3 3\n0 -1 0\n":          true,
+		"3 3 8 128\n1 1 1\n":     true,
+		"3\t2\n":                 true,
+		"0 3\n":                  false,
+		"3 3 8\n":                false,
+		"1,2,3\n4,5,6\n":         false,
+		"3 3":                    false,
+		"SIMPLE  =          T\n": false,
+	}
+	for in, want := range cases {
+		if got := matchMatrix([]byte(in)); got != want {
+			t.Errorf("matchMatrix(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestKernelMatrix(t *testing.T) {
+	k := Kernel{Width: 3, Height: 2, Values: []float64{1, 2, 3, 4, 5, 6}, Scale: 3, Offset: 1}
+
+	buf, err := k.MarshalMatrix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detectType(buf) != MATRIX {
+		t.Errorf("MarshalMatrix() wrote %v: %q", detectType(buf), buf)
+	}
+	got, err := ParseKernel(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, k) {
+		t.Errorf("ParseKernel(MarshalMatrix()) = %+v, want %+v", got, k)
+	}
+
+	buf, err = k.MarshalCSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = ParseKernel(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Width != 3 || got.Height != 2 || !reflect.DeepEqual(got.Values, k.Values) {
+		t.Errorf("ParseKernel(MarshalCSV()) = %+v, want values of %+v", got, k)
+	}
+}
+
+func TestMatrixSave(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, image.NewGray(image.Rect(0, 0, 4, 2))); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Resize(buf.Bytes(), Options{Savetype: MATRIX, Colourspace: COLOURSPACE_B_W})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out, []byte("4 2")) {
+		t.Errorf("Resize(MATRIX) = %q, want a 4x2 matrix", out)
+	}
+
+	r, err := ResizeWithInfo(out, Options{Savetype: PNG})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Width != 4 || r.Height != 2 {
+		t.Errorf("matrix round trip => %dx%d, want 4x2", r.Width, r.Height)
+	}
+}
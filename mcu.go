@@ -0,0 +1,46 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"strings"
+)
+
+// alignsMCU reports whether o asks for crops of a typ source to be aligned
+// to its JPEG blocks. Trimming and cutting margins move the origin off the
+// grid.
+func alignsMCU(typ ImageType, o Options) bool {
+	return o.AlignMCU && typ == JPEG && !o.Trim && o.CropMargins == (Margins{}) && saverOf(o.Savetype).Type == JPEG
+}
+
+// mcuOf is the size of the minimum coded units of a JPEG image, from the
+// chroma subsampling libvips reports, or 16x16, which any grid divides,
+// when it reports none.
+func mcuOf(image *C.struct__VipsImage) (width, height int) {
+	return parseMCU(vipsImageString(image, "jpeg-chroma-subsample"))
+}
+
+// parseMCU reads the MCU size from a subsampling such as "4:2:0 (2x2)".
+func parseMCU(subsample string) (width, height int) {
+	var h, v int
+	if i := strings.IndexByte(subsample, '('); i >= 0 {
+		if _, err := fmt.Sscanf(subsample[i:], "(%dx%d)", &h, &v); err == nil && h > 0 && v > 0 {
+			return 8 * h, 8 * v
+		}
+	}
+	if strings.HasPrefix(subsample, "4:4:4") {
+		return 8, 8
+	}
+	return 16, 16
+}
+
+// alignWindow moves the top left corner of w back onto a mcuWidth x
+// mcuHeight grid, keeping its bottom right corner.
+func alignWindow(w Rect, mcuWidth, mcuHeight int) Rect {
+	dx, dy := w.Left%mcuWidth, w.Top%mcuHeight
+	return Rect{w.Left - dx, w.Top - dy, w.Width + dx, w.Height + dy}
+}
@@ -0,0 +1,61 @@
+package vips
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestParseMCU(t *testing.T) {
+	cases := []struct {
+		subsample     string
+		width, height int
+	}{
+		{"4:2:0 (2x2)", 16, 16},
+		{"4:2:2 (2x1)", 16, 8},
+		{"4:4:4 (1x1)", 8, 8},
+		{"4:4:4", 8, 8},
+		{"", 16, 16},
+		{"garbage (x)", 16, 16},
+	}
+	for _, c := range cases {
+		if w, h := parseMCU(c.subsample); w != c.width || h != c.height {
+			t.Errorf("parseMCU(%q) = %dx%d, want %dx%d", c.subsample, w, h, c.width, c.height)
+		}
+	}
+}
+
+func TestAlignWindow(t *testing.T) {
+	got := alignWindow(Rect{Left: 37, Top: 20, Width: 100, Height: 50}, 16, 8)
+	if want := (Rect{Left: 32, Top: 16, Width: 105, Height: 54}); got != want {
+		t.Errorf("alignWindow() = %+v, want %+v", got, want)
+	}
+	if got := alignWindow(Rect{Left: 32, Top: 16, Width: 10, Height: 10}, 16, 16); got.Left != 32 || got.Top != 16 || got.Width != 10 {
+		t.Errorf("alignWindow() moved an aligned window to %+v", got)
+	}
+}
+
+func TestAlignMCU(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	region := func(width, height int) (Rect, error) {
+		return Rect{Left: 37, Top: 21, Width: 100, Height: 60}, nil
+	}
+	r, err := ResizeWithInfo(buf, Options{CropRegion: region, AlignMCU: true, Savetype: JPEG})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Width < 100+37%8 || r.Height < 60+21%8 {
+		t.Errorf("aligned crop => %dx%d, want it widened to the block grid", r.Width, r.Height)
+	}
+
+	r, err = ResizeWithInfo(buf, Options{CropRegion: region, AlignMCU: true, Savetype: PNG})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Width != 100 || r.Height != 60 {
+		t.Errorf("crop saved as PNG => %dx%d, want 100x60 unaligned", r.Width, r.Height)
+	}
+}
@@ -0,0 +1,34 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import "fmt"
+
+// memoryOf is the size of the decoded pixels of image, which operations
+// needing random access, such as rotation, hold in memory.
+func memoryOf(image *C.struct__VipsImage) int64 {
+	return int64(image.Xsize) * int64(image.Ysize) * int64(image.Bands) * int64(C.vips_format_sizeof(image.BandFmt))
+}
+
+// limitMemory holds image to o.MaxMemory before any pixels are decoded.
+// Larger images are decoded to a temporary file on disc instead when
+// o.SpillToDisc is set, and refused with ErrLimitExceeded otherwise.
+// image is released when it is replaced or refused.
+func limitMemory(image *C.struct__VipsImage, o Options) (out *C.struct__VipsImage, spilled bool, err error) {
+	size := memoryOf(image)
+	if o.MaxMemory <= 0 || size <= o.MaxMemory {
+		return image, false, nil
+	}
+
+	if !o.SpillToDisc {
+		C.g_object_unref(C.gpointer(image))
+		return nil, false, fmt.Errorf("%w: decodes to %d bytes, over MaxMemory %d", ErrLimitExceeded, size, o.MaxMemory)
+	}
+
+	debug("spilling %d bytes to disc", size)
+	out, err = vipsSpillToDisc(image)
+	return out, err == nil, err
+}
@@ -0,0 +1,47 @@
+package vips
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestMaxMemory(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Size(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := int64(m.RawWidth) * int64(m.RawHeight) * int64(m.Channels)
+
+	if _, err := Resize(buf, Options{Width: 100, MaxMemory: decoded}); err != nil {
+		t.Errorf("Resize() within MaxMemory = %v", err)
+	}
+	if _, err := Resize(buf, Options{Width: 100, MaxMemory: decoded - 1}); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Resize() over MaxMemory = %v, want %v", err, ErrLimitExceeded)
+	}
+
+	want, err := ResizeWithInfo(buf, Options{Width: 100, RotateDegrees: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ResizeWithInfo(buf, Options{Width: 100, RotateDegrees: 30, MaxMemory: 1024, SpillToDisc: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Width != want.Width || got.Height != want.Height {
+		t.Errorf("spilled Resize() => %dx%d, want %dx%d", got.Width, got.Height, want.Width, want.Height)
+	}
+
+	outs, err := ResizeMulti(buf, []Options{{Width: 50}, {Width: 80, MaxMemory: 1024, SpillToDisc: true}})
+	if err != nil || len(outs) != 2 {
+		t.Errorf("ResizeMulti() spilling = %d outputs, %v", len(outs), err)
+	}
+
+	if err := (Options{MaxMemory: -1}).Validate(); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Validate() of a negative MaxMemory = %v", err)
+	}
+}
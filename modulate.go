@@ -0,0 +1,22 @@
+package vips
+
+// Modulation scales lightness and chroma and rotates hue in the LCh
+// colour space, like sharp's modulate. Zero multipliers mean 1, so the
+// zero value changes nothing.
+type Modulation struct {
+	// Brightness multiplies lightness.
+	Brightness float64
+	// Saturation multiplies chroma; 0.0001 all but removes colour.
+	Saturation float64
+	// Hue rotates hue by this many degrees.
+	Hue float64
+}
+
+// Modulate applies a Modulation to buf at its original size, keeping its
+// format where it can be saved.
+func Modulate(buf []byte, brightness, saturation, hue float64) ([]byte, error) {
+	return Resize(buf, Options{
+		Modulate: Modulation{Brightness: brightness, Saturation: saturation, Hue: hue},
+		Savetype: keptType(buf),
+	})
+}
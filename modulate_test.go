@@ -0,0 +1,32 @@
+package vips
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+)
+
+func TestModulate(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{0xff, 0, 0, 0xff}}, image.ZP, draw.Src)
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Modulate(buf.Bytes(), 1, 1, 180)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outImg, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, b, _ := outImg.At(5, 5).RGBA(); r >= g || r >= b {
+		t.Errorf("Modulate(hue 180) of red => %v, want a cyan", outImg.At(5, 5))
+	}
+}
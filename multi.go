@@ -0,0 +1,93 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"fmt"
+)
+
+// ResizeMulti is Resize for several variants of one source, such as a
+// thumbnail, medium and large size, or the same size as WebP and JPEG.
+// The source is decoded once and kept in memory for all of them, so JPEG
+// shrink-on-load does not apply. The decode is bound by the tightest
+// limits and MaxMemory of the variants, spills to disc when any sets
+// SpillToDisc, and is strict when any sets FailOnError. It queues at the
+// highest Priority of the variants. Outputs are in the order of variants;
+// the first variant failing fails the call.
+func ResizeMulti(buf []byte, variants []Options) ([][]byte, error) {
+	var (
+		l    Limits
+		m    Options
+		fail bool
+	)
+	for i, o := range variants {
+		if err := o.Validate(); err != nil {
+			return nil, fmt.Errorf("vips: variant %d: %w", i, err)
+		}
+		l = l.within(limitsOf(o))
+		fail = fail || o.FailOnError
+		if o.MaxMemory > 0 && (m.MaxMemory == 0 || o.MaxMemory < m.MaxMemory) {
+			m.MaxMemory = o.MaxMemory
+		}
+		m.SpillToDisc = m.SpillToDisc || o.SpillToDisc
+		if i == 0 || o.Priority < m.Priority {
+			m.Priority = o.Priority
+		}
+	}
+
+	release, err := acquireAt(m.Priority)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	image, typ, err := loadBuffer(buf, l, fail)
+	if err != nil {
+		return nil, err
+	}
+
+	// loaders read sequentially, every variant reads it again, from memory
+	// or from disc when over the tightest MaxMemory
+	source, spilled, err := limitMemory(image, m)
+	if err != nil {
+		return nil, err
+	}
+	if !spilled {
+		image, source = source, C.vips_image_copy_memory(source)
+		C.g_object_unref(C.gpointer(image))
+		if source == nil {
+			return nil, catchVipsError()
+		}
+	}
+	defer C.g_object_unref(C.gpointer(source))
+
+	outs := make([][]byte, len(variants))
+	for i, o := range variants {
+		if outs[i], err = resizeVariant(source, typ, buf, o); err != nil {
+			return nil, fmt.Errorf("vips: variant %d: %w", i, err)
+		}
+	}
+	return outs, nil
+}
+
+// resizeVariant transforms and saves source, which it keeps, as o.
+func resizeVariant(source *C.struct__VipsImage, typ ImageType, buf []byte, o Options) (out []byte, err error) {
+	debug("%#+v", o)
+	defer audit("resize", buf, o)(&out, &err)
+
+	C.g_object_ref(C.gpointer(source))
+	image, err := transformImage(source, typ, o, nil)
+	if err != nil {
+		return nil, err
+	}
+	return saveImage(image, o)
+}
@@ -0,0 +1,47 @@
+package vips
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestResizeMulti(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	variants := []Options{
+		{Width: 50, Height: 50, Crop: true},
+		{Width: 200, Savetype: WEBP},
+		{Width: 200, Savetype: PNG},
+	}
+	outs, err := ResizeMulti(buf, variants)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outs) != len(variants) {
+		t.Fatalf("ResizeMulti() returned %d outputs, want %d", len(outs), len(variants))
+	}
+
+	for i, o := range variants {
+		want := o.Savetype
+		if want == UNKNOWN {
+			want = JPEG
+		}
+		if got := detectType(outs[i]); got != want {
+			t.Errorf("variant %d is %v, want %v", i, got, want)
+		}
+		m, err := Size(outs[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Width != o.Width {
+			t.Errorf("variant %d is %d wide, want %d", i, m.Width, o.Width)
+		}
+	}
+
+	if _, err := ResizeMulti([]byte("not an image"), variants); err == nil {
+		t.Errorf("ResizeMulti() of garbage did not fail")
+	}
+}
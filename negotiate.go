@@ -0,0 +1,103 @@
+package vips
+
+import (
+	"strconv"
+	"strings"
+)
+
+// BestSaveType picks the output format for an HTTP Accept header, for
+// image proxies to set Options.Savetype with. Formats named in accept, such
+// as image/webp or a registered image/avif, are chosen by their q values,
+// ties going to the one listed first, when this libvips can save them and,
+// with hasAlpha, as Size reports it, they keep transparency. Lossless
+// formats such as PNG are only chosen for images with alpha. Otherwise it
+// falls back to PNG for images with alpha and JPEG for the rest, which
+// every client takes.
+func BestSaveType(accept string, hasAlpha bool) ImageType {
+	return BestSaveTypeOf(accept, hasAlpha, nil)
+}
+
+// BestSaveTypeOf is BestSaveType choosing only among formats, such as
+// those a tenant is allowed, or among all when formats is empty. When
+// neither PNG nor JPEG is among them, it falls back to the first of formats
+// that can be saved, keeping transparency with hasAlpha if one does.
+func BestSaveTypeOf(accept string, hasAlpha bool, formats []ImageType) ImageType {
+	allowed := func(t ImageType) bool {
+		if len(formats) == 0 {
+			return true
+		}
+		for _, f := range formats {
+			if f == t {
+				return true
+			}
+		}
+		return false
+	}
+
+	fallback := JPEG
+	if hasAlpha {
+		fallback = PNG
+	}
+	if !allowed(fallback) {
+		fallback = fallbackOf(formats, hasAlpha)
+	}
+
+	best, bestQ, wildcardQ := UNKNOWN, 0.0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mime, q := parseAccept(part)
+		if mime == "*/*" || mime == "image/*" {
+			if q > wildcardQ {
+				wildcardQ = q
+			}
+			continue
+		}
+
+		f, ok := formatOf(TypeOfMIME(mime))
+		if !ok || !f.canSave || hasAlpha && !f.Alpha || !hasAlpha && f.lossless || !allowed(f.Type) {
+			continue
+		}
+		if q > bestQ {
+			best, bestQ = f.Type, q
+		}
+	}
+
+	if best == UNKNOWN || wildcardQ > bestQ {
+		return fallback
+	}
+	return best
+}
+
+// fallbackOf is the first of formats that can be saved, preferring those
+// keeping alpha with hasAlpha, or UNKNOWN, saving as JPEG, for none.
+func fallbackOf(formats []ImageType, hasAlpha bool) ImageType {
+	first := UNKNOWN
+	for _, t := range formats {
+		f, ok := formatOf(t)
+		if !ok || !f.canSave {
+			continue
+		}
+		if !hasAlpha || f.Alpha {
+			return t
+		}
+		if first == UNKNOWN {
+			first = t
+		}
+	}
+	return first
+}
+
+// parseAccept splits an Accept header element such as "image/webp;q=0.8"
+// into its lower case media range and quality, 1 when not given.
+func parseAccept(part string) (mime string, q float64) {
+	params := strings.Split(part, ";")
+	mime, q = strings.ToLower(strings.TrimSpace(params[0])), 1
+	for _, p := range params[1:] {
+		p = strings.TrimSpace(p)
+		if strings.HasPrefix(p, "q=") || strings.HasPrefix(p, "Q=") {
+			if v, err := strconv.ParseFloat(p[2:], 64); err == nil && v >= 0 && v <= 1 {
+				q = v
+			}
+		}
+	}
+	return mime, q
+}
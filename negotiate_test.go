@@ -0,0 +1,61 @@
+package vips
+
+import "testing"
+
+func TestBestSaveType(t *testing.T) {
+	cases := []struct {
+		accept   string
+		hasAlpha bool
+		want     ImageType
+	}{
+		{"", false, JPEG},
+		{"", true, PNG},
+		{"*/*", false, JPEG},
+		{"image/webp,image/apng,image/*,*/*;q=0.8", false, WEBP},
+		{"image/webp,image/apng,image/*,*/*;q=0.8", true, WEBP},
+		{"image/png,image/jpeg;q=0.9", false, JPEG},
+		{"image/png,image/jpeg;q=0.9", true, PNG},
+		// older Safari
+		{"image/png,image/svg+xml,image/*;q=0.8,video/*;q=0.8,*/*;q=0.5", false, JPEG},
+		{"image/jpeg", true, PNG},
+		{"image/webp;q=0.5, image/*", false, JPEG},
+		{"image/webp;q=0", false, JPEG},
+		{"Image/WebP; Q=0.9, image/png;q=0.9", true, WEBP},
+		{"image/gif, text/html", false, JPEG},
+		{"image/bmp", false, JPEG},
+	}
+	for _, c := range cases {
+		if got := BestSaveType(c.accept, c.hasAlpha); got != c.want {
+			t.Errorf("BestSaveType(%q, %v) = %v, want %v", c.accept, c.hasAlpha, got, c.want)
+		}
+	}
+}
+
+func TestParseAccept(t *testing.T) {
+	if mime, q := parseAccept(" image/webp ; q=0.25 "); mime != "image/webp" || q != 0.25 {
+		t.Errorf("parseAccept() = %q, %v", mime, q)
+	}
+	if _, q := parseAccept("image/png;q=nope"); q != 1 {
+		t.Errorf("parseAccept() with a bad q = %v, want 1", q)
+	}
+}
+
+func TestBestSaveTypeOf(t *testing.T) {
+	cases := []struct {
+		accept   string
+		hasAlpha bool
+		formats  []ImageType
+		want     ImageType
+	}{
+		{"image/webp,*/*", false, nil, WEBP},
+		{"image/webp,*/*", false, []ImageType{JPEG}, JPEG},
+		{"image/webp,*/*", true, []ImageType{JPEG, PNG}, PNG},
+		{"image/png", false, []ImageType{WEBP}, WEBP},
+		{"", true, []ImageType{JPEG, WEBP}, WEBP},
+	}
+	for _, c := range cases {
+		if got := BestSaveTypeOf(c.accept, c.hasAlpha, c.formats); got != c.want {
+			t.Errorf("BestSaveTypeOf(%q, %v, %v) = %v, want %v", c.accept, c.hasAlpha, c.formats, got, c.want)
+		}
+	}
+}
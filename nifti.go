@@ -0,0 +1,54 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// NIFTI is the type of NIfTI-1 and NIfTI-2 single file volumes, the
+// format of MRI and other medical scans. It needs libvips built with
+// niftiio. Volumes are rendered one slice at a time, picked with
+// Options.Slice. Scans are usually 16 bit or float, so pair them with a
+// Stretch.
+var NIFTI ImageType
+
+// matchNIfTI reports whether buf starts with a NIfTI-1 or NIfTI-2 header.
+func matchNIfTI(buf []byte) bool {
+	return (len(buf) >= 348 && bytes.Equal(buf[344:348], []byte("n+1\x00"))) ||
+		(len(buf) >= 8 && bytes.Equal(buf[4:8], []byte("n+2\x00")))
+}
+
+// libvips only reads NIfTI files, so buffers go through a temporary one.
+func loadNIfTIBuffer(buf []byte, fail bool) (*C.struct__VipsImage, error) {
+	return loadViaFile(buf, "vips-*.nii", vipsNIfTILoad)
+}
+
+// extractSlice crops slice out of image, whose slices are height pixels
+// tall, and releases image.
+func extractSlice(image *C.struct__VipsImage, height, slice int) (*C.struct__VipsImage, error) {
+	slices := int(image.Ysize) / height
+	if slice < 0 || slice >= slices {
+		C.g_object_unref(C.gpointer(image))
+		return nil, fmt.Errorf("vips: slice %d out of %d", slice, slices)
+	}
+	debug("slice %d of %d", slice, slices)
+
+	return vipsExtractArea(image, 0, slice*height, int(image.Xsize), height)
+}
+
+func init() {
+	NIFTI, _ = registerFormat(&Format{
+		Name:       "nifti",
+		MIME:       "application/x-nifti",
+		Extensions: []string{".nii"},
+		Match:      matchNIfTI,
+		lossless:   true,
+		loadOp:     "niftiload",
+		load:       loadNIfTIBuffer,
+	})
+}
@@ -0,0 +1,53 @@
+package vips
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// nifti1 builds a width x height x depth uchar NIfTI-1 volume whose slice
+// z is filled with z.
+func nifti1(width, height, depth int) []byte {
+	buf := make([]byte, 352+width*height*depth)
+	le := binary.LittleEndian
+	le.PutUint32(buf[0:], 348)
+	for i, d := range []int{3, width, height, depth, 1, 1, 1, 1} {
+		le.PutUint16(buf[40+2*i:], uint16(d))
+	}
+	le.PutUint16(buf[70:], 2) // DT_UNSIGNED_CHAR
+	le.PutUint16(buf[72:], 8)
+	for i := 0; i < 4; i++ {
+		le.PutUint32(buf[76+4*i:], 0x3f800000) // pixdim 1.0
+	}
+	le.PutUint32(buf[108:], 0x43b00000) // vox_offset 352.0
+	copy(buf[344:], "n+1\x00")
+
+	for z := 0; z < depth; z++ {
+		for i := 0; i < width*height; i++ {
+			buf[352+z*width*height+i] = byte(z)
+		}
+	}
+	return buf
+}
+
+func TestNIfTI(t *testing.T) {
+	buf := nifti1(4, 3, 2)
+	if NIFTI.String() != "nifti" || TypeOfExt(".nii") != NIFTI || detectType(buf) != NIFTI {
+		t.Errorf("NIfTI format not registered: %v", detectType(buf))
+	}
+	if matchNIfTI(buf[:300]) {
+		t.Errorf("matchNIfTI() matched a truncated header")
+	}
+
+	r, err := ResizeWithInfo(buf, Options{Slice: 1, Savetype: PNG})
+	if err != nil {
+		t.Skip("no NIfTI support in this libvips:", err)
+	}
+	if r.Width != 4 || r.Height != 3 {
+		t.Errorf("Resize(Slice: 1) => %dx%d, want 4x3", r.Width, r.Height)
+	}
+
+	if _, err := Resize(buf, Options{Slice: 2}); err == nil {
+		t.Errorf("Resize() of a missing slice did not fail")
+	}
+}
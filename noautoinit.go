@@ -0,0 +1,6 @@
+//go:build vips_noautoinit
+// +build vips_noautoinit
+
+package vips
+
+const autoInitialize = false
@@ -0,0 +1,93 @@
+package vips
+
+// orientation is a mirror left to right, when mirror is set, followed by
+// turns quarter turns clockwise: how an EXIF orientation tag, or Rotate,
+// Flip and Flop, turn the stored pixels into those displayed.
+type orientation struct {
+	mirror bool
+	turns  int
+}
+
+// orientations are the EXIF orientation tags 1-8.
+var orientations = [...]orientation{
+	1: {false, 0},
+	2: {true, 0},
+	3: {false, 2},
+	4: {true, 2},
+	5: {true, 3},
+	6: {false, 1},
+	7: {true, 1},
+	8: {false, 3},
+}
+
+// then is a followed by b.
+func (a orientation) then(b orientation) orientation {
+	// turning then mirroring is mirroring then turning the other way
+	turns := a.turns
+	if b.mirror {
+		turns = -turns
+	}
+	return orientation{a.mirror != b.mirror, ((turns+b.turns)%4 + 4) % 4}
+}
+
+// tag is the EXIF orientation of a.
+func (a orientation) tag() int {
+	for tag := 1; tag < len(orientations); tag++ {
+		if orientations[tag] == a {
+			return tag
+		}
+	}
+	return 1
+}
+
+// orientationOfTag is the orientation of an EXIF tag, none for values out
+// of range.
+func orientationOfTag(tag int) orientation {
+	if tag < 1 || tag >= len(orientations) {
+		return orientation{}
+	}
+	return orientations[tag]
+}
+
+// orientationOf is Rotate followed by Flip and Flop.
+func orientationOf(o Options) orientation {
+	t := orientation{turns: int(o.Rotate) / 90 % 4}
+	if o.Flip {
+		t = t.then(orientation{mirror: true})
+	}
+	if o.Flop {
+		t = t.then(orientation{mirror: true, turns: 2})
+	}
+	return t
+}
+
+// orientsByTag reports whether o turns the result with the EXIF
+// orientation tag rather than by moving pixels. AUTO may not pick JPEG.
+func orientsByTag(o Options) bool {
+	return o.OrientByTag && o.Savetype != AUTO && saverOf(o.Savetype).Type == JPEG
+}
+
+// jpegWithOrientation inserts an EXIF segment carrying only the
+// orientation tag into a JPEG buf without one, after its JFIF segment.
+func jpegWithOrientation(buf []byte, tag int) []byte {
+	tiff := []byte{
+		'I', 'I', 42, 0, 8, 0, 0, 0, // little endian, IFD0 at 8
+		1, 0, // one entry
+		0x12, 0x01, 3, 0, 1, 0, 0, 0, byte(tag), byte(tag >> 8), 0, 0, // orientation, one SHORT
+		0, 0, 0, 0, // no next IFD
+	}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := append([]byte{0xff, 0xe1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}, payload...)
+
+	at := 2
+	jpegSegments(buf, func(offset int, marker byte, payload []byte) {
+		if offset == 2 && marker == 0xe0 {
+			at = offset + 4 + len(payload)
+		}
+	})
+
+	out := make([]byte, 0, len(buf)+len(segment))
+	out = append(out, buf[:at]...)
+	out = append(out, segment...)
+	return append(out, buf[at:]...)
+}
@@ -0,0 +1,120 @@
+package vips
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestOrientation(t *testing.T) {
+	for tag := 1; tag <= 8; tag++ {
+		o := orientationOfTag(tag)
+		if o.tag() != tag {
+			t.Errorf("orientationOfTag(%d).tag() = %d", tag, o.tag())
+		}
+		if got := o.then(orientation{}); got != o {
+			t.Errorf("%d then nothing = %d", tag, got.tag())
+		}
+	}
+
+	cases := []struct {
+		o    Options
+		want int
+	}{
+		{Options{}, 1},
+		{Options{Flip: true}, 2},
+		{Options{Rotate: D180}, 3},
+		{Options{Flop: true}, 4},
+		{Options{Rotate: D90}, 6},
+		{Options{Rotate: D270}, 8},
+		{Options{Rotate: D90, Flip: true}, 5},
+		{Options{Rotate: D270, Flip: true}, 7},
+		{Options{Flip: true, Flop: true}, 3},
+	}
+	for _, c := range cases {
+		if got := orientationOf(c.o).tag(); got != c.want {
+			t.Errorf("orientationOf(%+v) = %d, want %d", c.o, got, c.want)
+		}
+	}
+
+	// a sideways photo turned back by a quarter turn the other way
+	if got := orientationOfTag(6).then(orientation{turns: 3}).tag(); got != 1 {
+		t.Errorf("6 then 270 = %d, want 1", got)
+	}
+}
+
+func TestOrientByTag(t *testing.T) {
+	jpg := new(bytes.Buffer)
+	if err := jpeg.Encode(jpg, image.NewRGBA(image.Rect(0, 0, 40, 20)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Resize(jpg.Bytes(), Options{Rotate: D90, OrientByTag: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Size(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Orientation != 6 || m.RawWidth != 40 || m.Width != 20 {
+		t.Errorf("Resize() by tag => %+v, want 40x20 stored with orientation 6", m)
+	}
+
+	out, err = Resize(jpg.Bytes(), Options{Rotate: D90})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := Size(out); err != nil || m.Orientation > 1 || m.RawWidth != 20 || m.RawHeight != 40 {
+		t.Errorf("Resize() turning pixels => %+v, %v, want 20x40", m, err)
+	}
+
+	out, err = Resize(jpg.Bytes(), Options{Rotate: D90, OrientByTag: true, Savetype: PNG})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := Size(out); err != nil || m.RawWidth != 20 {
+		t.Errorf("Resize() to PNG by tag => %+v, %v, want the pixels turned", m, err)
+	}
+}
+
+func TestResizeOrients(t *testing.T) {
+	// red, blue over green, white
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{0xff, 0, 0, 0xff})
+	img.Set(1, 0, color.RGBA{0, 0, 0xff, 0xff})
+	img.Set(0, 1, color.RGBA{0, 0xff, 0, 0xff})
+	img.Set(1, 1, color.RGBA{0xff, 0xff, 0xff, 0xff})
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	var testCases = []struct {
+		options Options
+		topLeft color.RGBA
+	}{
+		// unset, the pixels stay where they were before 2.0.0
+		{Options{Savetype: PNG}, color.RGBA{0xff, 0, 0, 0xff}},
+		{Options{Savetype: PNG, Flip: true}, color.RGBA{0, 0, 0xff, 0xff}},
+		{Options{Savetype: PNG, Flop: true}, color.RGBA{0, 0xff, 0, 0xff}},
+		{Options{Savetype: PNG, Rotate: D90}, color.RGBA{0, 0xff, 0, 0xff}},
+	}
+
+	for index, tc := range testCases {
+		out, err := Resize(buf.Bytes(), tc.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outImg, err := png.Decode(bytes.NewReader(out))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := color.RGBAModel.Convert(outImg.At(0, 0)); got != tc.topLeft {
+			t.Errorf("%d. Resize(%+v) top left => %v, want %v", index, tc.options, got, tc.topLeft)
+		}
+	}
+}
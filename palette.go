@@ -0,0 +1,123 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+)
+
+// paletteSize is the side of the image DominantColors is shrunk to before
+// counting colours.
+const paletteSize = 64
+
+// paletteSeparation is how far apart, in sRGB, the colours DominantColors
+// returns are at least, so shades of one colour don't crowd others out.
+const paletteSeparation = 48
+
+// DominantColors returns up to n colours covering most of buf, commonest
+// first, for placeholder backgrounds and theming. The image is shrunk and
+// its colours counted in bins of 16 levels per channel, each bin giving
+// the mean of its pixels. Transparent pixels don't count, and images with
+// few distinct colours give fewer than n.
+func DominantColors(buf []byte, n int) ([]color.RGBA, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("%w: %d colours", ErrInvalidOption, n)
+	}
+
+	release, err := acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	image, _, err := resizeImage(buf, Options{Width: paletteSize, Height: paletteSize})
+	if err != nil {
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	pixels, err := vipsRGBA(image)
+	if err != nil {
+		return nil, err
+	}
+	return dominantColors(pixels, n), nil
+}
+
+// colourBin sums the pixels of one bin.
+type colourBin struct {
+	key     int
+	count   int
+	r, g, b int
+}
+
+func (b colourBin) mean() color.RGBA {
+	return color.RGBA{uint8(b.r / b.count), uint8(b.g / b.count), uint8(b.b / b.count), 0xff}
+}
+
+// dominantColors picks up to n colours from RGBA pixels.
+func dominantColors(pixels []byte, n int) []color.RGBA {
+	bins := map[int]*colourBin{}
+	for i := 0; i+3 < len(pixels); i += 4 {
+		r, g, b, a := pixels[i], pixels[i+1], pixels[i+2], pixels[i+3]
+		if a < 0x80 {
+			continue
+		}
+		key := int(r>>4)<<8 | int(g>>4)<<4 | int(b>>4)
+		bin := bins[key]
+		if bin == nil {
+			bin = &colourBin{key: key}
+			bins[key] = bin
+		}
+		bin.count++
+		bin.r += int(r)
+		bin.g += int(g)
+		bin.b += int(b)
+	}
+
+	sorted := make([]colourBin, 0, len(bins))
+	for _, bin := range bins {
+		sorted = append(sorted, *bin)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		// for the same output on every run
+		return sorted[i].key < sorted[j].key
+	})
+
+	var colours []color.RGBA
+	for _, bin := range sorted {
+		c := bin.mean()
+		if nearColour(c, colours) {
+			continue
+		}
+		if colours = append(colours, c); len(colours) == n {
+			break
+		}
+	}
+	return colours
+}
+
+// nearColour reports whether c is within paletteSeparation of any of
+// colours.
+func nearColour(c color.RGBA, colours []color.RGBA) bool {
+	for _, o := range colours {
+		dr, dg, db := int(c.R)-int(o.R), int(c.G)-int(o.G), int(c.B)-int(o.B)
+		if dr*dr+dg*dg+db*db < paletteSeparation*paletteSeparation {
+			return true
+		}
+	}
+	return false
+}
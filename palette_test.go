@@ -0,0 +1,65 @@
+package vips
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+func TestDominantColorsOf(t *testing.T) {
+	pixel := func(c color.RGBA, times int) []byte {
+		return bytes.Repeat([]byte{c.R, c.G, c.B, c.A}, times)
+	}
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	darkRed := color.RGBA{0xd8, 0, 0, 0xff}
+	blue := color.RGBA{0, 0, 0xff, 0xff}
+	var pixels []byte
+	pixels = append(pixels, pixel(red, 10)...)
+	pixels = append(pixels, pixel(darkRed, 8)...)
+	pixels = append(pixels, pixel(blue, 5)...)
+	pixels = append(pixels, pixel(color.RGBA{0, 0xff, 0, 0}, 50)...)
+
+	var testCases = []struct {
+		n       int
+		colours []color.RGBA
+	}{
+		{1, []color.RGBA{red}},
+		// dark red is too near red, and transparent green doesn't count
+		{3, []color.RGBA{red, blue}},
+	}
+
+	for index, tc := range testCases {
+		if colours := dominantColors(pixels, tc.n); !reflect.DeepEqual(colours, tc.colours) {
+			t.Errorf("%d. dominantColors(%d) => %v, want %v", index, tc.n, colours, tc.colours)
+		}
+	}
+}
+
+func TestDominantColors(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{0x20, 0x40, 0xc0, 0xff}}, image.ZP, draw.Src)
+	draw.Draw(img, image.Rect(150, 0, 200, 100), &image.Uniform{color.RGBA{0xf0, 0xf0, 0x10, 0xff}}, image.ZP, draw.Src)
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	colours, err := DominantColors(buf.Bytes(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the edge between them blurs as the image shrinks
+	want := []color.RGBA{{0x20, 0x40, 0xc0, 0xff}, {0xf0, 0xf0, 0x10, 0xff}}
+	if len(colours) != len(want) || !nearColour(colours[0], want[:1]) || !nearColour(colours[1], want[1:]) {
+		t.Errorf("DominantColors() => %v, want %v", colours, want)
+	}
+
+	if _, err := DominantColors(buf.Bytes(), 0); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("DominantColors(0) => %v, want ErrInvalidOption", err)
+	}
+}
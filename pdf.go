@@ -0,0 +1,103 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"errors"
+)
+
+// PDFMode is the colour depth of PDF pages.
+type PDFMode int
+
+const (
+	// PDF_GREY keeps 256 grey levels, for photos and forms.
+	PDF_GREY PDFMode = iota
+	// PDF_BILEVEL thresholds pages to pure black and white, the most
+	// compact choice for text.
+	PDF_BILEVEL
+)
+
+// PDFOptions describes a PDF written by PDF.
+type PDFOptions struct {
+	Mode PDFMode
+	// Threshold is the grey level, 1-255, at and above which PDF_BILEVEL
+	// pages turn white, 128 by default.
+	Threshold int
+}
+
+// PDF writes the page images as a grey or black and white PDF, one page
+// each, applying embedded ICC profiles before converting to grey. Pages
+// smaller than the largest one are centred on white. It needs libvips
+// built with ImageMagick.
+func PDF(pages [][]byte, o PDFOptions) ([]byte, error) {
+	if len(pages) == 0 {
+		return nil, errors.New("vips: PDF needs pages")
+	}
+	if o.Threshold == 0 {
+		o.Threshold = 128
+	}
+
+	release, err := acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	images := make([]*C.struct__VipsImage, 0, len(pages))
+	unref := func() {
+		for _, image := range images {
+			if image != nil {
+				C.g_object_unref(C.gpointer(image))
+			}
+		}
+	}
+
+	width, height := 0, 0
+	for _, buf := range pages {
+		if len(buf) == 0 {
+			unref()
+			return nil, errors.New("vips: empty page")
+		}
+		image, _, err := loadBuffer(buf, DefaultLimits, false)
+		if err == nil {
+			image, err = vipsGreyPage(image, o.Mode == PDF_BILEVEL, o.Threshold)
+		}
+		if err != nil {
+			unref()
+			return nil, err
+		}
+		images = append(images, image)
+
+		if w := int(image.Xsize); w > width {
+			width = w
+		}
+		if h := int(image.Ysize); h > height {
+			height = h
+		}
+	}
+
+	for i, image := range images {
+		w, h := int(image.Xsize), int(image.Ysize)
+		if w == width && h == height {
+			continue
+		}
+		images[i] = nil
+		padded, err := vipsEmbed(image, (width-w)/2, (height-h)/2, width, height, EXTEND_WHITE)
+		if err != nil {
+			unref()
+			return nil, err
+		}
+		images[i] = padded
+	}
+
+	return vipsPDFSavePages(images)
+}
@@ -0,0 +1,32 @@
+package vips
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestPDF(t *testing.T) {
+	first, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ioutil.ReadFile("testdata/6.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []PDFMode{PDF_GREY, PDF_BILEVEL} {
+		out, err := PDF([][]byte{first, second}, PDFOptions{Mode: mode})
+		if err != nil {
+			t.Skip("no PDF support in this libvips:", err)
+		}
+		if !bytes.HasPrefix(out, []byte("%PDF")) {
+			t.Errorf("PDF(%d) did not write a PDF", mode)
+		}
+	}
+
+	if _, err := PDF(nil, PDFOptions{}); err == nil {
+		t.Errorf("PDF() without pages did not fail")
+	}
+}
@@ -0,0 +1,94 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import "math/bits"
+
+// phashSize is the side of the image PHash is shrunk to before hashing;
+// JPEG sources shrink on load towards it.
+const phashSize = 64
+
+// PHash returns a perceptual hash of buf, the difference hash of its grey
+// squashed to 9x8: each bit tells whether a pixel is darker than the one
+// to its right. Copies resized, recompressed or slightly retouched hash
+// alike; compare hashes with PHashDistance.
+func PHash(buf []byte) (uint64, error) {
+	release, err := acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	image, _, err := resizeImage(buf, Options{Width: phashSize, Height: phashSize, Enlarge: true})
+	if err != nil {
+		return 0, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	return dHash(image)
+}
+
+// PHash is PHash of the source of i, so images loaded to be resized can be
+// hashed without decoding them again.
+func (i *Image) PHash() (uint64, error) {
+	release, err := acquire()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	image, err := i.ref()
+	if err != nil {
+		return 0, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	return dHash(image)
+}
+
+// PHashDistance is the number of bits in which a and b differ: 0 for
+// copies, up to about 10 for near duplicates, around 32 for unrelated
+// images.
+func PHashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// dHash is the difference hash of image, which it keeps.
+func dHash(image *C.struct__VipsImage) (uint64, error) {
+	pixels, err := vipsGreyPixels(image, 9, 8)
+	if err != nil {
+		return 0, err
+	}
+	return dHashOf(pixels), nil
+}
+
+// dHashOf hashes 9x8 grey pixels, a bit for each pixel darker than the
+// next, row by row from the top bit.
+func dHashOf(pixels []byte) uint64 {
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		row := pixels[y*9 : y*9+9]
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if row[x] < row[x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
@@ -0,0 +1,74 @@
+package vips
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestDHashOf(t *testing.T) {
+	pixels := make([]byte, 9*8)
+	for i := range pixels {
+		pixels[i] = byte(i % 9)
+	}
+	if hash := dHashOf(pixels); hash != ^uint64(0) {
+		t.Errorf("dHashOf(rising) => %x, want all ones", hash)
+	}
+
+	// only the last pixel of the first row rises
+	pixels = make([]byte, 9*8)
+	pixels[8] = 1
+	if hash := dHashOf(pixels); hash != 1<<56 {
+		t.Errorf("dHashOf() => %x, want %x", hash, uint64(1)<<56)
+	}
+}
+
+func TestPHash(t *testing.T) {
+	gradient := func(width, height int, mirror bool) []byte {
+		img := image.NewGray(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				v := x * 255 / width
+				if mirror {
+					v = 255 - v
+				}
+				img.SetGray(x, y, color.Gray{uint8(v)})
+			}
+		}
+		buf := new(bytes.Buffer)
+		if err := png.Encode(buf, img); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	a, err := PHash(gradient(300, 200, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := PHash(gradient(150, 100, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := PHash(gradient(300, 200, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := PHashDistance(a, b); d > 4 {
+		t.Errorf("PHashDistance() of a resized copy => %d", d)
+	}
+	if d := PHashDistance(a, c); d < 32 {
+		t.Errorf("PHashDistance() of a mirrored copy => %d", d)
+	}
+
+	img, err := Load(gradient(300, 200, false), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer img.Close()
+	if hash, err := img.PHash(); err != nil || PHashDistance(hash, a) > 4 {
+		t.Errorf("Image.PHash() => %x, %v, want near %x", hash, err, a)
+	}
+}
@@ -0,0 +1,165 @@
+package vips
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Step is one operation of a pipeline spec, named by Op, with the fields
+// that operation reads:
+//
+//	resize     width, height, fit (contain, cover or pad), gravity
+//	           (centre, north, east, south or west) and enlarge
+//	rotate     degrees clockwise
+//	flip, flop mirror left to right or top to bottom
+//	sharpen    and blur, with a 3x3 kernel
+//	grayscale  converts to black and white
+//	grain      sigma and seed, see Options.Grain
+//	watermark  payload, key and strength, see Watermark
+//	convert    format, such as "webp", and quality, 1-100 or "auto"
+type Step struct {
+	Op string `json:"op"`
+
+	Width   int    `json:"width,omitempty"`
+	Height  int    `json:"height,omitempty"`
+	Fit     string `json:"fit,omitempty"`
+	Gravity string `json:"gravity,omitempty"`
+	Enlarge bool   `json:"enlarge,omitempty"`
+
+	Degrees float64 `json:"degrees,omitempty"`
+
+	Sigma float64 `json:"sigma,omitempty"`
+	Seed  int64   `json:"seed,omitempty"`
+
+	Payload  uint32  `json:"payload,omitempty"`
+	Key      string  `json:"key,omitempty"`
+	Strength float64 `json:"strength,omitempty"`
+
+	Format  string          `json:"format,omitempty"`
+	Quality json.RawMessage `json:"quality,omitempty"`
+}
+
+var gravityNames = map[string]Gravity{
+	"":       CENTRE,
+	"centre": CENTRE,
+	"center": CENTRE,
+	"north":  NORTH,
+	"east":   EAST,
+	"south":  SOUTH,
+	"west":   WEST,
+}
+
+// ApplyPipeline transforms buf as spec, a JSON list of Steps, describes,
+// so presets can live in configuration:
+//
+//	[{"op": "resize", "width": 800, "fit": "cover"},
+//	 {"op": "sharpen"},
+//	 {"op": "convert", "format": "webp", "quality": 80}]
+//
+// The steps become the Options of one Resize, decoding and encoding once,
+// so they run in the order Resize has for them rather than that of the
+// list, and each operation may appear only once.
+func ApplyPipeline(buf []byte, spec []byte) ([]byte, error) {
+	o, err := ParsePipeline(spec)
+	if err != nil {
+		return nil, err
+	}
+	return Resize(buf, o)
+}
+
+// ParsePipeline turns a pipeline spec, as ApplyPipeline takes, into
+// Options.
+func ParsePipeline(spec []byte) (Options, error) {
+	var steps []Step
+	dec := json.NewDecoder(bytes.NewReader(spec))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&steps); err != nil {
+		return Options{}, fmt.Errorf("%w: pipeline: %v", ErrInvalidOption, err)
+	}
+
+	var o Options
+	seen := map[string]bool{}
+	for i, s := range steps {
+		op := strings.ToLower(s.Op)
+		// sharpen and blur both set the kernel
+		slot := op
+		if op == "blur" {
+			slot = "sharpen"
+		}
+		if seen[slot] {
+			return Options{}, fmt.Errorf("%w: pipeline step %d: %s repeats an earlier step", ErrInvalidOption, i, s.Op)
+		}
+		seen[slot] = true
+
+		if err := s.apply(op, &o); err != nil {
+			return Options{}, fmt.Errorf("%w: pipeline step %d: %v", ErrInvalidOption, i, err)
+		}
+	}
+
+	return o, o.Validate()
+}
+
+// apply sets the Options of operation op from s.
+func (s Step) apply(op string, o *Options) error {
+	switch op {
+	case "resize":
+		o.Width, o.Height, o.Enlarge = s.Width, s.Height, s.Enlarge
+		switch s.Fit {
+		case "", "contain":
+		case "cover":
+			o.Crop = true
+		case "pad":
+			o.Embed = true
+		default:
+			return fmt.Errorf("unknown fit %q", s.Fit)
+		}
+		g, ok := gravityNames[strings.ToLower(s.Gravity)]
+		if !ok {
+			return fmt.Errorf("unknown gravity %q", s.Gravity)
+		}
+		o.Gravity = g
+	case "rotate":
+		degrees := math.Mod(s.Degrees, 360)
+		if degrees < 0 {
+			degrees += 360
+		}
+		if degrees == math.Trunc(degrees) && int(degrees)%90 == 0 {
+			o.Rotate = Angle(degrees)
+		} else {
+			o.RotateDegrees = degrees
+		}
+	case "flip":
+		o.Flip = true
+	case "flop":
+		o.Flop = true
+	case "sharpen":
+		o.Convolve = KERNEL_SHARPEN
+	case "blur":
+		o.Convolve = KERNEL_BOX_BLUR
+	case "grayscale", "greyscale":
+		o.Colourspace = COLOURSPACE_B_W
+	case "grain":
+		o.Grain, o.Seed = s.Sigma, s.Seed
+	case "watermark":
+		o.Watermark = &Watermark{Payload: s.Payload, Key: s.Key, Strength: s.Strength}
+	case "convert":
+		if s.Format != "" {
+			if o.Savetype = TypeOfExt(s.Format); o.Savetype == UNKNOWN {
+				return fmt.Errorf("unknown format %q", s.Format)
+			}
+		}
+		if len(s.Quality) > 0 {
+			if string(s.Quality) == `"auto"` {
+				o.Quality = QUALITY_AUTO
+			} else if err := json.Unmarshal(s.Quality, &o.Quality); err != nil || o.Quality < 1 {
+				return fmt.Errorf("bad quality %s", s.Quality)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown operation %q", s.Op)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package vips
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestParsePipeline(t *testing.T) {
+	o, err := ParsePipeline([]byte(`[
+		{"op": "resize", "width": 800, "height": 600, "fit": "cover", "gravity": "north"},
+		{"op": "sharpen"},
+		{"op": "rotate", "degrees": -90},
+		{"op": "watermark", "payload": 7, "key": "k"},
+		{"op": "convert", "format": "webp", "quality": "auto"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Width != 800 || o.Height != 600 || !o.Crop || o.Gravity != NORTH || o.Convolve.Width != 3 ||
+		o.Rotate != D270 || o.Watermark == nil || o.Watermark.Payload != 7 || o.Savetype != WEBP || o.Quality != QUALITY_AUTO {
+		t.Errorf("ParsePipeline() = %+v", o)
+	}
+
+	if o, err := ParsePipeline([]byte(`[{"op": "rotate", "degrees": 45}, {"op": "convert", "quality": 80}]`)); err != nil || o.RotateDegrees != 45 || o.Quality != 80 {
+		t.Errorf("ParsePipeline() = %+v, %v", o, err)
+	}
+
+	for _, bad := range []string{
+		`{"op": "resize"}`,
+		`[{"op": "explode"}]`,
+		`[{"op": "resize", "widht": 10}]`,
+		`[{"op": "resize", "fit": "stretch"}]`,
+		`[{"op": "resize", "width": -1}]`,
+		`[{"op": "sharpen"}, {"op": "blur"}]`,
+		`[{"op": "resize"}, {"op": "resize"}]`,
+		`[{"op": "convert", "format": "doc"}]`,
+		`[{"op": "convert", "quality": 101}]`,
+		`[{"op": "convert", "quality": "best"}]`,
+	} {
+		if _, err := ParsePipeline([]byte(bad)); !errors.Is(err, ErrInvalidOption) && !errors.Is(err, ErrInvalidDimensions) {
+			t.Errorf("ParsePipeline(%s) = %v, want an invalid option", bad, err)
+		}
+	}
+}
+
+func TestApplyPipeline(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ApplyPipeline(buf, []byte(`[{"op": "resize", "width": 100, "height": 100, "fit": "cover"}, {"op": "convert", "format": "png"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := Size(out); err != nil || m.Format != PNG || m.Width != 100 || m.Height != 100 {
+		t.Errorf("ApplyPipeline() => %+v, %v", m, err)
+	}
+}
@@ -0,0 +1,95 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// Gradient is a four-corner colour placeholder for an image, a cheap
+// CSS-friendly alternative to BlurHash.
+type Gradient struct {
+	// Width and Height keep the aspect ratio of the source image.
+	Width       int
+	Height      int
+	TopLeft     color.RGBA
+	TopRight    color.RGBA
+	BottomLeft  color.RGBA
+	BottomRight color.RGBA
+}
+
+// Placeholder derives a Gradient from the dominant colour of each corner
+// of buf.
+func Placeholder(buf []byte) (Gradient, error) {
+	release, err := acquire()
+	if err != nil {
+		return Gradient{}, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	image, _, err := resizeImage(buf, Options{Width: 64, Height: 64, Enlarge: true})
+	if err != nil {
+		return Gradient{}, err
+	}
+
+	g := Gradient{Width: int(image.Xsize), Height: int(image.Ysize)}
+
+	colours, err := vipsQuadrantColours(image)
+	if err != nil {
+		return Gradient{}, err
+	}
+
+	g.TopLeft, g.TopRight, g.BottomLeft, g.BottomRight = colours[0], colours[1], colours[2], colours[3]
+	return g, nil
+}
+
+// SVG renders g as a small stretchable SVG document: a horizontal gradient
+// between the top corners faded vertically into one between the bottom
+// corners.
+func (g Gradient) SVG() string {
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %[1]d %[2]d" preserveAspectRatio="none">`+
+		`<defs>`+
+		`<linearGradient id="t"><stop offset="0" stop-color="%[3]s"/><stop offset="1" stop-color="%[4]s"/></linearGradient>`+
+		`<linearGradient id="b"><stop offset="0" stop-color="%[5]s"/><stop offset="1" stop-color="%[6]s"/></linearGradient>`+
+		`<linearGradient id="f" x2="0" y2="1"><stop offset="0" stop-color="#fff" stop-opacity="0"/><stop offset="1" stop-color="#fff"/></linearGradient>`+
+		`<mask id="m"><rect width="%[1]d" height="%[2]d" fill="url(#f)"/></mask>`+
+		`</defs>`+
+		`<rect width="%[1]d" height="%[2]d" fill="url(#t)"/>`+
+		`<rect width="%[1]d" height="%[2]d" fill="url(#b)" mask="url(#m)"/>`+
+		`</svg>`,
+		g.Width, g.Height,
+		hexColor(g.TopLeft), hexColor(g.TopRight), hexColor(g.BottomLeft), hexColor(g.BottomRight))
+}
+
+// PNG renders g as a 2x2 PNG; browsers smooth it into a gradient when it
+// is scaled up to the size of the image.
+func (g Gradient) PNG() ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.SetRGBA(0, 0, g.TopLeft)
+	img.SetRGBA(1, 0, g.TopRight)
+	img.SetRGBA(0, 1, g.BottomLeft)
+	img.SetRGBA(1, 1, g.BottomRight)
+
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
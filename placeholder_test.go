@@ -0,0 +1,55 @@
+package vips
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestGradient(t *testing.T) {
+	g := Gradient{
+		Width:       4,
+		Height:      3,
+		TopLeft:     color.RGBA{0xff, 0, 0, 0xff},
+		TopRight:    color.RGBA{0, 0xff, 0, 0xff},
+		BottomLeft:  color.RGBA{0, 0, 0xff, 0xff},
+		BottomRight: color.RGBA{0x12, 0x34, 0x56, 0xff},
+	}
+
+	svg := g.SVG()
+	for _, want := range []string{`viewBox="0 0 4 3"`, "#ff0000", "#00ff00", "#0000ff", "#123456"} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("Gradient.SVG() => %s, missing %s", svg, want)
+		}
+	}
+
+	buf, err := g.PNG()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r != 0xffff {
+		t.Errorf("Gradient.PNG() top left => %v, want red", img.At(0, 0))
+	}
+}
+
+func TestPlaceholder(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := Placeholder(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Width != 64 && g.Height != 64 {
+		t.Errorf("Placeholder() => %dx%d, want one side of 64", g.Width, g.Height)
+	}
+}
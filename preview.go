@@ -0,0 +1,72 @@
+package vips
+
+import (
+	"math"
+)
+
+// previewShrink is how much smaller than the output previews are. JPEG
+// sources shrunk by 8 are decoded from their DC coefficients alone.
+const previewShrink = 8
+
+// previewQuality is the encoder quality of previews.
+const previewQuality = 50
+
+// ResizeProgressive is ResizeWithInfo for progressive loading in UIs.
+// Alongside the full transform it renders o at an eighth of the size and
+// low quality, which for JPEG sources takes little more than the header,
+// and hands that to preview while the full transform runs. A failing
+// preview is skipped.
+func ResizeProgressive(buf []byte, o Options, preview func(Result)) (Result, error) {
+	var r Result
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r, err = ResizeWithInfo(buf, o)
+	}()
+
+	if p, perr := renderPreview(buf, o); perr == nil {
+		preview(p)
+	}
+
+	<-done
+	return r, err
+}
+
+func renderPreview(buf []byte, o Options) (p Result, err error) {
+	po, err := previewOptions(buf, o)
+	if err != nil {
+		return Result{}, err
+	}
+	debug("%#+v", po)
+	defer audit("preview", buf, po)(&p.Buf, &err)
+
+	return resize(buf, po)
+}
+
+// previewOptions scales o down for a preview of buf.
+func previewOptions(buf []byte, o Options) (Options, error) {
+	width, height := o.Width, o.Height
+	if width == 0 && height == 0 {
+		m, err := Size(buf)
+		if err != nil {
+			return o, err
+		}
+		width = m.Width
+	}
+
+	o.Width = previewSize(width)
+	o.Height = previewSize(height)
+	o.Quality = previewQuality
+	o.JPEG.Quality = 0
+	o.WebP.Quality = 0
+	o.WebP.Lossless = false
+	return o, nil
+}
+
+func previewSize(n int) int {
+	if n == 0 {
+		return 0
+	}
+	return int(math.Max(1, math.Ceil(float64(n)/previewShrink)))
+}
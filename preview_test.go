@@ -0,0 +1,39 @@
+package vips
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestResizeProgressive(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var previews []Result
+	r, err := ResizeProgressive(buf, Options{Width: 400}, func(p Result) {
+		previews = append(previews, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Width != 400 {
+		t.Errorf("ResizeProgressive() => %d wide, want 400", r.Width)
+	}
+	if len(previews) != 1 || previews[0].Width != 50 || previews[0].Size >= r.Size {
+		t.Errorf("ResizeProgressive() previews = %+v, want one 50 wide and smaller", previews)
+	}
+
+	if _, err := ResizeProgressive(nil, Options{}, func(Result) { t.Errorf("preview of nothing") }); err == nil {
+		t.Errorf("ResizeProgressive(nil) did not fail")
+	}
+}
+
+func TestPreviewSize(t *testing.T) {
+	for in, want := range map[int]int{0: 0, 1: 1, 8: 1, 9: 2, 800: 100} {
+		if got := previewSize(in); got != want {
+			t.Errorf("previewSize(%d) = %d, want %d", in, got, want)
+		}
+	}
+}
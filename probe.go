@@ -0,0 +1,84 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+// ImageMetadata is what the header of an image says about it.
+type ImageMetadata struct {
+	// Width and Height are as displayed, after the EXIF orientation, so
+	// portrait phone photos stored sideways come out portrait.
+	Width, Height int
+	// RawWidth and RawHeight are as stored, before the orientation.
+	RawWidth, RawHeight int
+	// Pages counts the frames of animated or multi-page images, 1 for
+	// everything else.
+	Pages  int
+	Format ImageType
+	// Orientation is the EXIF orientation, 1-8, or 0 when there is none.
+	Orientation int
+	Channels    int
+	HasAlpha    bool
+	// HasC2PA reports a Content Credentials manifest, see Options.KeepC2PA.
+	HasC2PA bool
+}
+
+// Size reads the header of buf without decoding any pixels, which is far
+// cheaper than Resize for validating uploads.
+func Size(buf []byte) (ImageMetadata, error) {
+	if len(buf) == 0 {
+		return ImageMetadata{}, ErrEmptyBuffer
+	}
+
+	release, err := acquire()
+	if err != nil {
+		return ImageMetadata{}, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	image, err := loadBufferAuto(buf, false)
+	if err != nil {
+		return ImageMetadata{}, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	m := ImageMetadata{
+		Width:       int(image.Xsize),
+		Height:      int(image.Ysize),
+		Pages:       1,
+		Format:      detectType(buf),
+		Orientation: vipsExifOrientation(image),
+		Channels:    int(image.Bands),
+		HasAlpha:    C.vips_image_hasalpha(image) != 0,
+		HasC2PA:     c2paOf(buf) != nil,
+	}
+	if pages, ok := vipsImageInt(image, "n-pages"); ok && pages > 0 {
+		m.Pages = pages
+	}
+	// for animations the header height is that of a single frame
+	if m.Pages > 1 {
+		if height, ok := vipsImageInt(image, "page-height"); ok && height > 0 {
+			m.Height = height
+		}
+	}
+	m.RawWidth, m.RawHeight = m.Width, m.Height
+	m.Width, m.Height = orientedSize(m.Width, m.Height, m.Orientation)
+
+	return m, nil
+}
+
+// orientedSize is the size a width x height image stored with the EXIF
+// orientation is displayed at: orientations 5-8 turn it by 90 degrees.
+func orientedSize(width, height, orientation int) (int, int) {
+	if orientation >= 5 && orientation <= 8 {
+		return height, width
+	}
+	return width, height
+}
@@ -0,0 +1,73 @@
+package vips
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"testing"
+)
+
+func TestSize(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, _, err := image.DecodeConfig(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Size(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Width != decoded.Width || m.Height != decoded.Height || m.Format != JPEG || m.Pages != 1 || m.HasAlpha {
+		t.Errorf("Size() = %+v, want %dx%d jpeg", m, decoded.Width, decoded.Height)
+	}
+
+	alpha := new(bytes.Buffer)
+	if err := png.Encode(alpha, image.NewNRGBA(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatal(err)
+	}
+	m, err = Size(alpha.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Width != 3 || m.Height != 2 || m.Channels != 4 || !m.HasAlpha || m.Format != PNG {
+		t.Errorf("Size() of an RGBA PNG = %+v", m)
+	}
+
+	for _, bad := range [][]byte{nil, []byte("not an image")} {
+		if _, err := Size(bad); err == nil {
+			t.Errorf("Size(%q) did not fail", bad)
+		}
+	}
+}
+
+func TestSizeOrientation(t *testing.T) {
+	jpg := new(bytes.Buffer)
+	if err := jpeg.Encode(jpg, image.NewRGBA(image.Rect(0, 0, 40, 20)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		orientation   uint16
+		width, height int
+	}{
+		{1, 40, 20},
+		{3, 40, 20},
+		{6, 20, 40},
+		{8, 20, 40},
+	}
+	for _, c := range cases {
+		m, err := Size(jpegWithOrientation(jpg.Bytes(), int(c.orientation)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Orientation != int(c.orientation) || m.Width != c.width || m.Height != c.height || m.RawWidth != 40 || m.RawHeight != 20 {
+			t.Errorf("Size() with orientation %d = %+v, want %dx%d", c.orientation, m, c.width, c.height)
+		}
+	}
+}
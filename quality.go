@@ -0,0 +1,93 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import "math"
+
+// QUALITY_AUTO as Options.Quality picks the JPEG or WebP quality per image.
+const QUALITY_AUTO = -1
+
+const (
+	// the range QUALITY_AUTO searches
+	AUTO_QUALITY_MIN = 30
+	AUTO_QUALITY_MAX = 95
+	// DEFAULT_TARGET_PSNR is the TargetPSNR used when it is zero.
+	DEFAULT_TARGET_PSNR = 40.0
+)
+
+// autoQuality reports whether saving as f searches for the quality, which
+// the JPEG and WebP encoders do for QUALITY_AUTO unless their own options
+// set one. Lossless WebP has no quality to search.
+func autoQuality(f *Format, o Options) bool {
+	if o.Quality != QUALITY_AUTO {
+		return false
+	}
+	switch f.Type {
+	case JPEG:
+		return o.JPEG.Quality == 0
+	case WEBP:
+		return o.WebP.Quality == 0 && !o.WebP.Lossless
+	}
+	return false
+}
+
+// saveAutoQuality encodes image as f at the lowest quality whose decoded
+// result keeps o.TargetPSNR, by binary search between AUTO_QUALITY_MIN and
+// AUTO_QUALITY_MAX. Images no quality in range satisfies are saved at
+// AUTO_QUALITY_MAX. It does not release image.
+func saveAutoQuality(image *C.struct__VipsImage, f *Format, o Options) ([]byte, error) {
+	target := o.TargetPSNR
+	if target == 0 {
+		target = DEFAULT_TARGET_PSNR
+	}
+
+	// every attempt reads the pixels again
+	ref := C.vips_image_copy_memory(image)
+	if ref == nil {
+		return nil, catchVipsError()
+	}
+	defer C.g_object_unref(C.gpointer(ref))
+
+	// attempts are kept until a better one is found, so each gets its own
+	// buffer
+	o.into = nil
+	encode := func(quality int) ([]byte, float64, error) {
+		o.Quality = quality
+		buf, err := f.save(ref, saveDefaults(o))
+		if err != nil {
+			return nil, 0, err
+		}
+		decoded, err := f.load(buf, false)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer C.g_object_unref(C.gpointer(decoded))
+
+		psnr, err := vipsPSNR(ref, decoded)
+		return buf, psnr, err
+	}
+
+	var best []byte
+	for lo, hi := AUTO_QUALITY_MIN, AUTO_QUALITY_MAX; lo <= hi; {
+		q := (lo + hi) / 2
+		buf, psnr, err := encode(q)
+		if err != nil {
+			return nil, err
+		}
+		debug("auto quality %d: %.2f dB", q, psnr)
+		if psnr >= target || math.IsInf(psnr, 1) {
+			best, hi = buf, q-1
+		} else {
+			lo = q + 1
+		}
+	}
+	if best != nil {
+		return best, nil
+	}
+
+	buf, _, err := encode(AUTO_QUALITY_MAX)
+	return buf, err
+}
@@ -0,0 +1,52 @@
+package vips
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestQualityAuto(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, typ := range []ImageType{JPEG, WEBP} {
+		best, err := Resize(buf, Options{Width: 200, Savetype: typ, Quality: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		low, err := Resize(buf, Options{Width: 200, Savetype: typ, Quality: QUALITY_AUTO, TargetPSNR: 30})
+		if err != nil {
+			t.Fatal(err)
+		}
+		high, err := Resize(buf, Options{Width: 200, Savetype: typ, Quality: QUALITY_AUTO, TargetPSNR: 45})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if detectType(low) != typ {
+			t.Errorf("auto quality %v wrote %v", typ, detectType(low))
+		}
+		if len(low) >= len(best) || len(low) > len(high) {
+			t.Errorf("%v auto quality sizes: %d at 30 dB, %d at 45 dB, %d at quality 100", typ, len(low), len(high), len(best))
+		}
+	}
+
+	// PNG has no quality to search
+	if _, err := Resize(buf, Options{Width: 50, Savetype: PNG, Quality: QUALITY_AUTO}); err != nil {
+		t.Errorf("Resize() to PNG with auto quality = %v", err)
+	}
+}
+
+func TestQualityAutoValidate(t *testing.T) {
+	if err := (Options{Quality: QUALITY_AUTO}).Validate(); err != nil {
+		t.Errorf("Validate() of QUALITY_AUTO = %v", err)
+	}
+	for _, o := range []Options{{Quality: -2}, {Quality: QUALITY_AUTO, TargetPSNR: -1}} {
+		if err := o.Validate(); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Validate(%+v) = %v, want %v", o, err, ErrInvalidOption)
+		}
+	}
+}
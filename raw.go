@@ -0,0 +1,162 @@
+//go:build libraw
+// +build libraw
+
+package vips
+
+/*
+#cgo pkg-config: libraw
+#include <libraw/libraw.h>
+#include <vips/vips.h>
+
+// raw_identify reports whether libraw recognises buf as a raw file.
+static int
+raw_identify(void *buf, size_t len) {
+	libraw_data_t *raw;
+	int err;
+
+	if (!(raw = libraw_init(0))) {
+		return 0;
+	}
+	err = libraw_open_buffer(raw, buf, len);
+	libraw_close(raw);
+	return err == LIBRAW_SUCCESS;
+}
+
+// raw_develop demosaics buf into an 8 bit sRGB image. libraw only holds
+// on to buf until it returns.
+static VipsImage *
+raw_develop(void *buf, size_t len, int half_size, int auto_wb, int quality, double exp_shift, int *err) {
+	libraw_data_t *raw;
+	libraw_processed_image_t *mem = NULL;
+	VipsImage *image;
+
+	if (!(raw = libraw_init(0))) {
+		*err = LIBRAW_UNSUFFICIENT_MEMORY;
+		return NULL;
+	}
+	raw->params.output_bps = 8;
+	raw->params.half_size = half_size;
+	raw->params.use_camera_wb = !auto_wb;
+	raw->params.use_auto_wb = auto_wb;
+	raw->params.user_qual = quality;
+	if (exp_shift != 1) {
+		raw->params.exp_correc = 1;
+		raw->params.exp_shift = exp_shift;
+	}
+
+	if (
+		(*err = libraw_open_buffer(raw, buf, len)) ||
+		(*err = libraw_unpack(raw)) ||
+		(*err = libraw_dcraw_process(raw)) ||
+		!(mem = libraw_dcraw_make_mem_image(raw, err))
+	) {
+		libraw_close(raw);
+		return NULL;
+	}
+
+	image = vips_image_new_from_memory_copy(mem->data, mem->data_size, mem->width, mem->height, mem->colors, VIPS_FORMAT_UCHAR);
+	libraw_dcraw_clear_mem(mem);
+	libraw_close(raw);
+	return image;
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"sync"
+	"unsafe"
+)
+
+// Demosaic is the interpolation recovering full colour from the sensor's
+// colour filter array, from fastest to best.
+type Demosaic int
+
+const (
+	DEMOSAIC_LINEAR Demosaic = iota
+	DEMOSAIC_VNG
+	DEMOSAIC_PPG
+	DEMOSAIC_AHD
+)
+
+// RawOptions controls how raw camera files are developed.
+type RawOptions struct {
+	// HalfSize develops at half resolution without interpolating, several
+	// times faster and plenty for thumbnails.
+	HalfSize bool
+	// Demosaic is ignored with HalfSize.
+	Demosaic Demosaic
+	// Exposure corrects by this many stops, -2 to +3.
+	Exposure float64
+	// AutoWhiteBalance averages the image instead of using the white
+	// balance the camera recorded.
+	AutoWhiteBalance bool
+}
+
+// RAW is the type of raw camera files, such as DNG, CR2 and NEF, which are
+// loaded through libraw when the package is built with the libraw tag.
+// They can be read but not saved.
+var RAW ImageType
+
+var (
+	rawMu      sync.RWMutex
+	rawOptions = RawOptions{HalfSize: true}
+)
+
+// SetRawOptions sets how raw files are developed from now on. By default
+// they are developed at HalfSize for speed.
+func SetRawOptions(o RawOptions) {
+	rawMu.Lock()
+	rawOptions = o
+	rawMu.Unlock()
+}
+
+// rawMagic are the first bytes of raw formats. Most are TIFF based and
+// need a look by libraw to tell apart from plain TIFF.
+var rawMagic = [][]byte{
+	[]byte("II*\x00"),
+	[]byte("MM\x00*"),
+	[]byte("IIRO"),
+	[]byte("IIRS"),
+	[]byte("IIU\x00"),
+	[]byte("FUJIFILM"),
+}
+
+func matchRaw(buf []byte) bool {
+	for _, magic := range rawMagic {
+		if bytes.HasPrefix(buf, magic) {
+			return C.raw_identify(unsafe.Pointer(&buf[0]), C.size_t(len(buf))) != 0
+		}
+	}
+	return false
+}
+
+func loadRawBuffer(buf []byte, fail bool) (*C.struct__VipsImage, error) {
+	rawMu.RLock()
+	o := rawOptions
+	rawMu.RUnlock()
+
+	exposure := math.Max(-2, math.Min(o.Exposure, 3))
+
+	var errc C.int
+	image := C.raw_develop(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), cbool(o.HalfSize), cbool(o.AutoWhiteBalance), C.int(o.Demosaic), C.double(math.Exp2(exposure)), &errc)
+	if image == nil {
+		if errc != 0 {
+			return nil, errors.New("vips: libraw: " + C.GoString(C.libraw_strerror(errc)))
+		}
+		return nil, resizeError()
+	}
+	return image, nil
+}
+
+func init() {
+	RAW, _ = registerFormat(&Format{
+		Name:       "raw",
+		MIME:       "image/x-dcraw",
+		Extensions: []string{".dng", ".cr2", ".nef", ".arw", ".orf", ".rw2", ".raf"},
+		Match:      matchRaw,
+		load:       loadRawBuffer,
+	})
+}
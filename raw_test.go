@@ -0,0 +1,33 @@
+//go:build libraw
+// +build libraw
+
+package vips
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestRawFormat(t *testing.T) {
+	if RAW.String() != "raw" || TypeOfExt(".NEF") != RAW {
+		t.Errorf("raw format not registered: %v", RAW)
+	}
+
+	// a bare TIFF header is not a raw file
+	if matchRaw([]byte("II*\x00\x08\x00\x00\x00")) {
+		t.Errorf("matchRaw() accepted a TIFF header")
+	}
+
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detectType(buf) != JPEG {
+		t.Errorf("detectType() of a JPEG = %v", detectType(buf))
+	}
+
+	// raw files can't be saved, so they are written as JPEG
+	if f := saverOf(RAW); f.Type != JPEG {
+		t.Errorf("saverOf(RAW) = %v, want jpeg", f.Type)
+	}
+}
@@ -0,0 +1,107 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"fmt"
+)
+
+// LoadRaw makes an Image of a copy of data, width x height pixels of bands
+// samples of format each, row by row in native byte order, such as frames
+// from video decoders or camera feeds. One or two bands are taken as grey,
+// three or four as sRGB, the last band of two or four being alpha, and 16
+// bit samples as 16 bit grey or RGB.
+func LoadRaw(data []byte, width, height, bands int, format BandFormat) (*Image, error) {
+	cformat, ok := bandFormats[format]
+	if !ok || width < 1 || height < 1 || bands < 1 {
+		return nil, fmt.Errorf("%w: %dx%d pixels of %d bands of format %d", ErrInvalidDimensions, width, height, bands, format)
+	}
+	if size := width * height * bands * int(C.vips_format_sizeof(cformat)); len(data) != size {
+		return nil, fmt.Errorf("%w: %d bytes of pixels, want %d", ErrInvalidDimensions, len(data), size)
+	}
+
+	release, err := acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	image, err := vipsFromPixels(data, width, height, bands, format, rawInterpretation(bands, format))
+	if err != nil {
+		return nil, err
+	}
+	return newImage(image, sourceOf(image), UNKNOWN), nil
+}
+
+// rawInterpretation is how LoadRaw takes bands samples of format.
+func rawInterpretation(bands int, format BandFormat) C.VipsInterpretation {
+	deep := format == FORMAT_USHORT
+	switch {
+	case bands <= 2 && deep:
+		return C.VIPS_INTERPRETATION_GREY16
+	case bands <= 2:
+		return C.VIPS_INTERPRETATION_B_W
+	case bands <= 4 && deep:
+		return C.VIPS_INTERPRETATION_RGB16
+	case bands <= 4:
+		return C.VIPS_INTERPRETATION_sRGB
+	}
+	return C.VIPS_INTERPRETATION_MULTIBAND
+}
+
+// BandFormat is the format of the samples of i, FORMAT_DEFAULT once
+// closed or for formats LoadRaw does not take.
+func (i *Image) BandFormat() BandFormat {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.image == nil {
+		return FORMAT_DEFAULT
+	}
+	for f, c := range bandFormats {
+		if c == i.image.BandFmt {
+			return f
+		}
+	}
+	return FORMAT_DEFAULT
+}
+
+// ExportRaw copies out the pixels of i, as LoadRaw takes them: Width x
+// Height pixels of Bands samples of BandFormat each, row by row in native
+// byte order.
+func (i *Image) ExportRaw() ([]byte, error) {
+	release, err := acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	ref, err := i.ref()
+	if err != nil {
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(ref))
+
+	var length C.size_t
+	mem := C.vips_image_write_to_memory(ref, &length)
+	if mem == nil {
+		return nil, catchVipsError()
+	}
+	defer C.g_free(C.gpointer(mem))
+
+	return C.GoBytes(mem, C.int(length)), nil
+}
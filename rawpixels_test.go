@@ -0,0 +1,40 @@
+package vips
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestLoadRaw(t *testing.T) {
+	pixels := make([]byte, 16*8*3)
+	for i := range pixels {
+		pixels[i] = byte(i)
+	}
+
+	i, err := LoadRaw(pixels, 16, 8, 3, FORMAT_UCHAR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer i.Close()
+	if i.Width() != 16 || i.Height() != 8 || i.Bands() != 3 || i.BandFormat() != FORMAT_UCHAR {
+		t.Errorf("LoadRaw() => %dx%d, %d bands of %v", i.Width(), i.Height(), i.Bands(), i.BandFormat())
+	}
+
+	out, err := i.ExportRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, pixels) {
+		t.Error("ExportRaw() differs from the pixels loaded")
+	}
+
+	r, err := i.Resize(Options{Width: 8, Savetype: PNG})
+	if err != nil || r.Width != 8 || r.Height != 4 {
+		t.Errorf("Resize() of a LoadRaw() => %dx%d, %v", r.Width, r.Height, err)
+	}
+
+	if _, err := LoadRaw(pixels, 16, 8, 4, FORMAT_UCHAR); !errors.Is(err, ErrInvalidDimensions) {
+		t.Errorf("LoadRaw() of too few bytes = %v, want %v", err, ErrInvalidDimensions)
+	}
+}
@@ -0,0 +1,99 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"math"
+)
+
+// Rect is an area of an image in pixels.
+type Rect struct {
+	Left, Top, Width, Height int
+}
+
+// CropRegionProvider returns the region of a width x height source image
+// to keep, for example from a face or object detector. Errors abort the
+// operation.
+type CropRegionProvider func(width, height int) (Rect, error)
+
+// cropToRegion extracts the window around the region o.CropRegion picks
+// and releases image. With alignMCU the window is widened to start on a
+// JPEG block boundary.
+func cropToRegion(image *C.struct__VipsImage, o Options, alignMCU bool) (*C.struct__VipsImage, error) {
+	inWidth, inHeight := int(image.Xsize), int(image.Ysize)
+
+	r, err := o.CropRegion(inWidth, inHeight)
+	if err != nil {
+		C.g_object_unref(C.gpointer(image))
+		return nil, err
+	}
+
+	outWidth, outHeight := 0, 0
+	if o.Crop && o.Width > 0 && o.Height > 0 {
+		outWidth, outHeight = o.Width, o.Height
+	}
+	w := regionWindow(r, inWidth, inHeight, outWidth, outHeight)
+	if alignMCU {
+		mcuWidth, mcuHeight := mcuOf(image)
+		w = alignWindow(w, mcuWidth, mcuHeight)
+	}
+	debug("crop region %+v: %+v", r, w)
+
+	return vipsExtractArea(image, w.Left, w.Top, w.Width, w.Height)
+}
+
+// regionWindow clamps r to an inWidth x inHeight image. Given an output
+// size, it widens r around its centre to the output's shape, and to at
+// least its size so the region is not enlarged, as far as the image
+// allows.
+func regionWindow(r Rect, inWidth, inHeight, outWidth, outHeight int) Rect {
+	// clamp
+	left := clampInt(r.Left, 0, inWidth-1)
+	top := clampInt(r.Top, 0, inHeight-1)
+	right := clampInt(r.Left+r.Width, left+1, inWidth)
+	bottom := clampInt(r.Top+r.Height, top+1, inHeight)
+
+	if outWidth <= 0 || outHeight <= 0 {
+		return Rect{left, top, right - left, bottom - top}
+	}
+
+	cx, cy := float64(left+right)/2, float64(top+bottom)/2
+	w, h := float64(right-left), float64(bottom-top)
+	ratio := float64(outWidth) / float64(outHeight)
+
+	// to the output's shape, then its size, then back inside the image
+	if w/h < ratio {
+		w = h * ratio
+	} else {
+		h = w / ratio
+	}
+	if w < float64(outWidth) {
+		w, h = float64(outWidth), float64(outHeight)
+	}
+	if w > float64(inWidth) {
+		w, h = float64(inWidth), float64(inWidth)/ratio
+	}
+	if h > float64(inHeight) {
+		w, h = float64(inHeight)*ratio, float64(inHeight)
+	}
+
+	width := clampInt(int(math.Floor(w+0.5)), 1, inWidth)
+	height := clampInt(int(math.Floor(h+0.5)), 1, inHeight)
+	left = clampInt(int(math.Floor(cx-float64(width)/2+0.5)), 0, inWidth-width)
+	top = clampInt(int(math.Floor(cy-float64(height)/2+0.5)), 0, inHeight-height)
+
+	return Rect{left, top, width, height}
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,54 @@
+package vips
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestRegionWindow(t *testing.T) {
+	cases := []struct {
+		r                   Rect
+		outWidth, outHeight int
+		want                Rect
+	}{
+		// clamped only
+		{Rect{-10, 20, 50, 500}, 0, 0, Rect{0, 20, 40, 180}},
+		// widened to 1:1 around the centre
+		{Rect{100, 50, 20, 40}, 10, 10, Rect{90, 50, 40, 40}},
+		// grown to the output size, then pushed inside
+		{Rect{0, 0, 10, 10}, 100, 50, Rect{0, 0, 100, 50}},
+		// as wide as the image allows, kept inside it
+		{Rect{0, 0, 300, 10}, 16, 9, Rect{0, 0, 200, 113}},
+	}
+	for _, c := range cases {
+		if got := regionWindow(c.r, 200, 200, c.outWidth, c.outHeight); got != c.want {
+			t.Errorf("regionWindow(%+v, %dx%d) = %+v, want %+v", c.r, c.outWidth, c.outHeight, got, c.want)
+		}
+	}
+}
+
+func TestCropRegion(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen [2]int
+	r, err := ResizeWithInfo(buf, Options{Width: 50, Height: 50, Crop: true, CropRegion: func(w, h int) (Rect, error) {
+		seen = [2]int{w, h}
+		return Rect{w / 2, h / 2, 100, 100}, nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seen[0] == 0 || r.Width != 50 || r.Height != 50 {
+		t.Errorf("Resize(CropRegion) => %dx%d, provider saw %v", r.Width, r.Height, seen)
+	}
+
+	failure := errors.New("no faces")
+	_, err = Resize(buf, Options{Width: 50, CropRegion: func(w, h int) (Rect, error) { return Rect{}, failure }})
+	if err != failure {
+		t.Errorf("Resize() with a failing provider = %v, want %v", err, failure)
+	}
+}
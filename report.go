@@ -0,0 +1,137 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ReportEntry describes one image of a Report.
+type ReportEntry struct {
+	Path        string `json:"path"`
+	Format      string `json:"format"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Orientation int    `json:"orientation"`
+	// Date is the EXIF capture date as 2006-01-02T15:04:05, without a
+	// zone since EXIF does not record one.
+	Date       string `json:"date,omitempty"`
+	ICCProfile bool   `json:"icc_profile"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Report probes the headers of the images in paths, descending into
+// directories, without decoding any pixels. Files in directories that do
+// not look like images are skipped; files named explicitly are always
+// reported, with Error set if they could not be read.
+func Report(paths []string) ([]ReportEntry, error) {
+	release, err := acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	var entries []ReportEntry
+	for _, root := range paths {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+
+			entry := probeFile(path)
+			if path != root && entry.Format == UNKNOWN.String() {
+				return nil
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+		if err != nil {
+			return entries, err
+		}
+	}
+
+	return entries, nil
+}
+
+// probeFile fills a ReportEntry from the header of the file at path.
+func probeFile(path string) ReportEntry {
+	entry := ReportEntry{Path: path, Format: UNKNOWN.String()}
+
+	f, err := os.Open(path)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	magic := make([]byte, 12)
+	n, _ := io.ReadFull(f, magic)
+	f.Close()
+	entry.Format = detectType(magic[:n]).String()
+
+	image, err := vipsHeader(path)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	entry.Width = int(image.Xsize)
+	entry.Height = int(image.Ysize)
+	entry.Orientation = vipsExifOrientation(image)
+	entry.ICCProfile = vipsHasField(image, "icc-profile-data")
+
+	for _, field := range exifDateFields {
+		if date, ok := parseExifDate(vipsImageString(image, field)); ok {
+			entry.Date = date.Format("2006-01-02T15:04:05")
+			break
+		}
+	}
+
+	return entry
+}
+
+// WriteReportCSV writes entries as CSV with a header row.
+func WriteReportCSV(w io.Writer, entries []ReportEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"path", "format", "width", "height", "orientation", "date", "icc_profile", "error"})
+	for _, e := range entries {
+		cw.Write([]string{
+			e.Path,
+			e.Format,
+			strconv.Itoa(e.Width),
+			strconv.Itoa(e.Height),
+			strconv.Itoa(e.Orientation),
+			e.Date,
+			strconv.FormatBool(e.ICCProfile),
+			e.Error,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteReportJSON writes entries as an indented JSON array.
+func WriteReportJSON(w io.Writer, entries []ReportEntry) error {
+	if entries == nil {
+		entries = []ReportEntry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
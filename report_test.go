@@ -0,0 +1,42 @@
+package vips
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteReportCSV(t *testing.T) {
+	entries := []ReportEntry{
+		{Path: "a.jpg", Format: "jpeg", Width: 10, Height: 20, Orientation: 6, Date: "2014-05-03T12:34:56"},
+		{Path: "b, c.png", Format: "png", Width: 1, Height: 2, ICCProfile: true},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteReportCSV(buf, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "path,format,width,height,orientation,date,icc_profile,error\n" +
+		"a.jpg,jpeg,10,20,6,2014-05-03T12:34:56,false,\n" +
+		"\"b, c.png\",png,1,2,0,,true,\n"
+	if buf.String() != want {
+		t.Errorf("WriteReportCSV() => %q, want %q", buf.String(), want)
+	}
+}
+
+func TestReport(t *testing.T) {
+	entries, err := Report([]string{"testdata"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 7 {
+		t.Fatalf("Report(testdata) => %d entries, want 7", len(entries))
+	}
+
+	for _, e := range entries {
+		if e.Error != "" || e.Width == 0 || e.Height == 0 {
+			t.Errorf("Report(testdata) => %#v", e)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package vips
+
+import (
+	"image/color"
+)
+
+// RotateArbitrary rotates buf clockwise by degrees at its original scale,
+// for deskewing scans or creative effects. The canvas grows to fit and
+// the uncovered corners are filled with background, transparent where it
+// is and the format allows. The format of buf is kept where it can be
+// saved.
+func RotateArbitrary(buf []byte, degrees float64, background color.RGBA) ([]byte, error) {
+	return Resize(buf, Options{RotateDegrees: degrees, Background: background, Savetype: keptType(buf)})
+}
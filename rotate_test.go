@@ -0,0 +1,39 @@
+package vips
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+)
+
+func TestRotateArbitrary(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{0, 0, 0xff, 0xff}}, image.ZP, draw.Src)
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := RotateArbitrary(buf.Bytes(), 45, color.RGBA{0xff, 0, 0, 0xff})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outImg, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := outImg.Bounds()
+	if b.Dx() < 105 || b.Dx() > 108 || b.Dy() < 105 || b.Dy() > 108 {
+		t.Errorf("RotateArbitrary(45) of 100x50 => %dx%d, want about 106x106", b.Dx(), b.Dy())
+	}
+	if r, _, bl, _ := outImg.At(1, 1).RGBA(); r>>8 != 0xff || bl != 0 {
+		t.Errorf("RotateArbitrary() corner = %v, want the background", outImg.At(1, 1))
+	}
+	if _, _, bl, _ := outImg.At(b.Dx()/2, b.Dy()/2).RGBA(); bl>>8 != 0xff {
+		t.Errorf("RotateArbitrary() centre = %v, want the image", outImg.At(b.Dx()/2, b.Dy()/2))
+	}
+}
@@ -0,0 +1,111 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package vips
+
+import (
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	prSetNoNewPrivs = 38
+
+	seccompSetModeFilter   = 1
+	seccompFilterFlagTsync = 1
+
+	seccompRetKillProcess = 0x80000000
+	seccompRetErrno       = 0x00050000
+	seccompRetAllow       = 0x7fff0000
+
+	// offsets into struct seccomp_data
+	seccompDataNr   = 0
+	seccompDataArch = 4
+	seccompDataArgs = 16
+
+	// set in the numbers of x32 syscalls, which share the amd64 arch
+	x32SyscallBit = 0x40000000
+
+	// open flags that create or modify files
+	openWriteFlags = syscall.O_WRONLY | syscall.O_RDWR | syscall.O_CREAT | syscall.O_TRUNC | syscall.O_APPEND
+)
+
+// sandboxOpen is a syscall opening files, with the index of its flags
+// argument.
+type sandboxOpen struct {
+	nr    uint32
+	flags uint32
+}
+
+// Sandbox restricts the current process to what decoding untrusted images
+// needs: files may still be opened for reading, but not created, written,
+// renamed or removed, no new sockets can be made and no programs run.
+// Denied calls fail with EPERM. It applies to every thread and cannot be
+// undone: it is meant for Isolator workers, see Isolator.Sandbox, and
+// not for a serving process.
+func Sandbox() error {
+	prog := sandboxFilter()
+
+	// no_new_privs is per thread and seccomp requires it on the calling one
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+		return errno
+	}
+
+	fprog := syscall.SockFprog{Len: uint16(len(prog)), Filter: &prog[0]}
+	if _, _, errno := syscall.RawSyscall(sysSeccomp, seccompSetModeFilter, seccompFilterFlagTsync, uintptr(unsafe.Pointer(&fprog))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// sandboxFilter builds the seccomp BPF program behind Sandbox.
+func sandboxFilter() []syscall.SockFilter {
+	stmt := func(code uint16, k uint32) syscall.SockFilter {
+		return syscall.SockFilter{Code: code, K: k}
+	}
+	jump := func(code uint16, k uint32, jt, jf uint8) syscall.SockFilter {
+		return syscall.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+	}
+	deny := stmt(syscall.BPF_RET|syscall.BPF_K, seccompRetErrno|uint32(syscall.EPERM))
+	allow := stmt(syscall.BPF_RET|syscall.BPF_K, seccompRetAllow)
+
+	prog := []syscall.SockFilter{
+		// syscalls of another ABI have other numbers, refuse them outright
+		stmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, seccompDataArch),
+		jump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, auditArch, 1, 0),
+		stmt(syscall.BPF_RET|syscall.BPF_K, seccompRetKillProcess),
+		stmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, seccompDataNr),
+		jump(syscall.BPF_JMP|syscall.BPF_JGE|syscall.BPF_K, x32SyscallBit, 0, 1),
+		stmt(syscall.BPF_RET|syscall.BPF_K, seccompRetKillProcess),
+	}
+
+	// openat2 hides its flags in a struct; libc falls back to openat
+	prog = append(prog,
+		jump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, sysOpenat2, 0, 1),
+		stmt(syscall.BPF_RET|syscall.BPF_K, seccompRetErrno|uint32(syscall.ENOSYS)),
+	)
+
+	for _, nr := range sandboxDenied {
+		prog = append(prog,
+			jump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, nr, 0, 1),
+			deny,
+		)
+	}
+
+	for _, open := range sandboxOpens {
+		prog = append(prog,
+			jump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, open.nr, 0, 4),
+			stmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, seccompDataArgs+8*open.flags),
+			jump(syscall.BPF_JMP|syscall.BPF_JSET|syscall.BPF_K, openWriteFlags, 0, 1),
+			deny,
+			allow,
+		)
+	}
+
+	return append(prog, allow)
+}
@@ -0,0 +1,37 @@
+package vips
+
+import (
+	"syscall"
+)
+
+const (
+	auditArch  = 0xc000003e // AUDIT_ARCH_X86_64
+	sysSeccomp = 317
+	sysOpenat2 = 437
+)
+
+var sandboxDenied = []uint32{
+	syscall.SYS_SOCKET,
+	syscall.SYS_EXECVE,
+	322, // execveat
+	syscall.SYS_PTRACE,
+	syscall.SYS_UNLINK,
+	syscall.SYS_UNLINKAT,
+	syscall.SYS_RENAME,
+	syscall.SYS_RENAMEAT,
+	316, // renameat2
+	syscall.SYS_MKDIR,
+	syscall.SYS_MKDIRAT,
+	syscall.SYS_RMDIR,
+	syscall.SYS_LINK,
+	syscall.SYS_LINKAT,
+	syscall.SYS_SYMLINK,
+	syscall.SYS_SYMLINKAT,
+	syscall.SYS_CREAT,
+	syscall.SYS_TRUNCATE,
+}
+
+var sandboxOpens = []sandboxOpen{
+	{syscall.SYS_OPEN, 1},
+	{syscall.SYS_OPENAT, 2},
+}
@@ -0,0 +1,29 @@
+package vips
+
+import (
+	"syscall"
+)
+
+const (
+	auditArch  = 0xc00000b7 // AUDIT_ARCH_AARCH64
+	sysSeccomp = 277
+	sysOpenat2 = 437
+)
+
+var sandboxDenied = []uint32{
+	syscall.SYS_SOCKET,
+	syscall.SYS_EXECVE,
+	281, // execveat
+	syscall.SYS_PTRACE,
+	syscall.SYS_UNLINKAT,
+	syscall.SYS_RENAMEAT,
+	276, // renameat2
+	syscall.SYS_MKDIRAT,
+	syscall.SYS_LINKAT,
+	syscall.SYS_SYMLINKAT,
+	syscall.SYS_TRUNCATE,
+}
+
+var sandboxOpens = []sandboxOpen{
+	{syscall.SYS_OPENAT, 2},
+}
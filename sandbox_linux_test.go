@@ -0,0 +1,77 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package vips
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// sandboxProbeEnv makes the test binary sandbox itself and report what it
+// was still allowed to do, rather than run the tests.
+const sandboxProbeEnv = "VIPS_SANDBOX_PROBE"
+
+func init() {
+	path := os.Getenv(sandboxProbeEnv)
+	if path == "" {
+		return
+	}
+	if err := Sandbox(); err != nil {
+		fmt.Println("sandbox:", err)
+		os.Exit(2)
+	}
+
+	report := func(name string, err error) {
+		if err != nil {
+			fmt.Println(name, "denied:", err)
+		} else {
+			fmt.Println(name, "allowed")
+		}
+	}
+	f, err := os.Create(path)
+	if err == nil {
+		f.Close()
+	}
+	report("create", err)
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	if err == nil {
+		syscall.Close(fd)
+	}
+	report("socket", err)
+	report("exec", exec.Command("/bin/true").Run())
+	_, err = ioutil.ReadFile("/proc/self/status")
+	report("read", err)
+	os.Exit(0)
+}
+
+func TestSandboxDenies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "probe")
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), sandboxProbeEnv+"="+path)
+	out, err := cmd.Output()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 2 {
+		t.Skip("sandbox not supported:", strings.TrimSpace(string(out)))
+	}
+	if err != nil {
+		t.Fatal(err, string(out))
+	}
+
+	for _, want := range []string{"create denied", "socket denied", "exec denied", "read allowed"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("sandboxed process reported %q, want %q", out, want)
+		}
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Errorf("sandboxed process created %s", path)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux || !(amd64 || arm64)
+// +build !linux !amd64,!arm64
+
+package vips
+
+import (
+	"errors"
+)
+
+// Sandbox is only implemented on Linux for amd64 and arm64.
+func Sandbox() error {
+	return errors.New("vips: sandbox not supported on this platform")
+}
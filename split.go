@@ -0,0 +1,110 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"fmt"
+)
+
+// Split cuts buf into rows x cols pieces, for grid posts or to spread work
+// such as OCR over several workers, returned row by row in the format of
+// buf. Pieces differ in size by a pixel at most when the image does not
+// divide evenly.
+func Split(buf []byte, rows, cols int) ([][]byte, error) {
+	return split(buf, func(width, height int) ([]Rect, error) {
+		if rows < 1 || cols < 1 || rows > height || cols > width {
+			return nil, fmt.Errorf("%w: %dx%d pieces of %dx%d", ErrInvalidDimensions, cols, rows, width, height)
+		}
+		return gridRects(width, height, rows, cols), nil
+	})
+}
+
+// gridRects cuts a width x height image into rows x cols, row by row.
+func gridRects(width, height, rows, cols int) []Rect {
+	rects := make([]Rect, 0, rows*cols)
+	for r := 0; r < rows; r++ {
+		top, bottom := r*height/rows, (r+1)*height/rows
+		for c := 0; c < cols; c++ {
+			left, right := c*width/cols, (c+1)*width/cols
+			rects = append(rects, Rect{left, top, right - left, bottom - top})
+		}
+	}
+	return rects
+}
+
+// SplitStrips cuts a wide panorama in buf into strips width pixels wide,
+// as tall as the image, for viewers that can't take it whole. Each strip
+// overlaps the one before by overlap pixels, so nothing falls on a seam;
+// the last is moved left to end at the right edge, overlapping more,
+// rather than left narrower. Images up to width wide make one strip.
+func SplitStrips(buf []byte, width, overlap int) ([][]byte, error) {
+	return split(buf, func(w, h int) ([]Rect, error) {
+		if width < 1 || overlap < 0 || overlap >= width {
+			return nil, fmt.Errorf("%w: strips %d wide overlapping by %d", ErrInvalidDimensions, width, overlap)
+		}
+		return stripRects(w, h, width, overlap), nil
+	})
+}
+
+// stripRects cuts a w x h image into strips width wide, left to right,
+// overlapping by overlap.
+func stripRects(w, h, width, overlap int) []Rect {
+	if w <= width {
+		return []Rect{{0, 0, w, h}}
+	}
+	var rects []Rect
+	for left := 0; ; left += width - overlap {
+		if left+width >= w {
+			return append(rects, Rect{w - width, 0, width, h})
+		}
+		rects = append(rects, Rect{left, 0, width, h})
+	}
+}
+
+// split decodes buf once and saves the areas cut picks, in the format of
+// buf.
+func split(buf []byte, cut func(width, height int) ([]Rect, error)) ([][]byte, error) {
+	release, err := acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	image, typ, err := loadBuffer(buf, DefaultLimits, false)
+	if err != nil {
+		return nil, err
+	}
+	rects, err := cut(int(image.Xsize), int(image.Ysize))
+	if err != nil {
+		C.g_object_unref(C.gpointer(image))
+		return nil, err
+	}
+
+	// loaders read sequentially, the pieces out of order
+	if image, err = decodeToMemory(image); err != nil {
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	outs := make([][]byte, len(rects))
+	for i, r := range rects {
+		C.g_object_ref(C.gpointer(image))
+		piece, err := vipsExtractArea(image, r.Left, r.Top, r.Width, r.Height)
+		if err != nil {
+			return nil, err
+		}
+		if outs[i], err = saveImage(piece, Options{Savetype: typ}); err != nil {
+			return nil, err
+		}
+	}
+	return outs, nil
+}
@@ -0,0 +1,118 @@
+package vips
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestGridRects(t *testing.T) {
+	got := gridRects(10, 7, 2, 3)
+	want := []Rect{
+		{0, 0, 3, 3}, {3, 0, 3, 3}, {6, 0, 4, 3},
+		{0, 3, 3, 4}, {3, 3, 3, 4}, {6, 3, 4, 4},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("gridRects() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("gridRects()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestStripRects(t *testing.T) {
+	cases := []struct {
+		w, width, overlap int
+		want              []Rect
+	}{
+		{80, 100, 10, []Rect{{0, 0, 80, 5}}},
+		{100, 100, 10, []Rect{{0, 0, 100, 5}}},
+		{190, 100, 10, []Rect{{0, 0, 100, 5}, {90, 0, 100, 5}}},
+		// the last strip ends at the edge
+		{250, 100, 10, []Rect{{0, 0, 100, 5}, {90, 0, 100, 5}, {150, 0, 100, 5}}},
+	}
+	for _, c := range cases {
+		got := stripRects(c.w, 5, c.width, c.overlap)
+		if len(got) != len(c.want) {
+			t.Errorf("stripRects(%d, %d, %d) = %v, want %v", c.w, c.width, c.overlap, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("stripRects(%d, %d, %d) = %v, want %v", c.w, c.width, c.overlap, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Size(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pieces, err := Split(buf, 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pieces) != 9 {
+		t.Fatalf("Split() => %d pieces, want 9", len(pieces))
+	}
+	width := 0
+	for _, p := range pieces[:3] {
+		pm, err := Size(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pm.Format != JPEG {
+			t.Errorf("piece is %v, want %v", pm.Format, JPEG)
+		}
+		width += pm.RawWidth
+	}
+	if width != m.RawWidth {
+		t.Errorf("the first row of pieces is %d wide, want %d", width, m.RawWidth)
+	}
+
+	if _, err := Split(buf, 0, 3); !errors.Is(err, ErrInvalidDimensions) {
+		t.Errorf("Split() into no rows = %v, want %v", err, ErrInvalidDimensions)
+	}
+}
+
+func TestSplitStrips(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Size(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	strips, err := SplitStrips(buf, m.RawWidth/3, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(strips) < 3 {
+		t.Fatalf("SplitStrips() => %d strips, want at least 3", len(strips))
+	}
+	for i, s := range strips {
+		sm, err := Size(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sm.RawWidth != m.RawWidth/3 || sm.RawHeight != m.RawHeight {
+			t.Errorf("strip %d is %dx%d, want %dx%d", i, sm.RawWidth, sm.RawHeight, m.RawWidth/3, m.RawHeight)
+		}
+	}
+
+	if _, err := SplitStrips(buf, 100, 100); !errors.Is(err, ErrInvalidDimensions) {
+		t.Errorf("SplitStrips() overlapping by the whole strip = %v, want %v", err, ErrInvalidDimensions)
+	}
+}
@@ -0,0 +1,95 @@
+package vips
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// SRCSET_QUALITY_1X and SRCSET_QUALITY_2X are the qualities of the
+	// candidates of ResizeSrcset when Options.Quality is unset. Compression
+	// artifacts are half the size on screen at 2x, so it gets away with
+	// less.
+	SRCSET_QUALITY_1X = 80
+	SRCSET_QUALITY_2X = 60
+	// SRCSET_SHARPEN_1X and SRCSET_SHARPEN_2X are the amounts the
+	// candidates are sharpened by when Options.Convolve is unset. 1x is
+	// shrunk further and loses more detail.
+	SRCSET_SHARPEN_1X = 0.3
+	SRCSET_SHARPEN_2X = 0.15
+)
+
+// Candidate is one image of a Srcset, for screens of Density device
+// pixels per CSS pixel.
+type Candidate struct {
+	Result
+	Density int
+}
+
+// Srcset are the candidates of a responsive image, lowest density first.
+type Srcset []Candidate
+
+// ResizeSrcset resizes buf to the CSS size o.Width x o.Height at 1x and
+// at 2x, each sharpened and compressed as suits its scale, for an img
+// srcset. The source is decoded once. The 2x candidate is left out when
+// the source, unless o.Enlarge, is not larger than the 1x one.
+func ResizeSrcset(buf []byte, o Options) (Srcset, error) {
+	img, err := Load(buf, o)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+
+	var set Srcset
+	for _, density := range []int{1, 2} {
+		r, err := img.Resize(srcsetOptions(o, density))
+		if err != nil {
+			return nil, fmt.Errorf("vips: %dx: %w", density, err)
+		}
+		if density > 1 && r.Width <= set[0].Width && r.Height <= set[0].Height {
+			break
+		}
+		set = append(set, Candidate{r, density})
+	}
+	return set, nil
+}
+
+// srcsetOptions are o for the candidate of density.
+func srcsetOptions(o Options, density int) Options {
+	o.Width *= density
+	o.Height *= density
+
+	quality, sharpen := SRCSET_QUALITY_1X, SRCSET_SHARPEN_1X
+	if density > 1 {
+		quality, sharpen = SRCSET_QUALITY_2X, SRCSET_SHARPEN_2X
+	}
+	switch {
+	case o.Quality == 0:
+		o.Quality = quality
+	case o.Quality != QUALITY_AUTO && density > 1:
+		o.Quality = o.Quality * SRCSET_QUALITY_2X / SRCSET_QUALITY_1X
+	}
+	if len(o.Convolve.Values) == 0 {
+		o.Convolve = sharpenKernel(sharpen)
+	}
+	return o
+}
+
+// sharpenKernel is KERNEL_SHARPEN at strength amount, 1 being its own.
+func sharpenKernel(amount float64) Kernel {
+	return Kernel{Width: 3, Height: 3, Values: []float64{
+		0, -amount, 0,
+		-amount, 1 + 4*amount, -amount,
+		0, -amount, 0,
+	}}
+}
+
+// Attribute is the value of the srcset attribute for s, with the URL url
+// gives each candidate, such as "a.jpg 1x, a@2x.jpg 2x".
+func (s Srcset) Attribute(url func(c Candidate) string) string {
+	parts := make([]string, len(s))
+	for i, c := range s {
+		parts[i] = fmt.Sprintf("%s %dx", url(c), c.Density)
+	}
+	return strings.Join(parts, ", ")
+}
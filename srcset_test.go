@@ -0,0 +1,47 @@
+package vips
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+)
+
+func TestSrcsetOptions(t *testing.T) {
+	o := srcsetOptions(Options{Width: 100}, 2)
+	if o.Width != 200 || o.Quality != SRCSET_QUALITY_2X || o.Convolve.Values[4] != 1+4*SRCSET_SHARPEN_2X {
+		t.Errorf("srcsetOptions() at 2x = %+v", o)
+	}
+	if o := srcsetOptions(Options{Width: 100, Quality: 90}, 2); o.Quality != 67 {
+		t.Errorf("srcsetOptions() of quality 90 at 2x has quality %d, want 67", o.Quality)
+	}
+	if o := srcsetOptions(Options{Quality: QUALITY_AUTO, Convolve: KERNEL_BOX_BLUR}, 1); o.Quality != QUALITY_AUTO || o.Convolve.Values[0] != 1 {
+		t.Errorf("srcsetOptions() replaced the quality or kernel asked for: %+v", o)
+	}
+}
+
+func TestResizeSrcset(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := ResizeSrcset(buf, Options{Width: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set) != 2 || set[0].Width != 100 || set[1].Width != 200 {
+		t.Fatalf("ResizeSrcset() => %+v", set)
+	}
+	got := set.Attribute(func(c Candidate) string { return fmt.Sprintf("a-%d.jpg", c.Width) })
+	if want := "a-100.jpg 1x, a-200.jpg 2x"; got != want {
+		t.Errorf("Attribute() = %q, want %q", got, want)
+	}
+
+	m, err := Size(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set, err := ResizeSrcset(buf, Options{Width: m.RawWidth}); err != nil || len(set) != 1 {
+		t.Errorf("ResizeSrcset() at the source size => %d candidates, %v, want 1", len(set), err)
+	}
+}
@@ -0,0 +1,170 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// stageEntry is a decoded source held in memory.
+type stageEntry struct {
+	image   *C.struct__VipsImage
+	typ     ImageType
+	size    int64
+	expires time.Time
+}
+
+type stageKey struct {
+	sum  [sha256.Size]byte
+	fail bool
+}
+
+// stageCache keeps decoded sources for a short while, so bursts of
+// requests for different sizes of the same new upload decode it once.
+type stageCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	maxBytes int64
+	bytes    int64
+	entries  map[stageKey]*stageEntry
+}
+
+var stages = &stageCache{}
+
+// SetDecodeCache keeps decoded sources for ttl, up to maxBytes of pixels,
+// for Resize and ResizeWithInfo to reuse when asked for the same source
+// again. Sources are hashed to find them, and the cache gives up JPEG
+// shrink-on-load, so it pays off for bursts of sizes of a fresh upload
+// rather than one-off requests. A zero ttl, the default, turns it off and
+// frees what it holds.
+func SetDecodeCache(ttl time.Duration, maxBytes int64) {
+	stages.mu.Lock()
+	defer stages.mu.Unlock()
+
+	stages.ttl = ttl
+	stages.maxBytes = maxBytes
+	if ttl <= 0 {
+		stages.purgeLocked()
+	}
+}
+
+func (c *stageCache) enabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ttl > 0
+}
+
+// get returns a reference to the decoded source under k, if held.
+func (c *stageCache) get(k stageKey) (*C.struct__VipsImage, ImageType, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expireLocked(time.Now())
+	e, ok := c.entries[k]
+	if !ok {
+		return nil, UNKNOWN, false
+	}
+	C.g_object_ref(C.gpointer(e.image))
+	return e.image, e.typ, true
+}
+
+// put holds a reference to image under k, unless it does not fit.
+func (c *stageCache) put(k stageKey, image *C.struct__VipsImage, typ ImageType) {
+	size := memoryOf(image)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl <= 0 || size > c.maxBytes {
+		return
+	}
+	if _, ok := c.entries[k]; ok {
+		return
+	}
+
+	now := time.Now()
+	c.expireLocked(now)
+	// make room, oldest first
+	for c.bytes+size > c.maxBytes {
+		var oldest stageKey
+		var first *stageEntry
+		for k, e := range c.entries {
+			if first == nil || e.expires.Before(first.expires) {
+				oldest, first = k, e
+			}
+		}
+		c.removeLocked(oldest)
+	}
+
+	if c.entries == nil {
+		c.entries = make(map[stageKey]*stageEntry)
+	}
+	C.g_object_ref(C.gpointer(image))
+	c.entries[k] = &stageEntry{image: image, typ: typ, size: size, expires: now.Add(c.ttl)}
+	c.bytes += size
+}
+
+func (c *stageCache) expireLocked(now time.Time) {
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			c.removeLocked(k)
+		}
+	}
+}
+
+func (c *stageCache) removeLocked(k stageKey) {
+	e := c.entries[k]
+	C.g_object_unref(C.gpointer(e.image))
+	c.bytes -= e.size
+	delete(c.entries, k)
+}
+
+// purge drops every entry, before libvips shuts down.
+func (c *stageCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.purgeLocked()
+}
+
+func (c *stageCache) purgeLocked() {
+	for k := range c.entries {
+		c.removeLocked(k)
+	}
+}
+
+// loadStage is loadBuffer through the decode cache. The image returned is
+// in memory, so it can be read any number of times.
+func loadStage(buf []byte, l Limits, fail bool) (*C.struct__VipsImage, ImageType, error) {
+	buf = skipJunk(buf)
+	if l.MaxBytes > 0 && len(buf) > l.MaxBytes {
+		return nil, UNKNOWN, ErrLimitExceeded
+	}
+
+	k := stageKey{sha256.Sum256(buf), fail}
+	if image, typ, ok := stages.get(k); ok {
+		if !l.allows(int(image.Xsize), int(image.Ysize)) {
+			C.g_object_unref(C.gpointer(image))
+			return nil, typ, ErrLimitExceeded
+		}
+		debug("decode cache hit")
+		return image, typ, nil
+	}
+
+	image, typ, err := loadBuffer(buf, l, fail)
+	if err != nil {
+		return nil, typ, err
+	}
+	memory := C.vips_image_copy_memory(image)
+	C.g_object_unref(C.gpointer(image))
+	if memory == nil {
+		return nil, typ, catchVipsError()
+	}
+
+	stages.put(k, memory, typ)
+	return memory, typ, nil
+}
@@ -0,0 +1,57 @@
+package vips
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestDecodeCache(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetDecodeCache(time.Minute, 1<<30)
+	defer SetDecodeCache(0, 0)
+
+	for _, width := range []int{100, 200, 300} {
+		r, err := ResizeWithInfo(buf, Options{Width: width})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Width != width {
+			t.Errorf("ResizeWithInfo(%d) from the cache => %d wide", width, r.Width)
+		}
+	}
+	if len(stages.entries) != 1 || stages.bytes == 0 {
+		t.Errorf("decode cache holds %d entries of %d bytes, want 1", len(stages.entries), stages.bytes)
+	}
+
+	if _, err := Resize(buf, Options{Width: 100, MaxDimension: 10}); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Resize() over limits from the cache = %v, want %v", err, ErrLimitExceeded)
+	}
+
+	SetDecodeCache(0, 0)
+	if len(stages.entries) != 0 || stages.bytes != 0 {
+		t.Errorf("disabled decode cache holds %d entries of %d bytes", len(stages.entries), stages.bytes)
+	}
+}
+
+func TestDecodeCacheBudget(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetDecodeCache(time.Minute, 1)
+	defer SetDecodeCache(0, 0)
+
+	if _, err := Resize(buf, Options{Width: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if len(stages.entries) != 0 {
+		t.Errorf("decode cache kept a source over its budget")
+	}
+}
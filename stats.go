@@ -0,0 +1,87 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+// BandStats are the statistics of the samples of one band.
+type BandStats struct {
+	Min, Max, Mean, StdDev float64
+}
+
+// statsColumns is the width of the matrix of vips_stats.
+const statsColumns = 10
+
+// Stats returns the statistics of each band of buf as decoded, alpha
+// included, for automatic checks such as of exposure.
+func Stats(buf []byte) ([]BandStats, error) {
+	release, err := acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	image, _, err := loadBuffer(buf, DefaultLimits, false)
+	if err != nil {
+		return nil, err
+	}
+	bands := int(image.Bands)
+
+	m, err := vipsStats(image)
+	if err != nil {
+		return nil, err
+	}
+
+	// the first row is of all bands together
+	stats := make([]BandStats, bands)
+	for b := range stats {
+		row := m[(b+1)*statsColumns:]
+		stats[b] = BandStats{Min: row[0], Max: row[1], Mean: row[4], StdDev: row[5]}
+	}
+	return stats, nil
+}
+
+// Histogram counts the samples of each band of buf as decoded, alpha
+// included, in 256 bins, 16 bit samples being scaled down. Sources
+// decoding alike, such as duplicates saved again, have histograms alike.
+func Histogram(buf []byte) ([][]int, error) {
+	release, err := acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	image, _, err := loadBuffer(buf, DefaultLimits, false)
+	if err != nil {
+		return nil, err
+	}
+	bands := int(image.Bands)
+
+	counts, err := vipsHistogram(image)
+	if err != nil {
+		return nil, err
+	}
+
+	// one row of 256 bins, the bands of each bin together
+	hist := make([][]int, bands)
+	for b := range hist {
+		hist[b] = make([]int, 256)
+		for bin := range hist[b] {
+			hist[b][bin] = int(counts[bin*bands+b])
+		}
+	}
+	return hist, nil
+}
@@ -0,0 +1,45 @@
+package vips
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 2; x < 4; x++ {
+			img.SetGray(x, y, color.Gray{0xff})
+		}
+	}
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := Stats(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("Stats() => %d bands, want 1", len(stats))
+	}
+	if s := stats[0]; s.Min != 0 || s.Max != 255 || s.Mean != 127.5 || math.Abs(s.StdDev-131.7) > 0.1 {
+		t.Errorf("Stats() => %+v", s)
+	}
+
+	hist, err := Histogram(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hist) != 1 {
+		t.Fatalf("Histogram() => %d bands, want 1", len(hist))
+	}
+	if hist[0][0] != 8 || hist[0][255] != 8 || hist[0][128] != 0 {
+		t.Errorf("Histogram() => %d black, %d white, %d grey", hist[0][0], hist[0][255], hist[0][128])
+	}
+}
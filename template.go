@@ -0,0 +1,357 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Template lays out a raster such as a certificate or a ticket: a
+// background, images in slots, text and a QR code, drawn in that order.
+// Templates are JSON, so layouts can live in configuration:
+//
+//	{"width": 1600, "height": 1130, "background": "#fdf6e3",
+//	 "slots": [{"image": "logo", "x": 60, "y": 60, "width": 240, "height": 120}],
+//	 "texts": [{"field": "name", "font": "serif bold 64", "x": 800, "y": 520, "anchor": "centre"}],
+//	 "qr": {"x": 1340, "y": 870, "width": 200, "height": 200}}
+type Template struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Background is a colour as "#rgb", "#rrggbb" or "#rrggbbaa", white
+	// by default. Results are opaque unless it is not.
+	Background string `json:"background,omitempty"`
+	// BackgroundImage names an image of TemplateData to cover the whole
+	// template with.
+	BackgroundImage string `json:"background_image,omitempty"`
+
+	Slots []TemplateSlot `json:"slots,omitempty"`
+	Texts []TemplateText `json:"texts,omitempty"`
+	// QR is where the QR code of TemplateData goes.
+	QR *TemplateBox `json:"qr,omitempty"`
+}
+
+// TemplateBox is a rectangle of a Template, from its top left corner.
+type TemplateBox struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// TemplateSlot places the image of TemplateData named Image in a box.
+type TemplateSlot struct {
+	TemplateBox
+	Image string `json:"image"`
+	// Fit is "contain", the default, to fit the image inside the box,
+	// centred, or "cover" to fill it, cropping what sticks out.
+	Fit string `json:"fit,omitempty"`
+}
+
+// TemplateText is a line of text, either Text as it is or the field of
+// TemplateData named Field.
+type TemplateText struct {
+	Text  string `json:"text,omitempty"`
+	Field string `json:"field,omitempty"`
+	// Font is a Pango font description, "sans 24" by default.
+	Font string `json:"font,omitempty"`
+	// Color is as Template.Background, black by default.
+	Color string `json:"color,omitempty"`
+	X     int    `json:"x"`
+	Y     int    `json:"y"`
+	// Anchor is the point of the text put at X, Y: "top-left", the
+	// default, "top", "top-right", "left", "centre", "right",
+	// "bottom-left", "bottom" or "bottom-right".
+	Anchor string `json:"anchor,omitempty"`
+}
+
+// TemplateData fills a Template in.
+type TemplateData struct {
+	// Images are encoded images, by name.
+	Images map[string][]byte
+	// Fields are the values of text fields, by name.
+	Fields map[string]string
+	// QR is an encoded image of a QR code, made by the caller. It is
+	// scaled without smoothing, by whole pixels where it grows, so its
+	// modules stay sharp and even.
+	QR []byte
+}
+
+// templateAnchors are the points of text anchors, in halves of its width
+// and height from its top left corner.
+var templateAnchors = map[string][2]int{
+	"":             {0, 0},
+	"top-left":     {0, 0},
+	"top":          {1, 0},
+	"top-right":    {2, 0},
+	"left":         {0, 1},
+	"centre":       {1, 1},
+	"center":       {1, 1},
+	"right":        {2, 1},
+	"bottom-left":  {0, 2},
+	"bottom":       {1, 2},
+	"bottom-right": {2, 2},
+}
+
+// ParseTemplate reads a Template from JSON.
+func ParseTemplate(spec []byte) (Template, error) {
+	var t Template
+	dec := json.NewDecoder(bytes.NewReader(spec))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&t); err != nil {
+		return Template{}, fmt.Errorf("%w: template: %v", ErrInvalidOption, err)
+	}
+	return t, t.validate()
+}
+
+// validate checks t can be rendered, whatever it is filled in with.
+func (t Template) validate() error {
+	if t.Width < 1 || t.Height < 1 {
+		return fmt.Errorf("%w: template of %dx%d", ErrInvalidDimensions, t.Width, t.Height)
+	}
+	if _, err := parseHexColor(t.Background, color.RGBA{}); err != nil {
+		return fmt.Errorf("%w: template background: %v", ErrInvalidOption, err)
+	}
+	for i, s := range t.Slots {
+		if err := s.TemplateBox.validate(); err != nil {
+			return fmt.Errorf("%w: template slot %d: %v", ErrInvalidOption, i, err)
+		}
+		if s.Fit != "" && s.Fit != "contain" && s.Fit != "cover" {
+			return fmt.Errorf("%w: template slot %d: unknown fit %q", ErrInvalidOption, i, s.Fit)
+		}
+	}
+	for i, text := range t.Texts {
+		if (text.Text == "") == (text.Field == "") {
+			return fmt.Errorf("%w: template text %d: needs either text or a field", ErrInvalidOption, i)
+		}
+		if _, ok := templateAnchors[text.Anchor]; !ok {
+			return fmt.Errorf("%w: template text %d: unknown anchor %q", ErrInvalidOption, i, text.Anchor)
+		}
+		if _, err := parseHexColor(text.Color, color.RGBA{}); err != nil {
+			return fmt.Errorf("%w: template text %d: %v", ErrInvalidOption, i, err)
+		}
+	}
+	if t.QR != nil {
+		if err := t.QR.validate(); err != nil {
+			return fmt.Errorf("%w: template qr: %v", ErrInvalidOption, err)
+		}
+	}
+	return nil
+}
+
+func (b TemplateBox) validate() error {
+	if b.Width < 1 || b.Height < 1 {
+		return fmt.Errorf("box of %dx%d", b.Width, b.Height)
+	}
+	return nil
+}
+
+// parseHexColor parses "#rgb", "#rrggbb" or "#rrggbbaa", returning def
+// for "".
+func parseHexColor(s string, def color.RGBA) (color.RGBA, error) {
+	if s == "" {
+		return def, nil
+	}
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 8 || !strings.HasPrefix(s, "#") {
+		return color.RGBA{}, fmt.Errorf("bad colour %q", s)
+	}
+	return color.RGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, nil
+}
+
+// RenderTemplate draws t filled in with data, so certificates and tickets
+// can be made without a browser. o gives the format, quality and priority
+// of the result; its other fields don't apply.
+func RenderTemplate(t Template, data TemplateData, o Options) ([]byte, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	// look everything up before any pixels are drawn
+	images := func(name string) ([]byte, error) {
+		buf, ok := data.Images[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: template: no image %q", ErrInvalidOption, name)
+		}
+		return buf, nil
+	}
+	if t.BackgroundImage != "" {
+		if _, err := images(t.BackgroundImage); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range t.Slots {
+		if _, err := images(s.Image); err != nil {
+			return nil, err
+		}
+	}
+	texts := make([]string, len(t.Texts))
+	for i, text := range t.Texts {
+		texts[i] = text.Text
+		if text.Field != "" {
+			value, ok := data.Fields[text.Field]
+			if !ok {
+				return nil, fmt.Errorf("%w: template: no field %q", ErrInvalidOption, text.Field)
+			}
+			texts[i] = value
+		}
+	}
+	if t.QR != nil && len(data.QR) == 0 {
+		return nil, fmt.Errorf("%w: template: no QR code", ErrInvalidOption)
+	}
+
+	release, err := acquireAt(o.Priority)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	background, _ := parseHexColor(t.Background, color.RGBA{0xff, 0xff, 0xff, 0xff})
+	canvas, err := vipsCanvas(t.Width, t.Height, background)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.BackgroundImage != "" {
+		canvas, err = placeImage(canvas, data.Images[t.BackgroundImage], TemplateBox{0, 0, t.Width, t.Height}, "cover")
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range t.Slots {
+		canvas, err = placeImage(canvas, data.Images[s.Image], s.TemplateBox, s.Fit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, text := range t.Texts {
+		canvas, err = drawTemplateText(canvas, text, texts[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	if t.QR != nil {
+		canvas, err = placeQR(canvas, data.QR, *t.QR)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if background.A == 0xff {
+		if canvas, err = vipsFlatten(canvas, background); err != nil {
+			return nil, err
+		}
+	}
+
+	return saveImage(canvas, o)
+}
+
+// placeImage draws the image in buf over canvas, which it releases, fit
+// into box and centred in it.
+func placeImage(canvas *C.struct__VipsImage, buf []byte, box TemplateBox, fit string) (*C.struct__VipsImage, error) {
+	image, typ, err := loadBuffer(buf, DefaultLimits, false)
+	if err != nil {
+		C.g_object_unref(C.gpointer(canvas))
+		return nil, err
+	}
+
+	image, err = transformImage(image, typ, Options{Width: box.Width, Height: box.Height, Crop: fit == "cover", Enlarge: true}, nil)
+	if err != nil {
+		C.g_object_unref(C.gpointer(canvas))
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	left := box.X + (box.Width-int(image.Xsize))/2
+	top := box.Y + (box.Height-int(image.Ysize))/2
+	debug("template image %dx%d at %d,%d", int(image.Xsize), int(image.Ysize), left, top)
+	return vipsComposite(canvas, image, left, top)
+}
+
+// placeQR draws the QR code in buf over canvas, which it releases, as
+// large as fits in box and centred in it.
+func placeQR(canvas *C.struct__VipsImage, buf []byte, box TemplateBox) (*C.struct__VipsImage, error) {
+	image, _, err := loadBuffer(buf, DefaultLimits, false)
+	if err != nil {
+		C.g_object_unref(C.gpointer(canvas))
+		return nil, err
+	}
+
+	scale := qrScale(int(image.Xsize), int(image.Ysize), box)
+	image, err = vipsResizeKernel(image, scale, C.VIPS_KERNEL_NEAREST)
+	if err != nil {
+		C.g_object_unref(C.gpointer(canvas))
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	left := box.X + (box.Width-int(image.Xsize))/2
+	top := box.Y + (box.Height-int(image.Ysize))/2
+	debug("template qr x%v at %d,%d", scale, left, top)
+	return vipsComposite(canvas, image, left, top)
+}
+
+// qrScale is how much to scale a width x height QR code to fit in box,
+// by whole pixels when it grows.
+func qrScale(width, height int, box TemplateBox) float64 {
+	scale := math.Min(float64(box.Width)/float64(width), float64(box.Height)/float64(height))
+	if scale >= 1 {
+		scale = math.Floor(scale)
+	}
+	return scale
+}
+
+// drawTemplateText draws value as text says over canvas, which it
+// releases. Empty values draw nothing.
+func drawTemplateText(canvas *C.struct__VipsImage, text TemplateText, value string) (*C.struct__VipsImage, error) {
+	if strings.TrimSpace(value) == "" {
+		return canvas, nil
+	}
+	font := text.Font
+	if font == "" {
+		font = "sans 24"
+	}
+	ink, _ := parseHexColor(text.Color, color.RGBA{0, 0, 0, 0xff})
+
+	mask, err := vipsText(value, font)
+	if err != nil {
+		C.g_object_unref(C.gpointer(canvas))
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(mask))
+
+	left, top := anchorPosition(text.X, text.Y, int(mask.Xsize), int(mask.Ysize), text.Anchor)
+	debug("template text %q at %d,%d", value, left, top)
+	return vipsBlendMask(canvas, mask, left, top, ink)
+}
+
+// anchorPosition is the top left corner of a w x h box whose anchor point
+// is at x, y.
+func anchorPosition(x, y, w, h int, anchor string) (int, int) {
+	a := templateAnchors[anchor]
+	return x - w*a[0]/2, y - h*a[1]/2
+}
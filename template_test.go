@@ -0,0 +1,172 @@
+package vips
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	var testCases = []struct {
+		spec string
+		ok   bool
+	}{
+		{`{"width": 100, "height": 50}`, true},
+		{`{"width": 100, "height": 50, "background": "#fff", "qr": {"x": 10, "y": 10, "width": 20, "height": 20}}`, true},
+		{`{"width": 100, "height": 50, "slots": [{"image": "logo", "x": 0, "y": 0, "width": 10, "height": 10, "fit": "cover"}]}`, true},
+		{`{"width": 100, "height": 50, "texts": [{"field": "name", "x": 50, "y": 25, "anchor": "centre", "color": "#102030"}]}`, true},
+		{`{"width": 0, "height": 50}`, false},
+		{`{"width": 100, "height": 50, "colour": "#fff"}`, false},
+		{`{"width": 100, "height": 50, "background": "white"}`, false},
+		{`{"width": 100, "height": 50, "slots": [{"image": "logo", "width": 10, "height": 10, "fit": "stretch"}]}`, false},
+		{`{"width": 100, "height": 50, "slots": [{"image": "logo", "width": 0, "height": 10}]}`, false},
+		{`{"width": 100, "height": 50, "texts": [{"x": 50, "y": 25}]}`, false},
+		{`{"width": 100, "height": 50, "texts": [{"text": "a", "field": "b"}]}`, false},
+		{`{"width": 100, "height": 50, "texts": [{"text": "a", "anchor": "middle"}]}`, false},
+		{`{"width": 100, "height": 50, "qr": {"width": 10}}`, false},
+	}
+
+	for index, tc := range testCases {
+		_, err := ParseTemplate([]byte(tc.spec))
+		if (err == nil) != tc.ok {
+			t.Errorf("%d. ParseTemplate(%s) => %v, want ok %v", index, tc.spec, err, tc.ok)
+		}
+	}
+}
+
+func TestParseHexColor(t *testing.T) {
+	var testCases = []struct {
+		s  string
+		c  color.RGBA
+		ok bool
+	}{
+		{"", color.RGBA{1, 2, 3, 4}, true},
+		{"#fff", color.RGBA{0xff, 0xff, 0xff, 0xff}, true},
+		{"#102030", color.RGBA{0x10, 0x20, 0x30, 0xff}, true},
+		{"#10203040", color.RGBA{0x10, 0x20, 0x30, 0x40}, true},
+		{"102030", color.RGBA{}, false},
+		{"#1020", color.RGBA{}, false},
+		{"#zzzzzz", color.RGBA{}, false},
+	}
+
+	for index, tc := range testCases {
+		c, err := parseHexColor(tc.s, color.RGBA{1, 2, 3, 4})
+		if (err == nil) != tc.ok || c != tc.c {
+			t.Errorf("%d. parseHexColor(%q) => %v, %v, want %v", index, tc.s, c, err, tc.c)
+		}
+	}
+}
+
+func TestAnchorPosition(t *testing.T) {
+	var testCases = []struct {
+		anchor    string
+		left, top int
+	}{
+		{"", 100, 50},
+		{"centre", 80, 45},
+		{"bottom-right", 60, 40},
+		{"top", 80, 50},
+	}
+
+	for index, tc := range testCases {
+		left, top := anchorPosition(100, 50, 40, 10, tc.anchor)
+		if left != tc.left || top != tc.top {
+			t.Errorf("%d. anchorPosition(%q) => %d,%d, want %d,%d", index, tc.anchor, left, top, tc.left, tc.top)
+		}
+	}
+}
+
+func TestQRScale(t *testing.T) {
+	var testCases = []struct {
+		size  int
+		box   TemplateBox
+		scale float64
+	}{
+		{25, TemplateBox{Width: 100, Height: 100}, 4},
+		{25, TemplateBox{Width: 120, Height: 100}, 4},
+		{30, TemplateBox{Width: 100, Height: 100}, 3},
+		{200, TemplateBox{Width: 100, Height: 100}, 0.5},
+	}
+
+	for index, tc := range testCases {
+		if scale := qrScale(tc.size, tc.size, tc.box); scale != tc.scale {
+			t.Errorf("%d. qrScale(%d, %+v) => %v, want %v", index, tc.size, tc.box, scale, tc.scale)
+		}
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	encode := func(img image.Image) []byte {
+		buf := new(bytes.Buffer)
+		if err := png.Encode(buf, img); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	logo := image.NewNRGBA(image.Rect(0, 0, 20, 10))
+	for i := range logo.Pix {
+		logo.Pix[i] = 0xff
+	}
+	// a 3x3 "QR code", black in the corners
+	qr := image.NewGray(image.Rect(0, 0, 3, 3))
+	for i := range qr.Pix {
+		qr.Pix[i] = 0xff
+	}
+	qr.SetGray(0, 0, color.Gray{})
+	qr.SetGray(2, 2, color.Gray{})
+
+	tmpl, err := ParseTemplate([]byte(`{
+		"width": 120, "height": 60, "background": "#000080",
+		"slots": [{"image": "logo", "x": 0, "y": 0, "width": 40, "height": 40, "fit": "cover"}],
+		"texts": [{"field": "name", "x": 60, "y": 30, "anchor": "centre", "color": "#ffffff"}],
+		"qr": {"x": 90, "y": 30, "width": 30, "height": 30}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := TemplateData{
+		Images: map[string][]byte{"logo": encode(logo)},
+		Fields: map[string]string{"name": "Ada"},
+		QR:     encode(qr),
+	}
+
+	buf, err := RenderTemplate(tmpl, data, Options{Savetype: PNG})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 120 || b.Dy() != 60 {
+		t.Fatalf("RenderTemplate() => %dx%d, want 120x60", b.Dx(), b.Dy())
+	}
+
+	at := func(x, y int) color.RGBA {
+		r, g, b, _ := img.At(x, y).RGBA()
+		return color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), 0xff}
+	}
+	var testCases = []struct {
+		x, y int
+		c    color.RGBA
+	}{
+		{20, 20, color.RGBA{0xff, 0xff, 0xff, 0xff}}, // the logo, covering its slot
+		{20, 50, color.RGBA{0, 0, 0x80, 0xff}},       // below it
+		{95, 35, color.RGBA{0, 0, 0, 0xff}},          // the top left module of the QR code
+		{105, 35, color.RGBA{0xff, 0xff, 0xff, 0xff}},
+		{115, 55, color.RGBA{0, 0, 0, 0xff}}, // the bottom right module
+	}
+	for index, tc := range testCases {
+		if c := at(tc.x, tc.y); c != tc.c {
+			t.Errorf("%d. RenderTemplate() at %d,%d => %v, want %v", index, tc.x, tc.y, c, tc.c)
+		}
+	}
+
+	delete(data.Fields, "name")
+	if _, err := RenderTemplate(tmpl, data, Options{Savetype: PNG}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("RenderTemplate() without a field => %v, want ErrInvalidOption", err)
+	}
+}
@@ -0,0 +1,117 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// Tile cuts tile x, y of zoom level z out of buf for slippy-map style
+// viewers. At zoom 0 the whole image fits one tileSize tile, and each
+// level doubles the scale, up to the native resolution and beyond. Tiles
+// past the right and bottom edges of the image are padded, transparent
+// where the format allows. Only the region under the tile is decoded.
+func Tile(buf []byte, z, x, y, tileSize int) (out []byte, err error) {
+	defer audit("tile", buf, Options{LeftPos: float32(x), TopPos: float32(y), Width: tileSize, Height: tileSize})(&out, &err)
+
+	if tileSize == 0 {
+		tileSize = 256
+	}
+	if z < 0 || z > 30 || x < 0 || y < 0 || tileSize < 0 {
+		return nil, fmt.Errorf("vips: no tile %d/%d/%d", z, x, y)
+	}
+
+	release, err := acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	image, typ, err := loadBuffer(buf, DefaultLimits, false)
+	if err != nil {
+		return nil, err
+	}
+
+	left, top, width, height, scale, dx, dy, ok := tileArea(int(image.Xsize), int(image.Ysize), z, x, y, tileSize)
+	if !ok {
+		C.g_object_unref(C.gpointer(image))
+		return nil, fmt.Errorf("vips: no tile %d/%d/%d", z, x, y)
+	}
+
+	image, err = vipsExtractArea(image, left, top, width, height)
+	if err != nil {
+		return nil, err
+	}
+
+	// shrink by the integral part first, as sharp does, so the affine
+	// does not alias
+	if shrink := int(1 / scale); shrink > 1 {
+		if image, err = vipsShrink(image, shrink); err != nil {
+			return nil, err
+		}
+		scale *= float64(shrink)
+	}
+	if scale != 1 {
+		if image, err = vipsAffine(image, AffineMatrix{A: scale, D: scale}, BICUBIC, color.RGBA{}); err != nil {
+			return nil, err
+		}
+	}
+
+	if dx >= int(image.Xsize) {
+		dx = int(image.Xsize) - 1
+	}
+	if dy >= int(image.Ysize) {
+		dy = int(image.Ysize) - 1
+	}
+	if w, h := int(image.Xsize)-dx, int(image.Ysize)-dy; dx > 0 || dy > 0 || w > tileSize || h > tileSize {
+		if w > tileSize {
+			w = tileSize
+		}
+		if h > tileSize {
+			h = tileSize
+		}
+		if image, err = vipsExtractArea(image, dx, dy, w, h); err != nil {
+			return nil, err
+		}
+	}
+	if int(image.Xsize) < tileSize || int(image.Ysize) < tileSize {
+		if image, err = vipsEmbedBackground(image, 0, 0, tileSize, tileSize, color.RGBA{}); err != nil {
+			return nil, err
+		}
+	}
+
+	return saveImage(image, Options{Savetype: typ})
+}
+
+// tileArea finds the whole source pixels under tile x, y of zoom z of a
+// width x height image, the scale taking them to tile pixels, and where the
+// tile starts in them once scaled: past native resolution a tile can start
+// partway into a source pixel. ok is false for tiles outside the image.
+func tileArea(width, height, z, x, y, tileSize int) (left, top, w, h int, scale float64, dx, dy int, ok bool) {
+	// the source pixels under a tile
+	span := math.Max(float64(width), float64(height)) / math.Exp2(float64(z))
+	scale = float64(tileSize) / span
+
+	left = int(math.Floor(float64(x) * span))
+	top = int(math.Floor(float64(y) * span))
+	if left >= width || top >= height {
+		return 0, 0, 0, 0, 0, 0, 0, false
+	}
+	right := math.Min(math.Ceil(float64(x+1)*span), float64(width))
+	bottom := math.Min(math.Ceil(float64(y+1)*span), float64(height))
+	dx = int(math.Round((float64(x)*span - float64(left)) * scale))
+	dy = int(math.Round((float64(y)*span - float64(top)) * scale))
+
+	return left, top, int(right) - left, int(bottom) - top, scale, dx, dy, true
+}
@@ -0,0 +1,59 @@
+package vips
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestTileArea(t *testing.T) {
+	cases := []struct {
+		z, x, y         int
+		left, top, w, h int
+		scale           float64
+		dx, dy          int
+		ok              bool
+	}{
+		{0, 0, 0, 0, 0, 1000, 500, 0.256, 0, 0, true},
+		{2, 1, 0, 250, 0, 250, 250, 1.024, 0, 0, true},
+		{2, 3, 1, 750, 250, 250, 250, 1.024, 0, 0, true},
+		{4, 1, 0, 62, 0, 63, 63, 4.096, 2, 0, true},
+		// past native resolution neighbouring tiles share a source pixel
+		{12, 1, 0, 0, 0, 1, 1, 1048.576, 256, 0, true},
+		{12, 2, 0, 0, 0, 1, 1, 1048.576, 512, 0, true},
+		{2, 0, 2, 0, 0, 0, 0, 0, 0, 0, false},
+		{1, 2, 0, 0, 0, 0, 0, 0, 0, 0, false},
+	}
+	for _, c := range cases {
+		left, top, w, h, scale, dx, dy, ok := tileArea(1000, 500, c.z, c.x, c.y, 256)
+		if ok != c.ok || ok && (left != c.left || top != c.top || w != c.w || h != c.h || scale != c.scale || dx != c.dx || dy != c.dy) {
+			t.Errorf("tileArea(%d/%d/%d) = %d, %d, %dx%d, %v, %d, %d, %v", c.z, c.x, c.y, left, top, w, h, scale, dx, dy, ok)
+		}
+	}
+}
+
+func TestTile(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, image.NewNRGBA(image.Rect(0, 0, 1000, 500))); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, zxy := range [][3]int{{0, 0, 0}, {2, 3, 1}} {
+		out, err := Tile(buf.Bytes(), zxy[0], zxy[1], zxy[2], 256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(bytes.NewReader(out))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := img.Bounds(); b.Dx() != 256 || b.Dy() != 256 {
+			t.Errorf("Tile(%v) => %dx%d, want 256x256", zxy, b.Dx(), b.Dy())
+		}
+	}
+
+	if _, err := Tile(buf.Bytes(), 0, 1, 0, 256); err == nil {
+		t.Errorf("Tile() outside the image did not fail")
+	}
+}
@@ -0,0 +1,89 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrTimeout is returned for operations that ran longer than
+// Options.Timeout.
+var ErrTimeout = errors.New("vips: operation timed out")
+
+// deadline kills the evaluation of the image it watches once its time is
+// up. Pixels are mostly decoded as the result is saved, so the image
+// watched is the one saved; steps before that which need the whole image,
+// such as Trim, can't be interrupted, but a save after the time is up
+// fails at once.
+type deadline struct {
+	mu      sync.Mutex
+	d       time.Duration
+	timer   *time.Timer
+	image   *C.struct__VipsImage
+	expired bool
+	done    bool
+}
+
+// startDeadline starts the clock on an operation allowed d, none when d is
+// 0.
+func startDeadline(d time.Duration) *deadline {
+	if d <= 0 {
+		return nil
+	}
+	t := &deadline{d: d}
+	t.timer = time.AfterFunc(d, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.expired = true
+		if t.image != nil && !t.done {
+			C.vips_image_set_kill(t.image, C.TRUE)
+		}
+	})
+	return t
+}
+
+// watch kills image when the time is up, or at once if it already is. It
+// holds a reference to image until stop.
+func (t *deadline) watch(image *C.struct__VipsImage) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	C.g_object_ref(C.gpointer(image))
+	t.image = image
+	if t.expired {
+		C.vips_image_set_kill(image, C.TRUE)
+	}
+}
+
+// stop stops the clock, releasing the image watched, and reports whether
+// the time was up. It may be called more than once.
+func (t *deadline) stop() (expired bool) {
+	if t == nil {
+		return false
+	}
+	t.timer.Stop()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.image != nil && !t.done {
+		C.g_object_unref(C.gpointer(t.image))
+	}
+	t.done = true
+	return t.expired
+}
+
+// check stops the clock and turns err into ErrTimeout when it was the time
+// running out that caused it.
+func (t *deadline) check(err error) error {
+	if err != nil && t.stop() {
+		return fmt.Errorf("%w after %v", ErrTimeout, t.d)
+	}
+	return err
+}
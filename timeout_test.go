@@ -0,0 +1,38 @@
+package vips
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Resize(buf, Options{Width: 100, Timeout: time.Minute}); err != nil {
+		t.Errorf("Resize() within Timeout = %v", err)
+	}
+	// enlarging this much takes far longer than a microsecond
+	o := Options{Width: 8000, Enlarge: true, Timeout: time.Microsecond}
+	if _, err := Resize(buf, o); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Resize() over Timeout = %v, want %v", err, ErrTimeout)
+	}
+
+	dir, err := ioutil.TempDir("", "vips")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ResizeFile("testdata/1.jpg", filepath.Join(dir, "out.jpg"), o); !errors.Is(err, ErrTimeout) {
+		t.Errorf("ResizeFile() over Timeout = %v, want %v", err, ErrTimeout)
+	}
+	if err := (Options{Timeout: -time.Second}).Validate(); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Validate() of a negative Timeout = %v, want %v", err, ErrInvalidOption)
+	}
+}
@@ -0,0 +1,40 @@
+package vips
+
+import (
+	"image/color"
+	"math"
+)
+
+// Tint gives buf the colour cast of c at its original size, keeping its
+// format where it can be saved. See Options.Tint.
+func Tint(buf []byte, c color.RGBA) ([]byte, error) {
+	c.A = 255
+	return Resize(buf, Options{Tint: c, Savetype: keptType(buf)})
+}
+
+// labOf converts c from sRGB to CIELAB under the D65 white point libvips
+// uses, ignoring alpha.
+func labOf(c color.RGBA) (l, a, b float64) {
+	linear := func(v uint8) float64 {
+		f := float64(v) / 255
+		if f <= 0.04045 {
+			return f / 12.92
+		}
+		return math.Pow((f+0.055)/1.055, 2.4)
+	}
+	r, g, bl := linear(c.R), linear(c.G), linear(c.B)
+
+	x := (0.4124*r + 0.3576*g + 0.1805*bl) / 0.95047
+	y := 0.2126*r + 0.7152*g + 0.0722*bl
+	z := (0.0193*r + 0.1192*g + 0.9505*bl) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
@@ -0,0 +1,41 @@
+package vips
+
+import (
+	"image/color"
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+func TestLabOf(t *testing.T) {
+	cases := []struct {
+		c       color.RGBA
+		l, a, b float64
+	}{
+		{color.RGBA{255, 255, 255, 255}, 100, 0, 0},
+		{color.RGBA{0, 0, 0, 255}, 0, 0, 0},
+		{color.RGBA{255, 0, 0, 255}, 53.24, 80.09, 67.20},
+		{color.RGBA{0, 0, 255, 255}, 32.30, 79.19, -107.86},
+	}
+	for _, c := range cases {
+		l, a, b := labOf(c.c)
+		if math.Abs(l-c.l) > 0.1 || math.Abs(a-c.a) > 0.1 || math.Abs(b-c.b) > 0.1 {
+			t.Errorf("labOf(%v) = %.2f, %.2f, %.2f, want %.2f, %.2f, %.2f", c.c, l, a, b, c.l, c.a, c.b)
+		}
+	}
+}
+
+func TestTint(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Tint(buf, color.RGBA{R: 112, G: 66, B: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detectType(out) != JPEG {
+		t.Errorf("Tint() changed the format to %v", detectType(out))
+	}
+}
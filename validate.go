@@ -0,0 +1,108 @@
+package vips
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors for bad input, as opposed to libvips failing on a valid request.
+// They may be wrapped with details; test for them with errors.Is.
+var (
+	// ErrEmptyBuffer is returned for sources with no bytes to decode.
+	ErrEmptyBuffer = errors.New("vips: empty buffer")
+	// ErrUnknownFormat is returned for sources no loader recognises.
+	ErrUnknownFormat = errors.New("vips: unknown image format")
+	// ErrInvalidDimensions is returned for negative sizes and the like.
+	ErrInvalidDimensions = errors.New("vips: invalid dimensions")
+	// ErrUnsupportedSaveType is returned when Savetype names a format that
+	// is not registered, can only be loaded or is missing from libvips.
+	ErrUnsupportedSaveType = errors.New("vips: unsupported save type")
+	// ErrInvalidOption is returned for other settings out of range.
+	ErrInvalidOption = errors.New("vips: invalid option")
+)
+
+// Validate checks o without touching an image. Resize and the other
+// operations taking Options call it first.
+func (o Options) Validate() error {
+	if o.Width < 0 || o.Height < 0 {
+		return fmt.Errorf("%w: %dx%d", ErrInvalidDimensions, o.Width, o.Height)
+	}
+
+	if o.Savetype != UNKNOWN && o.Savetype != AUTO {
+		if f, ok := formatOf(o.Savetype); !ok || !f.canSave {
+			return fmt.Errorf("%w: %v", ErrUnsupportedSaveType, o.Savetype)
+		}
+	}
+	for _, t := range o.AutoFormats {
+		if f, ok := formatOf(t); !ok || !f.canSave {
+			return fmt.Errorf("%w: auto format %v", ErrUnsupportedSaveType, t)
+		}
+	}
+
+	if o.TargetPSNR < 0 {
+		return fmt.Errorf("%w: target PSNR %v", ErrInvalidOption, o.TargetPSNR)
+	}
+	quality := o.Quality
+	if quality == QUALITY_AUTO {
+		quality = 0
+	}
+
+	for _, q := range []struct {
+		name       string
+		value, max int
+	}{
+		{"quality", quality, 100},
+		{"JPEG quality", o.JPEG.Quality, 100},
+		{"PNG compression", o.PNG.Compression, 9},
+		{"WebP quality", o.WebP.Quality, 100},
+		{"WebP reduction effort", o.WebP.ReductionEffort, 6},
+		{"WebP alpha quality", o.WebP.AlphaQuality, 100},
+		{"effort", int(o.Effort), int(EFFORT_SMALLEST)},
+	} {
+		if q.value < 0 || q.value > q.max {
+			return fmt.Errorf("%w: %s %d", ErrInvalidOption, q.name, q.value)
+		}
+	}
+
+	if o.MaxInputBytes < 0 || o.MaxDimension < 0 || o.MaxPixels < 0 || o.MaxMemory < 0 || o.MaxCost.Megapixels < 0 || o.MaxCost.Operations < 0 {
+		return fmt.Errorf("%w: negative limit", ErrInvalidOption)
+	}
+	if o.Priority < PRIORITY_INTERACTIVE || o.Priority > PRIORITY_BATCH {
+		return fmt.Errorf("%w: priority %d", ErrInvalidOption, o.Priority)
+	}
+	for _, d := range o.DecodeFallbacks {
+		if d < DECODER_NATIVE || d > DECODER_MAGICK {
+			return fmt.Errorf("%w: decoder %d", ErrInvalidOption, d)
+		}
+	}
+	if o.Timeout < 0 {
+		return fmt.Errorf("%w: timeout %v", ErrInvalidOption, o.Timeout)
+	}
+	if o.Grain < 0 {
+		return fmt.Errorf("%w: grain %v", ErrInvalidOption, o.Grain)
+	}
+	if _, ok := colourBlindnessNames[o.SimulateColourBlindness]; !ok {
+		return fmt.Errorf("%w: colour blindness %d", ErrInvalidOption, o.SimulateColourBlindness)
+	}
+	if o.Watermark != nil && o.Watermark.Strength < 0 {
+		return fmt.Errorf("%w: watermark strength %v", ErrInvalidOption, o.Watermark.Strength)
+	}
+	if o.Median < 0 || o.Slice < 0 {
+		return fmt.Errorf("%w: median %d, slice %d", ErrInvalidOption, o.Median, o.Slice)
+	}
+	if err := o.CropMargins.validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOption, err)
+	}
+	if o.AspectRatio != "" {
+		if _, err := parseAspectRatio(o.AspectRatio); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidOption, err)
+		}
+	}
+	if len(o.Convolve.Values) > 0 {
+		if err := o.Convolve.validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidOption, err)
+		}
+	}
+
+	return nil
+}
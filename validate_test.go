@@ -0,0 +1,36 @@
+package vips
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		o    Options
+		want error
+	}{
+		{Options{Width: 100, Height: 100, Savetype: PNG}, nil},
+		{Options{Width: -1}, ErrInvalidDimensions},
+		{Options{Savetype: ImageType(1000)}, ErrUnsupportedSaveType},
+		{Options{Savetype: NIFTI}, ErrUnsupportedSaveType},
+		{Options{Quality: 101}, ErrInvalidOption},
+		{Options{PNG: PNGOptions{Compression: 10}}, ErrInvalidOption},
+		{Options{AspectRatio: "wide"}, ErrInvalidOption},
+		{Options{Convolve: Kernel{Width: 3, Height: 3, Values: []float64{1}}}, ErrInvalidOption},
+	}
+	for _, c := range cases {
+		if err := c.o.Validate(); !errors.Is(err, c.want) {
+			t.Errorf("%+v.Validate() = %v, want %v", c.o, err, c.want)
+		}
+	}
+}
+
+func TestResizeTypedErrors(t *testing.T) {
+	if _, err := Resize([]byte("\x00\x01\x02\x03 not an image"), Options{}); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Resize() of garbage = %v, want %v", err, ErrUnknownFormat)
+	}
+	if _, err := Resize(nil, Options{Height: -5}); !errors.Is(err, ErrInvalidDimensions) {
+		t.Errorf("Resize() with a negative height = %v, want %v", err, ErrInvalidDimensions)
+	}
+}
@@ -0,0 +1,96 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"strings"
+)
+
+// Reason classifies why Verify rejected an image.
+type Reason int
+
+const (
+	REASON_UNKNOWN Reason = iota
+	REASON_UNSUPPORTED_FORMAT
+	REASON_TRUNCATED
+	REASON_BAD_HUFFMAN
+	REASON_CRC_MISMATCH
+)
+
+var reasons = map[Reason]string{
+	REASON_UNKNOWN:            "corrupt",
+	REASON_UNSUPPORTED_FORMAT: "unsupported_format",
+	REASON_TRUNCATED:          "truncated",
+	REASON_BAD_HUFFMAN:        "bad_huffman",
+	REASON_CRC_MISMATCH:       "crc_mismatch",
+}
+
+// String returns a stable code for r, suitable for storing alongside
+// quarantined assets.
+func (r Reason) String() string { return reasons[r] }
+
+// VerifyError is returned by Verify for images that do not decode cleanly.
+type VerifyError struct {
+	Reason Reason
+	// Message is the libvips error text the reason was derived from.
+	Message string
+}
+
+func (e *VerifyError) Error() string {
+	return "vips: " + e.Reason.String() + ": " + e.Message
+}
+
+// reasonPatterns maps fragments of libjpeg, libpng, libwebp and libvips
+// messages onto reasons, checked in order.
+var reasonPatterns = []struct {
+	pattern string
+	reason  Reason
+}{
+	{"not in a known format", REASON_UNSUPPORTED_FORMAT},
+	{"huffman", REASON_BAD_HUFFMAN},
+	{"crc", REASON_CRC_MISMATCH},
+	{"premature end", REASON_TRUNCATED},
+	{"truncated", REASON_TRUNCATED},
+	{"unexpected end", REASON_TRUNCATED},
+	{"not enough image data", REASON_TRUNCATED},
+	{"read error", REASON_TRUNCATED},
+}
+
+func classify(message string) Reason {
+	lower := strings.ToLower(message)
+	for _, p := range reasonPatterns {
+		if strings.Contains(lower, p.pattern) {
+			return p.reason
+		}
+	}
+	return REASON_UNKNOWN
+}
+
+// Verify fully decodes buf without encoding anything, treating decoder
+// warnings as errors. Failures are returned as a *VerifyError so ingestion
+// can quarantine corrupt assets with a reason code.
+func Verify(buf []byte) error {
+	if len(buf) == 0 {
+		return &VerifyError{Reason: REASON_TRUNCATED, Message: "empty buffer"}
+	}
+
+	release, err := acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	if err := vipsVerifyBuffer(buf); err != nil {
+		return &VerifyError{Reason: classify(err.Error()), Message: strings.TrimSpace(err.Error())}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package vips
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	var testCases = []struct {
+		message string
+		reason  Reason
+	}{
+		{"VipsForeignLoad: buffer is not in a known format", REASON_UNSUPPORTED_FORMAT},
+		{"VipsJpeg: Premature end of JPEG file", REASON_TRUNCATED},
+		{"VipsJpeg: Corrupt JPEG data: bad Huffman code", REASON_BAD_HUFFMAN},
+		{"IHDR: CRC error", REASON_CRC_MISMATCH},
+		{"something else", REASON_UNKNOWN},
+	}
+
+	for index, tc := range testCases {
+		if reason := classify(tc.message); reason != tc.reason {
+			t.Errorf("%d. classify(%q) => %v, want %v", index, tc.message, reason, tc.reason)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Verify(buf); err != nil {
+		t.Errorf("Verify(1.jpg) => %v", err)
+	}
+
+	err = Verify(buf[:len(buf)/2])
+	if verr, ok := err.(*VerifyError); !ok || verr.Reason != REASON_TRUNCATED {
+		t.Errorf("Verify(truncated) => %#v, want truncated", err)
+	}
+}
@@ -0,0 +1,41 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import "fmt"
+
+// VERSION is the semantic version of this package.
+//
+// 2.0.0 adds INTERPOLATOR_DEFAULT as the zero Interpolator, renumbering
+// BICUBIC, BILINEAR and NOHALO: stored values, and Options sent to
+// Isolator workers built from 1.x, change meaning. Resize also applies
+// Options.Rotate, Flip and Flop, which it used to ignore, turning the
+// pixels or, with OrientByTag, the EXIF orientation of JPEG results.
+const VERSION = "2.0.0"
+
+// VipsVersion returns the version of the libvips the package is linked
+// against. It needs no Initialize.
+func VipsVersion() (major, minor, micro int) {
+	return int(C.vips_version(0)), int(C.vips_version(1)), int(C.vips_version(2))
+}
+
+// RequireVipsVersion returns an error when the linked libvips is older than
+// major.minor.micro, so deployments can check at startup rather than fail
+// on a missing operation later.
+func RequireVipsVersion(major, minor, micro int) error {
+	have := [3]int{}
+	have[0], have[1], have[2] = VipsVersion()
+	want := [3]int{major, minor, micro}
+	for i := range have {
+		if have[i] != want[i] {
+			if have[i] < want[i] {
+				return fmt.Errorf("vips: libvips %d.%d.%d is older than %d.%d.%d", have[0], have[1], have[2], major, minor, micro)
+			}
+			break
+		}
+	}
+	return nil
+}
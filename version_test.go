@@ -0,0 +1,23 @@
+package vips
+
+import "testing"
+
+func TestVipsVersion(t *testing.T) {
+	major, minor, micro := VipsVersion()
+	if major < 7 || minor < 0 || micro < 0 {
+		t.Errorf("VipsVersion() = %d.%d.%d", major, minor, micro)
+	}
+
+	if err := RequireVipsVersion(major, minor, micro); err != nil {
+		t.Errorf("RequireVipsVersion(own version) = %v", err)
+	}
+	if err := RequireVipsVersion(major, minor-1, micro+1); err != nil {
+		t.Errorf("RequireVipsVersion(older minor) = %v", err)
+	}
+	if err := RequireVipsVersion(major, minor, micro+1); err == nil {
+		t.Errorf("RequireVipsVersion(newer micro) accepted")
+	}
+	if err := RequireVipsVersion(major+1, 0, 0); err == nil {
+		t.Errorf("RequireVipsVersion(newer major) accepted")
+	}
+}
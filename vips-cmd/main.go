@@ -10,6 +10,11 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		report(os.Args[2:])
+		return
+	}
+
 	filename := ""
 	options := vips.Options{Extend: vips.EXTEND_WHITE}
 	flag.StringVar(&filename, "file", "", "input file")
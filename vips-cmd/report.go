@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/daddye/vips"
+)
+
+// report implements `vips-cmd report [-format csv|json] path...`.
+func report(args []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	format := flags.String("format", "csv", "output format, csv or json")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: vips-cmd report [-format csv|json] path...")
+		flags.PrintDefaults()
+		return
+	}
+
+	entries, err := vips.Report(flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	switch *format {
+	case "json":
+		err = vips.WriteReportJSON(os.Stdout, entries)
+	case "csv":
+		err = vips.WriteReportCSV(os.Stdout, entries)
+	default:
+		err = fmt.Errorf("unknown report format %q", *format)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
@@ -8,22 +8,31 @@ import "C"
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"image/color"
+	"io"
+	"io/ioutil"
 	"math"
+	"net/http"
 	"os"
+	"path/filepath"
 	"runtime"
-	"unsafe"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
 )
 
-const DEBUG = false
-
 var (
 	MARKER_JPEG = []byte{0xff, 0xd8}
 	MARKER_PNG  = []byte{0x89, 0x50}
-    MARKER_WEBP  = []byte{0x57, 0x45, 0x42, 0x50}
-    MARKER_RIFF  = []byte{0x52, 0x49, 0x46, 0x46}
+	MARKER_WEBP = []byte{0x57, 0x45, 0x42, 0x50}
+	MARKER_RIFF = []byte{0x52, 0x49, 0x46, 0x46}
+	MARKER_BMP  = []byte{0x42, 0x4d}
 )
 
 type ImageType int
@@ -33,14 +42,39 @@ const (
 	JPEG
 	PNG
 	WEBP
+	// BMP is loaded through ImageMagick and cannot be saved.
+	BMP
 )
 
+// AUTO as Savetype picks the format from Options.AutoFormats that suits
+// the result: one with alpha for transparent images, lossless for
+// graphics and lossy for photos. It is only for saving.
+const AUTO ImageType = -1
+
+// String returns the name of the registered format t, or "unknown".
+func (t ImageType) String() string {
+	if t == AUTO {
+		return "auto"
+	}
+	if f, ok := formatOf(t); ok {
+		return f.Name
+	}
+	return "unknown"
+}
+
 type Interpolator int
 
 const (
-	BICUBIC Interpolator = iota
+	// INTERPOLATOR_DEFAULT uses Config.Interpolator, or BICUBIC when that
+	// is unset too. It was added in 2.0.0, renumbering the others.
+	INTERPOLATOR_DEFAULT Interpolator = iota
+	BICUBIC
 	BILINEAR
 	NOHALO
+	// LANCZOS3 resizes with a Lanczos kernel rather than interpolating,
+	// which keeps more detail when shrinking. Other transforms use bicubic
+	// interpolation for it.
+	LANCZOS3
 )
 
 type Extend int
@@ -48,12 +82,35 @@ type Extend int
 const (
 	EXTEND_BLACK Extend = C.VIPS_EXTEND_BLACK
 	EXTEND_WHITE Extend = C.VIPS_EXTEND_WHITE
+	// EXTEND_BACKGROUND fills with Options.Background.
+	EXTEND_BACKGROUND Extend = C.VIPS_EXTEND_BACKGROUND
 )
 
 var interpolations = map[Interpolator]string{
-	BICUBIC:  "bicubic",
-	BILINEAR: "bilinear",
-	NOHALO:   "nohalo",
+	INTERPOLATOR_DEFAULT: "default",
+	BICUBIC:              "bicubic",
+	BILINEAR:             "bilinear",
+	NOHALO:               "nohalo",
+	LANCZOS3:             "lanczos3",
+}
+
+// Colourspace is the colour space of the output image.
+type Colourspace int
+
+const (
+	COLOURSPACE_SRGB Colourspace = iota
+	COLOURSPACE_B_W
+	COLOURSPACE_CMYK
+	// COLOURSPACE_LAB keeps float CIELAB data, which needs an output format
+	// able to store it.
+	COLOURSPACE_LAB
+)
+
+var interpretations = map[Colourspace]C.VipsInterpretation{
+	COLOURSPACE_SRGB: C.VIPS_INTERPRETATION_sRGB,
+	COLOURSPACE_B_W:  C.VIPS_INTERPRETATION_B_W,
+	COLOURSPACE_CMYK: C.VIPS_INTERPRETATION_CMYK,
+	COLOURSPACE_LAB:  C.VIPS_INTERPRETATION_LAB,
 }
 
 type Angle int
@@ -83,90 +140,585 @@ type Options struct {
 	Embed        bool
 	Interpolator Interpolator
 	Gravity      Gravity
-	Quality      int
-	LeftPos      float32
-	TopPos       float32
-	Savetype     ImageType
+	// Quality is used by the JPEG and WebP encoders when their own
+	// options leave it zero, 100 by default. QUALITY_AUTO searches for the
+	// lowest quality that keeps TargetPSNR.
+	Quality int
+	// TargetPSNR is the peak signal-to-noise ratio in dB QUALITY_AUTO
+	// keeps between the image and its encoding, 40 by default.
+	TargetPSNR float64
+	LeftPos    float32
+	TopPos     float32
+	Savetype   ImageType
+	// AutoFormats are the formats Savetype AUTO picks from, JPEG and PNG
+	// by default; add WEBP and others the clients take.
+	AutoFormats  []ImageType
 	NoAutoRotate bool
+	// Rotate, then Flip (left to right) and Flop (top to bottom), turn the
+	// result after resizing.
 	Rotate Angle
-	Flip bool
-	Flop bool
+	Flip   bool
+	Flop   bool
+	// OrientByTag turns JPEG results by Rotate, Flip and Flop with the
+	// EXIF orientation tag rather than by moving pixels, which is faster
+	// and, when nothing else changes, leaves the pixels as they were.
+	// Other formats are turned as usual.
+	OrientByTag bool
+	// RotateDegrees rotates the result clockwise by any angle, growing the
+	// canvas to fit and filling the corners with Background. Use Rotate
+	// for multiples of 90, which are lossless.
+	RotateDegrees float64
+	// Affine maps the result through a matrix in one interpolation pass,
+	// for shears, skews and combined scale and rotation. The canvas grows
+	// to fit and is filled with Background.
+	Affine AffineMatrix
+	// Caption overlays the EXIF capture date and an optional location.
+	Caption *Caption
+	// Watermark invisibly marks the result with a number to trace leaked
+	// copies by. See ReadWatermark.
+	Watermark *Watermark
+	// CropMargins cuts fixed borders off the source before anything else,
+	// Trim included.
+	CropMargins Margins
+	// AspectRatio such as "16:9" crops the image to that shape, sizing the
+	// box from Width or Height when only one is set, or as large as the
+	// source allows when neither is. It is ignored when both are set.
+	AspectRatio string
+	// CropRegion, when set, is asked for the part of the source to keep,
+	// such as the faces a detector found. The region is widened to the
+	// shape of Width x Height when cropping, kept within the image and
+	// extracted before resizing.
+	CropRegion CropRegionProvider
+	// Background is the colour EXTEND_BACKGROUND pads with. Anything less
+	// than opaque needs a format with alpha, such as PNG or WebP.
+	Background color.RGBA
+	// Flatten composites transparent images onto Background, white when
+	// zero, so they don't turn black when saved without alpha.
+	Flatten bool
+	// Median replaces each pixel by the median of the Median x Median
+	// window around it, removing speckle noise.
+	Median int
+	// Convolve filters the image with a kernel, for sharpening, blurring
+	// or edge detection. Off when the kernel has no values.
+	Convolve Kernel
+	// Gamma applies vips_gamma with this exponent when set. Contrast scales
+	// pixel values around mid grey (zero means 1, unchanged) and Brightness
+	// is added afterwards, on the 0-255 scale.
+	Gamma      float64
+	Contrast   float64
+	Brightness float64
+	// Modulate adjusts lightness, saturation and hue.
+	Modulate Modulation
+	// Invert produces the negative of the image, leaving alpha alone.
+	Invert bool
+	// Tint replaces the colour of the image with a cast of this colour,
+	// keeping only its lightness, for duotone effects. Off when Tint.A is 0.
+	Tint color.RGBA
+	// SimulateColourBlindness shows the result as seen with a colour
+	// vision deficiency, for accessibility previews. It applies after
+	// Caption, so the whole visible result is simulated.
+	SimulateColourBlindness ColourBlindness
+	// Grain adds monochrome film grain, gaussian noise of this standard
+	// deviation on the 0-255 scale, after resizing.
+	Grain float64
+	// Seed seeds the random operations, Grain so far. The same seed gives
+	// the same output bytes on every run, so zero is as reproducible as any
+	// other; vary it for different noise.
+	Seed int64
+	// Colourspace of the output, sRGB by default. Other steps work in sRGB
+	// and the conversion happens last.
+	Colourspace Colourspace
+	// Trim removes margins of TrimBackground colour (white when zero) from
+	// the source before resizing. TrimThreshold is how far a pixel may be
+	// from the background and still count as margin, 10 by default.
+	Trim           bool
+	TrimBackground color.RGBA
+	TrimThreshold  float64
+	// Stretch maps the source range onto 0-255 before anything else, for
+	// FITS and other high bit depth data.
+	Stretch Stretch
+	// MaxInputBytes, MaxDimension and MaxPixels reject larger sources
+	// before their pixels are decoded, against decompression bombs. Zero
+	// keeps DefaultLimits; ResizeFile only applies limits set here.
+	MaxInputBytes int
+	MaxDimension  int
+	MaxPixels     int64
+	// FailOnError refuses damaged sources, such as truncated JPEGs, which
+	// are otherwise decoded as far as they go with the rest left grey.
+	FailOnError bool
+	// Slice picks the slice of volumes, such as NIfTI scans, to render,
+	// counting from 0. The first is used by default.
+	Slice int
+	// BandFormat casts the result last, for example to FORMAT_FLOAT to save
+	// FITS that keeps fractional values.
+	BandFormat BandFormat
+	// Effort trades encoding CPU for bytes across formats, defaulting to
+	// the one SetEffort chose.
+	Effort Effort
+	// MaxMemory bounds the bytes the decoded source may take in memory, so
+	// one giant request can't starve the others. Larger sources are
+	// decoded to a temporary file on disc with SpillToDisc, and refused
+	// with ErrLimitExceeded otherwise. Zero is unlimited.
+	MaxMemory   int64
+	SpillToDisc bool
+	// MaxCost refuses calls that would cost more, with ErrBudgetExceeded,
+	// before the pixels of the source are decoded. Zero fields don't cap.
+	MaxCost Cost
+	// DecodeFallbacks are tried in order when the source fails to decode,
+	// such as DECODER_MAGICK for files only ImageMagick opens. Sources are
+	// then decoded into memory up front, so damage is caught before
+	// anything is saved, and JPEG shrink-on-load does not apply.
+	DecodeFallbacks []Decoder
+	// Priority queues background work behind user-facing requests when
+	// Config.MaxOperations or Config.Adaptive bounds the operations.
+	Priority Priority
+	// AlignMCU moves crops of JPEG sources saved as JPEG without scaling
+	// onto the 8 or 16 pixel block grid of the source, so the blocks are
+	// encoded again unchanged and, at the source quality, show no new
+	// artifacts. Crops may start up to a block earlier than asked.
+	AlignMCU bool
+	// KeepC2PA copies the Content Credentials manifest of JPEG and PNG
+	// sources into JPEG and PNG results, where it is otherwise stripped
+	// with the rest of the metadata. Resize and ResizeWithInfo only.
+	KeepC2PA bool
+	// Timeout kills the decoding and encoding of sources that take longer,
+	// failing with ErrTimeout, so pathological inputs can't hold a worker
+	// for minutes. Zero is unlimited. Resize, ResizeWithInfo and
+	// ResizeFile only.
+	Timeout time.Duration
+	// JPEG, PNG and WebP tune the encoder for each format, as quality and
+	// effort mean different things to each.
+	JPEG JPEGOptions
+	PNG  PNGOptions
+	WebP WebPOptions
+
+	// into receives the result of ResizeInto.
+	into *bytes.Buffer
 }
 
-func init() {
-	Initialize()
+// JPEGOptions tunes the JPEG encoder.
+type JPEGOptions struct {
+	// Quality 1-100; zero falls back to Options.Quality.
+	Quality int
+	// Interlace writes a progressive JPEG.
+	Interlace bool
+	// KeepMetadata keeps EXIF, ICC and XMP data, stripped by default.
+	KeepMetadata bool
+}
+
+// PNGOptions tunes the PNG encoder.
+type PNGOptions struct {
+	// Compression is the zlib level 1-9; zero follows Options.Effort, or
+	// keeps the libvips default of 6.
+	Compression int
+	// Interlace writes an Adam7 interlaced PNG.
+	Interlace bool
 }
 
-var initialized bool
+// WebPOptions tunes the WebP encoder.
+type WebPOptions struct {
+	// Quality 1-100; zero falls back to Options.Quality.
+	Quality int
+	// Lossless encoding, NearLossless preprocessing (tuned by Quality) and
+	// SmartSubsample for sharper chroma.
+	Lossless       bool
+	NearLossless   bool
+	SmartSubsample bool
+	// ReductionEffort 0-6; zero follows Options.Effort, or keeps the
+	// libvips default of 4.
+	ReductionEffort int
+	// AlphaQuality 0-100; zero means 100.
+	AlphaQuality int
+}
 
-func Initialize() {
-	if initialized {
-		return
+// The package starts libvips on import unless built with the
+// vips_noautoinit tag or run with VIPS_NOAUTOINIT set, for programs that
+// would rather start it, and handle it failing to, with TryInitialize.
+func init() {
+	if autoInitialize && os.Getenv("VIPS_NOAUTOINIT") == "" {
+		TryInitialize()
 	}
+}
+
+var (
+	// lifecycle is held for writing while libvips starts, is retuned or
+	// shuts down, and for reading by every operation in between.
+	lifecycle   sync.RWMutex
+	initialized bool
+	refs        int
+	// startErr is why libvips last failed to start.
+	startErr error
+)
+
+// ErrNotInitialized is returned by operations started while libvips is not
+// running, either because it failed to start, wrapped with the reason
+// libvips gave, or after Shutdown.
+var ErrNotInitialized = errors.New("vips: not initialized")
+
+// Config tunes libvips. Start from DefaultConfig and override what you
+// need; values are handed to libvips unchanged, so a zero cache limit
+// disables that limit's cache.
+type Config struct {
+	// Concurrency is the number of worker threads per pipeline. Zero lets
+	// libvips decide from VIPS_CONCURRENCY or the number of CPUs.
+	Concurrency int
+	// CacheMaxMem is the operation cache size in bytes.
+	CacheMaxMem int
+	// CacheMaxOps is the number of operations kept in the cache.
+	CacheMaxOps int
+	// CacheMaxFiles is the number of open files kept in the cache.
+	CacheMaxFiles int
+	// ReportLeaks makes libvips print leaked objects on Shutdown, and logs
+	// the Images freed by the garbage collector rather than Close.
+	ReportLeaks bool
+	// MaxOperations bounds the operations running at once, queueing the
+	// others. Zero runs them all at once.
+	MaxOperations int
+	// Adaptive tunes MaxOperations and Concurrency at runtime instead:
+	// operations start at GOMAXPROCS at once and grow in number while they
+	// queue or shrink when they slow each other down, and the threads of
+	// their pipelines share GOMAXPROCS.
+	Adaptive bool
+	// Interpolator is used by operations whose Options leave it at
+	// INTERPOLATOR_DEFAULT, so a whole fleet can switch to LANCZOS3
+	// without touching every call site.
+	Interpolator Interpolator
+}
+
+// DefaultConfig is the configuration applied by Initialize.
+var DefaultConfig = Config{
+	Concurrency:   1,
+	CacheMaxMem:   100 * 1048576, // 100Mb
+	CacheMaxOps:   500,
+	CacheMaxFiles: 100,
+}
+
+// Initialize starts libvips with DefaultConfig. The package starts libvips
+// on import, so this is only needed when that is turned off, after Shutdown
+// or to hold a reference:
+// every Initialize must be balanced by a Shutdown, and libvips is shut down
+// by the last one.
+func Initialize() error {
+	return InitializeWithConfig(DefaultConfig)
+}
+
+// TryInitialize starts libvips with DefaultConfig unless it is running, as
+// the package does on import, returning the reason libvips gave when it
+// fails to. Unlike Initialize it takes no reference, so needs no Shutdown.
+func TryInitialize() error {
+	return initialize(DefaultConfig, false)
+}
+
+// InitializeWithConfig starts libvips if needed and applies c. It can be
+// called again at any time to retune a running process, and takes a
+// reference like Initialize.
+func InitializeWithConfig(c Config) error {
+	return initialize(c, true)
+}
+
+func initialize(c Config, counted bool) error {
+	lifecycle.Lock()
+	defer lifecycle.Unlock()
 
 	runtime.LockOSThread()
 	defer runtime.UnlockOSThread()
 
-	if err := C.vips_initialize(); err != 0 {
-		C.vips_shutdown()
-		panic("unable to start vips!")
+	if !initialized {
+		if err := C.vips_initialize(); err != 0 {
+			startErr = fmt.Errorf("vips: unable to start: %s", strings.TrimSpace(C.GoString(C.vips_error_buffer())))
+			C.vips_error_clear()
+			C.vips_shutdown()
+			return startErr
+		}
+		initialized = true
+		startErr = nil
+		detectFormats()
+	} else if !counted {
+		// TryInitialize leaves a running libvips as it is
+		return nil
+	}
+
+	if counted {
+		refs++
 	}
 
-	C.vips_concurrency_set(1)
-	C.vips_cache_set_max_mem(100 * 1048576) // 100Mb
-	C.vips_cache_set_max(500)
+	if c.Adaptive {
+		ops.configure(0, true, func(limit int) {
+			C.vips_concurrency_set(C.int(int(math.Max(1, float64(runtime.GOMAXPROCS(0)/limit)))))
+		})
+	} else {
+		ops.configure(c.MaxOperations, false, nil)
+		C.vips_concurrency_set(C.int(c.Concurrency))
+	}
+	C.vips_cache_set_max_mem(C.size_t(c.CacheMaxMem))
+	C.vips_cache_set_max(C.int(c.CacheMaxOps))
+	C.vips_cache_set_max_files(C.int(c.CacheMaxFiles))
+	SetLeakDetection(c.ReportLeaks)
+	atomic.StoreInt32(&defaultInterpolator, int32(c.Interpolator))
 
-	initialized = true
+	return nil
 }
 
+// Shutdown releases a reference taken by Initialize, shutting libvips down
+// once none are left. It waits for running operations to finish, and
+// operations started afterwards fail with ErrNotInitialized.
 func Shutdown() {
+	lifecycle.Lock()
+	defer lifecycle.Unlock()
+
 	if !initialized {
 		return
 	}
 
+	if refs > 0 {
+		refs--
+	}
+	if refs > 0 {
+		return
+	}
+
+	stages.purge()
 	C.vips_shutdown()
 
 	initialized = false
 }
 
+// Drain refuses new operations with ErrDraining, waits for running ones to
+// finish and then shuts libvips down regardless of outstanding Initialize
+// references. If ctx ends first Drain returns its error, and the shutdown
+// still happens in the background once the last operation is done.
+func Drain(ctx context.Context) error {
+	atomic.StoreInt32(&draining, 1)
+
+	done := make(chan struct{})
+	go func() {
+		lifecycle.Lock()
+		if initialized {
+			stages.purge()
+			C.vips_shutdown()
+			initialized = false
+		}
+		refs = 0
+		atomic.StoreInt32(&draining, 0)
+		lifecycle.Unlock()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// draining is set while Drain waits for running operations.
+var draining int32
+
+// ErrDraining is returned by operations started during Drain.
+var ErrDraining = errors.New("vips: draining")
+
+// acquire waits for its turn under Config.MaxOperations and keeps libvips
+// running until the returned release func is called. Public operations
+// take it once; they must not call each other while holding it, as a
+// waiting Shutdown blocks nested readers.
+func acquire() (func(), error) {
+	return acquireAt(PRIORITY_INTERACTIVE)
+}
+
+// acquireAt is acquire queueing at priority p.
+func acquireAt(p Priority) (func(), error) {
+	if atomic.LoadInt32(&draining) != 0 {
+		return nil, ErrDraining
+	}
+
+	admitted := ops.acquire(p)
+
+	lifecycle.RLock()
+	if !initialized {
+		err := ErrNotInitialized
+		if startErr != nil {
+			err = fmt.Errorf("%w: %v", ErrNotInitialized, startErr)
+		}
+		lifecycle.RUnlock()
+		admitted()
+		return nil, err
+	}
+	return func() {
+		lifecycle.RUnlock()
+		admitted()
+	}, nil
+}
+
 func Debug() {
 	C.im__print_all()
 }
 
-func Resize(buf []byte, o Options) ([]byte, error) {
+// MemStats is a snapshot of the resources tracked by libvips.
+type MemStats struct {
+	// Mem is the number of bytes currently allocated for pixel buffers.
+	Mem int64
+	// MemHighwater is the largest Mem seen since libvips started.
+	MemHighwater int64
+	// Allocs is the number of live tracked allocations.
+	Allocs int
+	// Files is the number of open files.
+	Files int
+}
+
+// MemoryStats reports the memory and files libvips is holding, useful to
+// spot leaks and size caches in long-lived services.
+func MemoryStats() MemStats {
+	return MemStats{
+		Mem:          int64(C.vips_tracked_get_mem()),
+		MemHighwater: int64(C.vips_tracked_get_mem_highwater()),
+		Allocs:       int(C.vips_tracked_get_allocs()),
+		Files:        int(C.vips_tracked_get_files()),
+	}
+}
+
+// SetLeakDetection turns on libvips reference leak reporting, printed to
+// stderr on Shutdown, and the logging of Images left to the garbage
+// collector, with where they were made.
+func SetLeakDetection(enabled bool) {
+	C.vips_leak_set(cbool(enabled))
+	if enabled {
+		atomic.StoreInt32(&reportLeaks, 1)
+	} else {
+		atomic.StoreInt32(&reportLeaks, 0)
+	}
+}
+
+// reportLeaks is set while SetLeakDetection is on.
+var reportLeaks int32
+
+func Resize(buf []byte, o Options) (out []byte, err error) {
 	debug("%#+v", o)
+	defer audit("resize", buf, o)(&out, &err)
 
-	// detect (if possible) the file type
-	typ := UNKNOWN
-	switch {
-	case bytes.Equal(buf[:2], MARKER_JPEG):
-		typ = JPEG
-	case bytes.Equal(buf[:2], MARKER_PNG):
-		typ = PNG
-    case bytes.Equal(buf[:4], MARKER_RIFF) && bytes.Equal(buf[8:12], MARKER_WEBP):
-        typ = WEBP
-	//default:
-	//	return nil, errors.New("unknown image format")
+	r, err := resize(buf, o)
+	return r.Buf, err
+}
+
+// Result is an encoded image with the properties callers would otherwise
+// decode it again for.
+type Result struct {
+	Buf           []byte
+	Width, Height int
+	// Channels counts the bands saved, alpha included.
+	Channels int
+	Format   ImageType
+	// Size is len(Buf).
+	Size int
+	// Warnings list what was lost converting the source to Format.
+	Warnings []Warning
+	// Decoder is the decoder that opened the source.
+	Decoder Decoder
+	// C2PA reports a Content Credentials manifest in the source, kept in
+	// Buf with Options.KeepC2PA.
+	C2PA bool
+	// Cost is what the call is metered at.
+	Cost Cost
+}
+
+// ResizeWithInfo is Resize returning the dimensions, channels and format
+// of the output alongside it.
+func ResizeWithInfo(buf []byte, o Options) (r Result, err error) {
+	debug("%#+v", o)
+	defer audit("resize", buf, o)(&r.Buf, &err)
+
+	return resize(buf, o)
+}
+
+func resize(buf []byte, o Options) (Result, error) {
+	if err := o.Validate(); err != nil {
+		return Result{}, err
 	}
 
-	// create an image instance
-	var image, tmpImage *C.struct__VipsImage
+	release, err := acquireAt(o.Priority)
+	if err != nil {
+		return Result{}, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	timeout := startDeadline(o.Timeout)
+	defer timeout.stop()
+
+	image, src, err := resizeImage(buf, o)
+	if err != nil {
+		return Result{}, err
+	}
+	timeout.watch(image)
+
+	r, err := saveResult(image, src, o)
+	if err != nil {
+		return Result{}, timeout.check(err)
+	}
+	store := c2paOf(buf)
+	r.C2PA = store != nil
+	if o.KeepC2PA {
+		r.Buf = withC2PA(r.Buf, store)
+		r.Size = len(r.Buf)
+	}
+
+	return r, nil
+}
+
+// saveResult saves image, made from src, as o asks and releases it.
+func saveResult(image *C.struct__VipsImage, src source, o Options) (Result, error) {
+	if o.Savetype == AUTO {
+		var err error
+		if image, o, err = autoSave(image, o); err != nil {
+			return Result{}, err
+		}
+	}
+
+	f := saverOf(o.Savetype)
+	r := Result{
+		Width:    int(image.Xsize),
+		Height:   int(image.Ysize),
+		Channels: int(image.Bands),
+		Format:   f.Type,
+		Warnings: conversionWarnings(src, image, f, o),
+		Decoder:  src.decoder,
+		Cost:     costOf(src, o),
+	}
+	// savers without alpha drop it
+	if !f.Alpha && C.vips_image_hasalpha(image) != 0 {
+		r.Channels--
+	}
+
+	buf, err := saveImage(image, o)
+	if err != nil {
+		return Result{}, err
+	}
+	r.Buf, r.Size = buf, len(buf)
+
+	return r, nil
+}
 
-	// feed it
-	switch typ {
-	case JPEG:
-		C.vips_jpegload_buffer_seq(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image)
-	case PNG:
-		C.vips_pngload_buffer_seq(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image)
-    case WEBP:
-        C.vips_webpload_buffer_custom(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image)
-    default:
-        ret := C.vips_magickload_buffer_custom(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image)
-        
-        if ret == -1 {
-            return nil, errors.New("-- unknown image format")
-        }
+// ResizeFile is Resize reading from and writing to disk. The input is
+// streamed with sequential access, so large sources are never copied
+// through Go memory. When o.Savetype is UNKNOWN the output format follows
+// the extension of outPath, falling back to JPEG.
+func ResizeFile(inPath, outPath string, o Options) error {
+	debug("%#+v", o)
+
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	release, err := acquireAt(o.Priority)
+	if err != nil {
+		return err
 	}
+	defer release()
 
 	// cleanup
 	defer func() {
@@ -174,15 +726,219 @@ func Resize(buf []byte, o Options) ([]byte, error) {
 		C.vips_error_clear()
 	}()
 
-	// defaults
-	if o.Quality == 0 {
-		o.Quality = 100
+	// sources on disk are streamed, only limits set in o apply
+	l := Limits{MaxBytes: o.MaxInputBytes, MaxWidth: o.MaxDimension, MaxHeight: o.MaxDimension, MaxPixels: o.MaxPixels}
+
+	f, err := os.Open(inPath)
+	if err != nil {
+		return err
+	}
+	if fi, err := f.Stat(); err == nil && l.MaxBytes > 0 && fi.Size() > int64(l.MaxBytes) {
+		f.Close()
+		return ErrLimitExceeded
+	}
+	magic := make([]byte, 12)
+	n, _ := io.ReadFull(f, magic)
+	f.Close()
+
+	cpath := C.CString(inPath)
+	defer C.free(unsafe.Pointer(cpath))
+
+	timeout := startDeadline(o.Timeout)
+	defer timeout.stop()
+
+	image := C.vips_load_from_file_seq(cpath, cbool(o.FailOnError))
+	if image == nil {
+		return resizeError()
+	}
+	if !l.allows(int(image.Xsize), int(image.Ysize)) {
+		C.g_object_unref(C.gpointer(image))
+		return ErrLimitExceeded
+	}
+	image, _, err = limitMemory(image, o)
+	if err != nil {
+		return err
+	}
+
+	if o.Savetype == UNKNOWN {
+		o.Savetype = TypeOfExt(filepath.Ext(outPath))
+	}
+
+	image, err = transformImage(image, detectType(magic[:n]), o, func(shrink int) (*C.struct__VipsImage, error) {
+		var out *C.struct__VipsImage
+		err := C.vips_jpegload_file_shrink(cpath, &out, C.int(shrink), cbool(o.FailOnError))
+		if err != 0 {
+			return nil, resizeError()
+		}
+		return out, nil
+	})
+	if err != nil {
+		return err
+	}
+	timeout.watch(image)
+
+	return timeout.check(saveFile(image, outPath, o))
+}
+
+// resizeImage decodes buf and applies the shrink, affine, crop and embed
+// steps described by o. The returned image, sRGB or grey with alpha, is
+// owned by the caller; src describes the decoded source.
+func resizeImage(buf []byte, o Options) (image *C.struct__VipsImage, src source, err error) {
+	buf = skipJunk(buf)
+	if len(o.DecodeFallbacks) > 0 {
+		image, typ, decoder, err := loadWithFallbacks(buf, o)
+		if err != nil {
+			return nil, src, err
+		}
+		src = sourceOf(image)
+		src.decoder = decoder
+		if err = checkCost(image, src, o); err != nil {
+			return nil, src, err
+		}
+		if image, _, err = limitMemory(image, o); err != nil {
+			return nil, src, err
+		}
+		image, err = transformImage(image, typ, o, nil)
+		return image, src, err
+	}
+	if stages.enabled() {
+		image, typ, err := loadStage(buf, limitsOf(o), o.FailOnError)
+		if err != nil {
+			return nil, src, err
+		}
+		src = sourceOf(image)
+		if err = checkCost(image, src, o); err != nil {
+			return nil, src, err
+		}
+		if image, _, err = limitMemory(image, o); err != nil {
+			return nil, src, err
+		}
+		image, err = transformImage(image, typ, o, nil)
+		return image, src, err
+	}
+
+	image, typ, err := loadBuffer(buf, limitsOf(o), o.FailOnError)
+	if err != nil {
+		return nil, src, err
+	}
+
+	src = sourceOf(image)
+	if err = checkCost(image, src, o); err != nil {
+		return nil, src, err
+	}
+	image, spilled, err := limitMemory(image, o)
+	if err != nil {
+		return nil, src, err
+	}
+	if spilled {
+		// a reload would decode to memory again
+		image, err = transformImage(image, typ, o, nil)
+		return image, src, err
+	}
+	image, err = transformImage(image, typ, o, func(shrink int) (*C.struct__VipsImage, error) {
+		var out *C.struct__VipsImage
+		err := C.vips_jpegload_buffer_shrink(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &out, C.int(shrink), cbool(o.FailOnError))
+		if err != 0 {
+			return nil, resizeError()
+		}
+		return out, nil
+	})
+	return image, src, err
+}
+
+// loadBuffer detects the format of buf and decodes it, refusing images
+// outside l before any pixels are decoded. With fail, damaged files such as
+// truncated JPEGs are errors rather than decoded as far as they go.
+func loadBuffer(buf []byte, l Limits, fail bool) (*C.struct__VipsImage, ImageType, error) {
+	buf = skipJunk(buf)
+	if len(buf) == 0 {
+		return nil, UNKNOWN, ErrEmptyBuffer
+	}
+	if l.MaxBytes > 0 && len(buf) > l.MaxBytes {
+		return nil, UNKNOWN, ErrLimitExceeded
+	}
+
+	// detect (if possible) the file type
+	typ := detectType(buf)
+
+	// feed it to the format's loader, or ImageMagick for unknown ones
+	load := loadMagickBuffer
+	if f, ok := formatOf(typ); ok {
+		if !f.canLoad {
+			return nil, typ, fmt.Errorf("%w: %s is not supported by this libvips", ErrUnknownFormat, f.Name)
+		}
+		load = f.load
+	}
+
+	image, err := load(buf, fail)
+	if err != nil {
+		return nil, typ, err
+	}
+
+	if !l.allows(int(image.Xsize), int(image.Ysize)) {
+		C.g_object_unref(C.gpointer(image))
+		return nil, typ, ErrLimitExceeded
+	}
+
+	return image, typ, nil
+}
+
+// transformImage applies the steps described by o to image, which was
+// decoded from a typ source and is released. JPEG sources are decoded again
+// through reload when they can be shrunk on load, unless reload is nil.
+// Explain lists the same steps, and changes here belong there too.
+func transformImage(image *C.struct__VipsImage, typ ImageType, o Options, reload func(shrink int) (*C.struct__VipsImage, error)) (*C.struct__VipsImage, error) {
+	var tmpImage *C.struct__VipsImage
+
+	if height, ok := vipsImageInt(image, "page-height"); ok && height > 0 && height < int(image.Ysize) {
+		var err error
+		image, err = extractSlice(image, height, o.Slice)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if o.CropMargins != (Margins{}) {
+		var err error
+		image, err = cropMargins(image, o.CropMargins)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if o.Trim {
+		var err error
+		image, err = vipsTrim(image, o.TrimThreshold, o.TrimBackground)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// get WxH
 	inWidth := int(image.Xsize)
 	inHeight := int(image.Ysize)
 
+	if o.AspectRatio != "" {
+		var err error
+		o, err = applyAspectRatio(o, inWidth, inHeight)
+		if err != nil {
+			C.g_object_unref(C.gpointer(image))
+			return nil, err
+		}
+	}
+
+	// crops keep the JPEG block grid when asked to and nothing is scaled
+	alignMCU := alignsMCU(typ, o)
+
+	if o.CropRegion != nil {
+		var err error
+		image, err = cropToRegion(image, o, alignMCU && o.Width == 0 && o.Height == 0)
+		if err != nil {
+			return nil, err
+		}
+		inWidth, inHeight = int(image.Xsize), int(image.Ysize)
+	}
+
 	// prepare for factor
 	factor := 0.0
 
@@ -238,7 +994,8 @@ func Resize(buf []byte, o Options) ([]byte, error) {
 
 	// Try to use libjpeg shrink-on-load
 	shrinkOnLoad := 1
-	if typ == JPEG && shrink >= 2 {
+	// (a reload would bring back trimmed margins and cropped regions)
+	if typ == JPEG && shrink >= 2 && !o.Trim && o.CropMargins == (Margins{}) && o.CropRegion == nil && reload != nil {
 		switch {
 		case shrink >= 8:
 			factor = factor / 8
@@ -259,11 +1016,19 @@ func Resize(buf []byte, o Options) ([]byte, error) {
 		shrink = int(math.Floor(factor))
 		residual = float64(shrink) / factor
 		// Reload input using shrink-on-load
-		err := C.vips_jpegload_buffer_shrink(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &tmpImage, C.int(shrinkOnLoad))
+		reloaded, err := reload(shrinkOnLoad)
 		C.g_object_unref(C.gpointer(image))
-		image = tmpImage
-		if err != 0 {
-			return nil, resizeError()
+		if err != nil {
+			return nil, err
+		}
+		image = reloaded
+	}
+
+	if o.Stretch != STRETCH_NONE {
+		var err error
+		image, err = vipsStretch(image, o.Stretch)
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -292,10 +1057,18 @@ func Resize(buf []byte, o Options) ([]byte, error) {
 
 	// Use vips_affine with the remaining float part
 	debug("residual: %v", residual)
-	if residual != 0 {
+	if interpolator := interpolatorOf(o.Interpolator); residual != 0 && interpolator == LANCZOS3 {
+		debug("residual %.2f with lanczos3", residual)
+		err := C.vips_resize_kernel(image, &tmpImage, C.double(residual), C.VIPS_KERNEL_LANCZOS3)
+		C.g_object_unref(C.gpointer(image))
+		image = tmpImage
+		if err != 0 {
+			return nil, resizeError()
+		}
+	} else if residual != 0 {
 		debug("residual %.2f", residual)
-		// Create interpolator - "bilinear" (default), "bicubic" or "nohalo"
-		is := C.CString(o.Interpolator.String())
+		// Create interpolator - "bicubic" (default), "bilinear" or "nohalo"
+		is := C.CString(interpolator.interpolate())
 		interpolator := C.vips_interpolate_new(is)
 
 		// Perform affine transformation
@@ -321,6 +1094,11 @@ func Resize(buf []byte, o Options) ([]byte, error) {
 			// Crop
 			debug("cropping")
 			left, top := sharpCalcCrop(affinedWidth, affinedHeight, o.Width, o.Height, o.LeftPos, o.TopPos, o.Gravity)
+			if alignMCU && factor == 1 {
+				mcuWidth, mcuHeight := mcuOf(image)
+				left, top = left-left%mcuWidth, top-top%mcuHeight
+				debug("aligned crop to %dx%d MCUs at %d,%d", mcuWidth, mcuHeight, left, top)
+			}
 			o.Width = int(math.Min(float64(affinedWidth), float64(o.Width)))
 			o.Height = int(math.Min(float64(affinedHeight), float64(o.Height)))
 			err := C.vips_extract_area_0(image, &tmpImage, C.int(left), C.int(top), C.int(o.Width), C.int(o.Height))
@@ -333,7 +1111,13 @@ func Resize(buf []byte, o Options) ([]byte, error) {
 			debug("embedding with extend %d", o.Extend)
 			left := (o.Width - affinedWidth) / 2
 			top := (o.Height - affinedHeight) / 2
-			err := C.vips_embed_extend(image, &tmpImage, C.int(left), C.int(top), C.int(o.Width), C.int(o.Height), C.int(o.Extend))
+			var err C.int
+			if o.Extend == EXTEND_BACKGROUND {
+				bg := o.Background
+				err = C.vips_embed_background(image, &tmpImage, C.int(left), C.int(top), C.int(o.Width), C.int(o.Height), C.double(bg.R), C.double(bg.G), C.double(bg.B), C.double(bg.A))
+			} else {
+				err = C.vips_embed_extend(image, &tmpImage, C.int(left), C.int(top), C.int(o.Width), C.int(o.Height), C.int(o.Extend))
+			}
 			C.g_object_unref(C.gpointer(image))
 			image = tmpImage
 			if err != 0 {
@@ -344,108 +1128,1072 @@ func Resize(buf []byte, o Options) ([]byte, error) {
 		debug("canvased same as affined")
 	}
 
-	// Always convert to sRGB colour space
+	if orientationOf(o) != (orientation{}) && !orientsByTag(o) {
+		var err error
+		image, err = vipsOrient(image, o)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Work in sRGB, converting to o.Colourspace at the end. Grey with
+	// alpha goes back to grey when nothing added colour.
+	greyAlpha := isGreyAlpha(image) && keepsGrey(o)
 	C.vips_colourspace_0(image, &tmpImage, C.VIPS_INTERPRETATION_sRGB)
 	C.g_object_unref(C.gpointer(image))
 	image = tmpImage
 
-	// Finally save
-	length := C.size_t(0)
-	var ptr unsafe.Pointer
-
-	switch o.Savetype {
-		case WEBP:
-			C.vips_webpsave_custom(image, &ptr, &length, C.int(o.Quality))
-		case PNG:
-			C.vips_pngsave_custom(image, &ptr, &length, 1, C.int(o.Quality), 0)
-		default:
-			C.vips_jpegsave_custom(image, &ptr, &length, 1, C.int(o.Quality), 0)
+	if math.Mod(o.RotateDegrees, 360) != 0 {
+		var err error
+		image, err = vipsRotateArbitrary(image, o.RotateDegrees, o.Background)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	C.g_object_unref(C.gpointer(image))
+	if o.Affine != (AffineMatrix{}) {
+		var err error
+		image, err = vipsAffine(image, o.Affine, o.Interpolator, o.Background)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	// get back the buffer
-	buf = C.GoBytes(ptr, C.int(length))
-	C.g_free(C.gpointer(ptr))
+	if o.Flatten {
+		var err error
+		image, err = vipsFlatten(image, o.Background)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	return buf, nil
-}
+	if o.Median > 0 {
+		var err error
+		image, err = vipsMedian(image, o.Median)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-func resizeError() error {
-	s := C.GoString(C.vips_error_buffer())
-	C.vips_error_clear()
-	return errors.New(s)
-}
+	if len(o.Convolve.Values) > 0 {
+		var err error
+		image, err = vipsConvolve(image, o.Convolve)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-type Gravity int
+	if o.Gamma != 0 || o.Contrast != 0 || o.Brightness != 0 {
+		var err error
+		image, err = vipsAdjust(image, o.Gamma, o.Contrast, o.Brightness)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-const (
-	CENTRE Gravity = iota
-	NORTH
-	EAST
-	SOUTH
-	WEST
-	CUSTOM
-)
+	if o.Modulate != (Modulation{}) {
+		var err error
+		image, err = vipsModulate(image, o.Modulate)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-func sharpCalcCrop(inWidth, inHeight, outWidth, outHeight int, customLeftPos, customTopPos float32, gravity Gravity) (int, int) {
-	left, top := 0, 0
-	switch gravity {
-	case NORTH:
-		left = (inWidth - outWidth + 1) / 2
-	case EAST:
-		left = inWidth - outWidth
-		top = (inHeight - outHeight + 1) / 2
-	case SOUTH:
-		left = (inWidth - outWidth + 1) / 2
-		top = inHeight - outHeight
-	case WEST:
-		top = (inHeight - outHeight + 1) / 2
-	case CUSTOM:
-		customLeft := float32(inWidth) * customLeftPos
-		customTop := float32(inHeight) * customTopPos
-		if customLeft + float32(outWidth) > float32(inWidth) {
-			left = inWidth - outWidth
-		} else {
-			left = int(customLeft)
+	if o.Invert {
+		var err error
+		image, err = vipsInvert(image)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		if customTop + float32(outHeight) > float32(inHeight) {
+	if o.Tint.A != 0 {
+		var err error
+		image, err = vipsTint(image, o.Tint)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if o.Grain > 0 {
+		var err error
+		image, err = vipsGrain(image, grainTile(o.Seed, o.Grain))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if o.Caption != nil {
+		var err error
+		image, err = drawCaption(image, *o.Caption)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if o.SimulateColourBlindness != COLOURBLIND_NONE {
+		var err error
+		image, err = vipsSimulateCVD(image, o.SimulateColourBlindness)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if o.Watermark != nil {
+		var err error
+		image, err = embedWatermark(image, *o.Watermark)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if greyAlpha && C.vips_image_hasalpha(image) != 0 {
+		debug("keeping grey with alpha")
+		err := C.vips_colourspace_0(image, &tmpImage, C.VIPS_INTERPRETATION_B_W)
+		C.g_object_unref(C.gpointer(image))
+		if err != 0 {
+			return nil, resizeError()
+		}
+		image = tmpImage
+	} else if o.Colourspace != COLOURSPACE_SRGB {
+		debug("colourspace %d", o.Colourspace)
+		err := C.vips_colourspace_0(image, &tmpImage, interpretations[o.Colourspace])
+		C.g_object_unref(C.gpointer(image))
+		if err != 0 {
+			return nil, resizeError()
+		}
+		image = tmpImage
+	}
+
+	if o.BandFormat != FORMAT_DEFAULT {
+		var err error
+		image, err = vipsCast(image, o.BandFormat)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return image, nil
+}
+
+// saveDefaults fills in the encoder settings o leaves zero.
+func saveDefaults(o Options) Options {
+	effort := effortOf(o)
+	if o.Quality == 0 || o.Quality == QUALITY_AUTO {
+		o.Quality = 100
+	}
+	if o.JPEG.Quality == 0 {
+		o.JPEG.Quality = o.Quality
+	}
+	if o.PNG.Compression == 0 {
+		o.PNG.Compression = 6
+		if effort != EFFORT_DEFAULT {
+			o.PNG.Compression = effort.scale(1, 9)
+		}
+	}
+	if o.WebP.Quality == 0 {
+		o.WebP.Quality = o.Quality
+	}
+	if o.WebP.ReductionEffort == 0 {
+		o.WebP.ReductionEffort = 4
+		if effort != EFFORT_DEFAULT {
+			o.WebP.ReductionEffort = effort.scale(0, 6)
+		}
+	}
+	if o.WebP.AlphaQuality == 0 {
+		o.WebP.AlphaQuality = 100
+	}
+	return o
+}
+
+// saveFile writes image to path as o.Savetype, JPEG when it is not a
+// registered format, and releases it.
+func saveFile(image *C.struct__VipsImage, path string, o Options) error {
+	if o.Savetype == AUTO {
+		var err error
+		if image, o, err = autoSave(image, o); err != nil {
+			return err
+		}
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	f := saverOf(o.Savetype)
+	if autoQuality(f, o) {
+		buf, err := saveAutoQuality(image, f, o)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, buf, 0666)
+	}
+	return f.saveFile(image, path, saveDefaults(o))
+}
+
+// saveImage encodes image as o.Savetype, JPEG when it is not a registered
+// format, and releases it.
+func saveImage(image *C.struct__VipsImage, o Options) ([]byte, error) {
+	if o.Savetype == AUTO {
+		var err error
+		if image, o, err = autoSave(image, o); err != nil {
+			return nil, err
+		}
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	f := saverOf(o.Savetype)
+	if autoQuality(f, o) {
+		return saveAutoQuality(image, f, o)
+	}
+	return f.save(image, saveDefaults(o))
+}
+
+func loadJpegBuffer(buf []byte, fail bool) (*C.struct__VipsImage, error) {
+	var image *C.struct__VipsImage
+	if C.vips_jpegload_buffer_seq(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image, cbool(fail)) != 0 {
+		return nil, resizeError()
+	}
+	return image, nil
+}
+
+func loadPngBuffer(buf []byte, fail bool) (*C.struct__VipsImage, error) {
+	var image *C.struct__VipsImage
+	if C.vips_pngload_buffer_seq(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image, cbool(fail)) != 0 {
+		return nil, resizeError()
+	}
+	return image, nil
+}
+
+func loadWebpBuffer(buf []byte, fail bool) (*C.struct__VipsImage, error) {
+	var image *C.struct__VipsImage
+	if C.vips_webpload_buffer_custom(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image, cbool(fail)) != 0 {
+		return nil, resizeError()
+	}
+	return image, nil
+}
+
+func loadMagickBuffer(buf []byte, fail bool) (*C.struct__VipsImage, error) {
+	var image *C.struct__VipsImage
+	if C.vips_magickload_buffer_custom(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image, cbool(fail)) != 0 {
+		C.vips_error_clear()
+		return nil, ErrUnknownFormat
+	}
+	return image, nil
+}
+
+// loadBmpBuffer decodes BMP, which libvips has no loader of its own for,
+// with ImageMagick.
+func loadBmpBuffer(buf []byte, fail bool) (*C.struct__VipsImage, error) {
+	var image *C.struct__VipsImage
+	if C.vips_magickload_buffer_custom(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image, cbool(fail)) != 0 {
+		return nil, resizeError()
+	}
+	return image, nil
+}
+
+// loadBufferAuto leaves it to libvips to find a loader for buf.
+func loadBufferAuto(buf []byte, fail bool) (*C.struct__VipsImage, error) {
+	image := C.vips_load_buffer_auto(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), cbool(fail))
+	if image == nil {
+		return nil, resizeError()
+	}
+	return image, nil
+}
+
+// savedBuffer copies the buffer a saver returned into Go memory, into the
+// buffer into, reset first, when given one.
+func savedBuffer(err C.int, ptr unsafe.Pointer, length C.size_t, into *bytes.Buffer) ([]byte, error) {
+	if err != 0 {
+		return nil, resizeError()
+	}
+	defer C.g_free(C.gpointer(ptr))
+
+	// get back the buffer
+	if into == nil {
+		return C.GoBytes(ptr, C.int(length)), nil
+	}
+	into.Reset()
+	into.Write(unsafe.Slice((*byte)(ptr), length))
+	return into.Bytes(), nil
+}
+
+func saveJpegBuffer(image *C.struct__VipsImage, o Options) ([]byte, error) {
+	image, tag, err := orientByTag(image, o)
+	if err != nil {
+		return nil, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	var ptr unsafe.Pointer
+	length := C.size_t(0)
+	cerr := C.vips_jpegsave_custom(image, &ptr, &length, cbool(!o.JPEG.KeepMetadata), C.int(o.JPEG.Quality), cbool(o.JPEG.Interlace))
+	buf, err := savedBuffer(cerr, ptr, length, o.into)
+	if err == nil && tag > 1 && !o.JPEG.KeepMetadata {
+		buf = jpegWithOrientation(buf, tag)
+	}
+	return buf, err
+}
+
+func savePngBuffer(image *C.struct__VipsImage, o Options) ([]byte, error) {
+	var ptr unsafe.Pointer
+	length := C.size_t(0)
+	err := C.vips_pngsave_custom(image, &ptr, &length, C.int(o.PNG.Compression), cbool(o.PNG.Interlace))
+	return savedBuffer(err, ptr, length, o.into)
+}
+
+func saveWebpBuffer(image *C.struct__VipsImage, o Options) ([]byte, error) {
+	var ptr unsafe.Pointer
+	length := C.size_t(0)
+	err := C.vips_webpsave_custom(image, &ptr, &length, C.int(o.WebP.Quality), cbool(o.WebP.Lossless), cbool(o.WebP.NearLossless), cbool(o.WebP.SmartSubsample), C.int(o.WebP.ReductionEffort), C.int(o.WebP.AlphaQuality))
+	return savedBuffer(err, ptr, length, o.into)
+}
+
+// saveBufferSuffix encodes image with the libvips saver for suffix, such as
+// ".tif" or ".tif[compression=lzw]".
+func saveBufferSuffix(image *C.struct__VipsImage, suffix string) ([]byte, error) {
+	csuffix := C.CString(suffix)
+	defer C.free(unsafe.Pointer(csuffix))
+
+	var ptr unsafe.Pointer
+	length := C.size_t(0)
+	err := C.vips_save_buffer_suffix(image, csuffix, &ptr, &length)
+	return savedBuffer(err, ptr, length, nil)
+}
+
+func saveJpegFile(image *C.struct__VipsImage, path string, o Options) error {
+	image, tag, err := orientByTag(image, o)
+	if err != nil {
+		return err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	if tag > 1 && !o.JPEG.KeepMetadata {
+		// the tag goes in an EXIF segment of its own, written here
+		buf, err := saveJpegBuffer(image, o)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, buf, 0666)
+	}
+
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	if C.vips_jpegsave_file_custom(image, cpath, cbool(!o.JPEG.KeepMetadata), C.int(o.JPEG.Quality), cbool(o.JPEG.Interlace)) != 0 {
+		return resizeError()
+	}
+	return nil
+}
+
+// orientByTag returns a reference to image, which it does not release,
+// with the EXIF orientation the JPEG savers should write for o, and that
+// orientation, 0 when o turns nothing by tag. With metadata kept, the tag
+// combines with the one of the source, so the result displays as it would
+// turned by moving pixels.
+func orientByTag(image *C.struct__VipsImage, o Options) (*C.struct__VipsImage, int, error) {
+	// only the JPEG savers call this, so o.OrientByTag is enough
+	turn := orientationOf(o)
+	if !o.OrientByTag || turn == (orientation{}) {
+		C.g_object_ref(C.gpointer(image))
+		return image, 0, nil
+	}
+
+	if o.JPEG.KeepMetadata {
+		source, _ := vipsImageInt(image, "orientation")
+		turn = turn.then(orientationOfTag(source))
+	}
+
+	var out *C.struct__VipsImage
+	if C.vips_set_orientation(image, &out, C.int(turn.tag())) != 0 {
+		return nil, 0, catchVipsError()
+	}
+	return out, turn.tag(), nil
+}
+
+func savePngFile(image *C.struct__VipsImage, path string, o Options) error {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	if C.vips_pngsave_file_custom(image, cpath, C.int(o.PNG.Compression), cbool(o.PNG.Interlace)) != 0 {
+		return resizeError()
+	}
+	return nil
+}
+
+func saveWebpFile(image *C.struct__VipsImage, path string, o Options) error {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	if C.vips_webpsave_file_custom(image, cpath, C.int(o.WebP.Quality), cbool(o.WebP.Lossless), cbool(o.WebP.NearLossless), cbool(o.WebP.SmartSubsample), C.int(o.WebP.ReductionEffort), C.int(o.WebP.AlphaQuality)) != 0 {
+		return resizeError()
+	}
+	return nil
+}
+
+// saveFileSuffix writes image to path with the libvips saver for suffix,
+// passing on any options in brackets after it.
+func saveFileSuffix(image *C.struct__VipsImage, path, suffix string) error {
+	if i := strings.Index(suffix, "["); i >= 0 {
+		path += suffix[i:]
+	}
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	if C.vips_save_file_auto(image, cpath) != 0 {
+		return resizeError()
+	}
+	return nil
+}
+
+func cbool(b bool) C.int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// detectType guesses the format of buf from the magic bytes of the
+// registered formats, looking past junk before the image, and failing that
+// from the content sniffing of net/http.
+func detectType(buf []byte) ImageType {
+	if t := matchType(skipJunk(buf)); t != UNKNOWN {
+		return t
+	}
+	return TypeOfMIME(http.DetectContentType(buf))
+}
+
+func resizeError() error {
+	s := C.GoString(C.vips_error_buffer())
+	C.vips_error_clear()
+	return errors.New(s)
+}
+
+type Gravity int
+
+const (
+	CENTRE Gravity = iota
+	NORTH
+	EAST
+	SOUTH
+	WEST
+	CUSTOM
+)
+
+func sharpCalcCrop(inWidth, inHeight, outWidth, outHeight int, customLeftPos, customTopPos float32, gravity Gravity) (int, int) {
+	left, top := 0, 0
+	switch gravity {
+	case NORTH:
+		left = (inWidth - outWidth + 1) / 2
+	case EAST:
+		left = inWidth - outWidth
+		top = (inHeight - outHeight + 1) / 2
+	case SOUTH:
+		left = (inWidth - outWidth + 1) / 2
+		top = inHeight - outHeight
+	case WEST:
+		top = (inHeight - outHeight + 1) / 2
+	case CUSTOM:
+		customLeft := float32(inWidth) * customLeftPos
+		customTop := float32(inHeight) * customTopPos
+		if customLeft+float32(outWidth) > float32(inWidth) {
+			left = inWidth - outWidth
+		} else {
+			left = int(customLeft)
+		}
+
+		if customTop+float32(outHeight) > float32(inHeight) {
 			top = inHeight - outHeight
 		} else {
 			top = int(customTop)
 		}
-	default:
-		left = (inWidth - outWidth + 1) / 2
-		top = (inHeight - outHeight + 1) / 2
+	default:
+		left = (inWidth - outWidth + 1) / 2
+		top = (inHeight - outHeight + 1) / 2
+	}
+	return left, top
+}
+
+// vipsLogHandlerSet routes the VIPS glib log domain to govipsLog.
+func vipsLogHandlerSet() C.guint {
+	return C.vips_log_handler_set()
+}
+
+func vipsLogHandlerUnset(id C.guint) {
+	C.vips_log_handler_unset(id)
+}
+
+func catchVipsError() error {
+	s := C.GoString(C.vips_error_buffer())
+	C.vips_error_clear()
+	C.vips_thread_shutdown()
+	return errors.New(s)
+}
+
+func vipsExifOrientation(image *C.struct__VipsImage) int {
+	orientation := int(C.vips_exif_orientation(image))
+	return orientation
+}
+
+func vipsRotate(image *C.struct__VipsImage, angle Angle) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_rotate(image, &out, C.int(angle))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsRotateArbitrary rotates image clockwise by degrees onto background.
+// It releases image.
+func vipsRotateArbitrary(image *C.struct__VipsImage, degrees float64, background color.RGBA) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	bg := background
+	err := C.vips_rotate_background(image, &out, C.double(degrees), C.double(bg.R), C.double(bg.G), C.double(bg.B), C.double(bg.A))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsAffine transforms image by m onto background. It releases image.
+func vipsAffine(image *C.struct__VipsImage, m AffineMatrix, interpolator Interpolator, background color.RGBA) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	is := C.CString(interpolatorOf(interpolator).interpolate())
+	defer C.free(unsafe.Pointer(is))
+	interp := C.vips_interpolate_new(is)
+	defer C.g_object_unref(C.gpointer(interp))
+
+	bg := background
+	err := C.vips_affine_background(image, &out, C.double(m.A), C.double(m.B), C.double(m.C), C.double(m.D), C.double(m.Dx), C.double(m.Dy), interp, C.double(bg.R), C.double(bg.G), C.double(bg.B), C.double(bg.A))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsOrient turns image by o.Rotate, then flips it by o.Flip and o.Flop,
+// and releases it.
+func vipsOrient(image *C.struct__VipsImage, o Options) (*C.struct__VipsImage, error) {
+	var err error
+	if angle := getAngle(o.Rotate); angle > D0 {
+		if image, err = vipsRotate(image, angle); err != nil {
+			return nil, err
+		}
+	}
+	if o.Flip {
+		if image, err = vipsFlip(image, HORIZONTAL); err != nil {
+			return nil, err
+		}
+	}
+	if o.Flop {
+		if image, err = vipsFlip(image, VERTICAL); err != nil {
+			return nil, err
+		}
+	}
+	return image, nil
+}
+
+func vipsFlip(image *C.struct__VipsImage, direction Direction) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_flip_bridge(image, &out, C.int(direction))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+func vipsCircleMask(image *C.struct__VipsImage) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_circle_mask(image, &out)
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+func vipsInitialsAvatar(text, font string, size int, c color.RGBA) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+
+	ctext := C.CString(text)
+	defer C.free(unsafe.Pointer(ctext))
+	cfont := C.CString(font)
+	defer C.free(unsafe.Pointer(cfont))
+
+	err := C.vips_initials_avatar(ctext, cfont, C.int(size), C.double(c.R), C.double(c.G), C.double(c.B), &out)
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsSamplePixels returns the uchar pixels of a grid of at most size x
+// size over image, which it keeps, with the width and height of the grid.
+func vipsSamplePixels(image *C.struct__VipsImage, size int) (pixels []byte, width, height int, err error) {
+	var length C.size_t
+	mem := C.vips_sample_pixels(image, C.int(size), &length)
+	if mem == nil {
+		return nil, 0, 0, catchVipsError()
+	}
+	defer C.g_free(C.gpointer(mem))
+
+	xfac := (int(image.Xsize) + size - 1) / size
+	yfac := (int(image.Ysize) + size - 1) / size
+	return C.GoBytes(mem, C.int(length)), int(image.Xsize) / xfac, int(image.Ysize) / yfac, nil
+}
+
+// vipsGreyPixels returns the uchar grey of image, which it keeps, squashed
+// to width x height.
+func vipsGreyPixels(image *C.struct__VipsImage, width, height int) ([]byte, error) {
+	var length C.size_t
+	mem := C.vips_grey_pixels(image, C.int(width), C.int(height), &length)
+	if mem == nil {
+		return nil, catchVipsError()
+	}
+	defer C.g_free(C.gpointer(mem))
+
+	if int(length) != width*height {
+		return nil, fmt.Errorf("vips: grey pixels of %d bytes, want %dx%d", length, width, height)
+	}
+	return C.GoBytes(mem, C.int(length)), nil
+}
+
+// vipsQuadrantColours returns the mean colour of the top-left, top-right,
+// bottom-left and bottom-right quarters of image, which it releases.
+func vipsQuadrantColours(image *C.struct__VipsImage) ([4]color.RGBA, error) {
+	var colours [4]color.RGBA
+	var rgb [12]byte
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_quadrant_colours(image, (*C.uchar)(unsafe.Pointer(&rgb[0])))
+	if err != 0 {
+		return colours, catchVipsError()
+	}
+
+	for i := range colours {
+		colours[i] = color.RGBA{rgb[i*3], rgb[i*3+1], rgb[i*3+2], 0xff}
+	}
+	return colours, nil
+}
+
+func vipsWatermarkMeans(image *C.struct__VipsImage) ([]float64, error) {
+	if image.Xsize < WATERMARK_GRID || image.Ysize < WATERMARK_GRID {
+		return nil, fmt.Errorf("vips: %dx%d is too small for a watermark", image.Xsize, image.Ysize)
+	}
+
+	means := make([]float64, WATERMARK_GRID*WATERMARK_GRID)
+	if C.vips_watermark_means(image, WATERMARK_GRID, (*C.double)(&means[0])) != 0 {
+		return nil, catchVipsError()
+	}
+	return means, nil
+}
+
+// vipsWatermarkAdd adds pattern to image and releases it.
+func vipsWatermarkAdd(image *C.struct__VipsImage, pattern []float64) (*C.struct__VipsImage, error) {
+	var out *C.struct__VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	if C.vips_watermark_add(image, (*C.double)(&pattern[0]), WATERMARK_GRID, &out) != 0 {
+		return nil, catchVipsError()
+	}
+	return out, nil
+}
+
+// vipsDZSave writes image as a tile pyramid named by o.Path and releases
+// it.
+func vipsDZSave(image *C.struct__VipsImage, o DeepZoomOptions) error {
+	defer C.g_object_unref(C.gpointer(image))
+
+	cpath := C.CString(o.Path)
+	defer C.free(unsafe.Pointer(cpath))
+	csuffix := C.CString(o.Suffix)
+	defer C.free(unsafe.Pointer(csuffix))
+
+	err := C.vips_dzsave_custom(image, cpath, C.int(o.TileSize), C.int(o.Overlap), csuffix, C.VipsForeignDzLayout(o.Layout), cbool(o.Zip))
+	if err != 0 {
+		return catchVipsError()
+	}
+	return nil
+}
+
+func vipsShrink(image *C.struct__VipsImage, shrink int) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_shrink_0(image, &out, C.double(shrink), C.double(shrink))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsEmbedBackground is vipsEmbed filling with background.
+func vipsEmbedBackground(image *C.struct__VipsImage, left, top, width, height int, background color.RGBA) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	bg := background
+	err := C.vips_embed_background(image, &out, C.int(left), C.int(top), C.int(width), C.int(height), C.double(bg.R), C.double(bg.G), C.double(bg.B), C.double(bg.A))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsFITSLoad reads the FITS file at path entirely into memory.
+func vipsFITSLoad(path string) (*C.struct__VipsImage, error) {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	image := C.vips_fitsload_memory(cpath)
+	if image == nil {
+		return nil, catchVipsError()
+	}
+	return image, nil
+}
+
+// vipsNIfTILoad reads the NIfTI volume at path entirely into memory, its
+// slices stacked top to bottom.
+func vipsNIfTILoad(path string) (*C.struct__VipsImage, error) {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	image := C.vips_niftiload_memory(cpath)
+	if image == nil {
+		return nil, catchVipsError()
+	}
+	return image, nil
+}
+
+func vipsFITSSave(image *C.struct__VipsImage, path string) error {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	if C.vips_fitssave_bridge(image, cpath) != 0 {
+		return catchVipsError()
+	}
+	return nil
+}
+
+// vipsMatrixLoad reads the matrix or, with csv, CSV file at path entirely
+// into memory.
+func vipsMatrixLoad(path string, csv bool) (*C.struct__VipsImage, error) {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	image := C.vips_matrixload_memory(cpath, cbool(csv))
+	if image == nil {
+		return nil, catchVipsError()
+	}
+	return image, nil
+}
+
+func vipsMatrixSave(image *C.struct__VipsImage, path string, csv bool) error {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	if C.vips_matrixsave_bridge(image, cpath, cbool(csv)) != 0 {
+		return catchVipsError()
+	}
+	return nil
+}
+
+// vipsMatrixSaveKernel writes k as a matrix or, with csv, CSV file.
+func vipsMatrixSaveKernel(k Kernel, path string, csv bool) error {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	values := make([]C.double, len(k.Values))
+	for i, v := range k.Values {
+		values[i] = C.double(v)
+	}
+
+	if C.vips_matrixsave_array(&values[0], C.int(k.Width), C.int(k.Height), C.double(k.scale()), C.double(k.Offset), cpath, cbool(csv)) != 0 {
+		return catchVipsError()
+	}
+	return nil
+}
+
+// vipsMatrixValues returns the pixels of image as float64s, row by row.
+func vipsMatrixValues(image *C.struct__VipsImage) ([]float64, error) {
+	var size C.size_t
+	ptr := C.vips_matrix_values(image, &size)
+	if ptr == nil {
+		return nil, catchVipsError()
+	}
+	defer C.g_free(C.gpointer(ptr))
+
+	values := make([]float64, int(size)/8)
+	for i, v := range (*[1 << 28]C.double)(unsafe.Pointer(ptr))[:len(values):len(values)] {
+		values[i] = float64(v)
+	}
+	return values, nil
+}
+
+// vipsStats returns the statistics matrix vips_stats makes of image, which
+// it releases: a row for all bands and then one for each, of min, max,
+// sum, sum of squares, mean, deviation and the positions of min and max.
+func vipsStats(image *C.struct__VipsImage) ([]float64, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	if C.vips_stats_0(image, &out) != 0 {
+		return nil, catchVipsError()
+	}
+	defer C.g_object_unref(C.gpointer(out))
+
+	return vipsMatrixValues(out)
+}
+
+// vipsHistogram returns the 256 bin histogram of image, which it
+// releases, bin by bin with the counts of every band of each together.
+func vipsHistogram(image *C.struct__VipsImage) ([]float64, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	if C.vips_histogram(image, &out) != 0 {
+		return nil, catchVipsError()
+	}
+	defer C.g_object_unref(C.gpointer(out))
+
+	return vipsMatrixValues(out)
+}
+
+// vipsCanvas makes a width x height image of colour c, with alpha.
+func vipsCanvas(width, height int, c color.RGBA) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+
+	err := C.vips_canvas(&out, C.int(width), C.int(height), C.double(c.R), C.double(c.G), C.double(c.B), C.double(c.A))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsComposite draws overlay, which it keeps, over base, which it
+// releases, with its top left corner at left, top.
+func vipsComposite(base, overlay *C.struct__VipsImage, left, top int) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(base))
+
+	err := C.vips_composite_at(base, overlay, &out, C.int(left), C.int(top))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsResizeKernel scales image by scale with kernel and releases image.
+func vipsResizeKernel(image *C.struct__VipsImage, scale float64, kernel C.VipsKernel) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_resize_kernel(image, &out, C.double(scale), kernel)
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsStretch maps the range of image onto 0-255 along curve and releases
+// image.
+func vipsStretch(image *C.struct__VipsImage, curve Stretch) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_stretch(image, &out, C.int(curve-STRETCH_LINEAR))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+func vipsCast(image *C.struct__VipsImage, format BandFormat) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_cast_0(image, &out, bandFormats[format])
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsFromPixels makes an image of a copy of the width x height pixels of
+// bands samples of format, as interpretation.
+func vipsFromPixels(pixels []byte, width, height, bands int, format BandFormat, interpretation C.VipsInterpretation) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+
+	err := C.vips_from_pixels(unsafe.Pointer(&pixels[0]), C.size_t(len(pixels)), C.int(width), C.int(height), C.int(bands), bandFormats[format], interpretation, &out)
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsRGBA returns the 8-bit sRGB pixels of image, which it keeps, with
+// alpha.
+func vipsRGBA(image *C.struct__VipsImage) ([]byte, error) {
+	var out *C.VipsImage
+
+	if C.vips_rgba(image, &out) != 0 {
+		return nil, catchVipsError()
+	}
+	defer C.g_object_unref(C.gpointer(out))
+
+	var length C.size_t
+	mem := C.vips_image_write_to_memory(out, &length)
+	if mem == nil {
+		return nil, catchVipsError()
+	}
+	defer C.g_free(C.gpointer(mem))
+
+	return C.GoBytes(mem, C.int(length)), nil
+}
+
+// vipsEmbed places image at left, top on a width x height canvas and
+// releases it.
+func vipsEmbed(image *C.struct__VipsImage, left, top, width, height int, extend Extend) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_embed_extend(image, &out, C.int(left), C.int(top), C.int(width), C.int(height), C.int(extend))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsGreyPage turns image into a one band page, thresholded to black and
+// white when bilevel. It releases image.
+func vipsGreyPage(image *C.struct__VipsImage, bilevel bool, threshold int) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_grey_page(image, &out, cbool(bilevel), C.double(threshold))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsPDFSavePages writes pages, which must all be the same size, as a
+// PDF and releases them.
+func vipsPDFSavePages(pages []*C.struct__VipsImage) ([]byte, error) {
+	defer func() {
+		for _, page := range pages {
+			C.g_object_unref(C.gpointer(page))
+		}
+	}()
+
+	var ptr unsafe.Pointer
+	length := C.size_t(0)
+	err := C.vips_pdfsave_pages(&pages[0], C.int(len(pages)), &ptr, &length)
+	return savedBuffer(err, ptr, length, nil)
+}
+
+// vipsImageInt reads the int metadata field name of image, ok false when
+// it has none.
+func vipsImageInt(image *C.struct__VipsImage, name string) (value int, ok bool) {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+
+	if C.vips_image_get_typeof(image, cname) == 0 {
+		return 0, false
 	}
-	return left, top
+
+	var out C.int
+	if C.vips_image_get_int(image, cname, &out) != 0 {
+		return 0, false
+	}
+	return int(out), true
 }
 
-func debug(format string, args ...interface{}) {
-	if !DEBUG {
-		return
+// vipsSpillToDisc decodes image to a temporary file, deleted once the
+// result is released, and releases image.
+func vipsSpillToDisc(image *C.struct__VipsImage) (*C.struct__VipsImage, error) {
+	defer C.g_object_unref(C.gpointer(image))
+
+	out := C.vips_spill_to_disc(image)
+	if out == nil {
+		return nil, catchVipsError()
 	}
-	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	return out, nil
 }
 
-func catchVipsError() error {
-	s := C.GoString(C.vips_error_buffer())
-	C.vips_error_clear()
-	C.vips_thread_shutdown()
-	return errors.New(s)
+// vipsPSNR is the peak signal-to-noise ratio between the bands a and b
+// have in common, +Inf when they are equal.
+func vipsPSNR(a, b *C.struct__VipsImage) (float64, error) {
+	var out C.double
+	if C.vips_psnr(a, b, &out) != 0 {
+		return 0, catchVipsError()
+	}
+	return float64(out), nil
 }
 
-func vipsExifOrientation(image *C.struct__VipsImage) int {
-	orientation := int(C.vips_exif_orientation(image))
-	return orientation
+// vipsImageString returns the string metadata field name of image, or ""
+// when it is not set.
+func vipsImageString(image *C.struct__VipsImage, name string) string {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+
+	if C.vips_image_get_typeof(image, cname) == 0 {
+		return ""
+	}
+
+	var out *C.char
+	if C.vips_image_get_string(image, cname, &out) != 0 {
+		return ""
+	}
+	return C.GoString(out)
 }
 
-func vipsRotate(image *C.struct__VipsImage, angle Angle) (*C.struct__VipsImage, error) {
+func vipsText(text, font string) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+
+	ctext := C.CString(text)
+	defer C.free(unsafe.Pointer(ctext))
+	cfont := C.CString(font)
+	defer C.free(unsafe.Pointer(cfont))
+
+	err := C.vips_text_mask(&out, ctext, cfont)
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsBlendMask paints c through mask onto image at left, top. It releases
+// image but not mask.
+func vipsBlendMask(image, mask *C.struct__VipsImage, left, top int, c color.RGBA) (*C.struct__VipsImage, error) {
 	var out *C.VipsImage
 	defer C.g_object_unref(C.gpointer(image))
 
-	err := C.vips_rotate(image, &out, C.int(angle))
+	err := C.vips_blend_mask(image, mask, &out, C.int(left), C.int(top), C.double(c.R), C.double(c.G), C.double(c.B))
 	if err != 0 {
 		return nil, catchVipsError()
 	}
@@ -453,11 +2201,221 @@ func vipsRotate(image *C.struct__VipsImage, angle Angle) (*C.struct__VipsImage,
 	return out, nil
 }
 
-func vipsFlip(image *C.struct__VipsImage, direction Direction) (*C.struct__VipsImage, error) {
+// vipsHeader opens file without decoding its pixels.
+func vipsHeader(file string) (*C.struct__VipsImage, error) {
+	cfile := C.CString(file)
+	defer C.free(unsafe.Pointer(cfile))
+
+	image := C.vips_load_from_file(cfile)
+	if image == nil {
+		return nil, catchVipsError()
+	}
+
+	return image, nil
+}
+
+func vipsHasField(image *C.struct__VipsImage, name string) bool {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+
+	return C.vips_image_get_typeof(image, cname) != 0
+}
+
+// vipsDecodeAll decodes every pixel of image, which is released.
+func vipsDecodeAll(image *C.struct__VipsImage) error {
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_decode_all(image)
+	if err != 0 {
+		return catchVipsError()
+	}
+	return nil
+}
+
+// vipsVerifyBuffer decodes every pixel of buf, failing on any warning.
+func vipsVerifyBuffer(buf []byte) error {
+	err := C.vips_verify_buffer(unsafe.Pointer(&buf[0]), C.size_t(len(buf)))
+	if err != 0 {
+		return resizeError()
+	}
+	return nil
+}
+
+func vipsExtractArea(image *C.struct__VipsImage, left, top, width, height int) (*C.struct__VipsImage, error) {
 	var out *C.VipsImage
 	defer C.g_object_unref(C.gpointer(image))
 
-	err := C.vips_flip_bridge(image, &out, C.int(direction))
+	err := C.vips_extract_area_0(image, &out, C.int(left), C.int(top), C.int(width), C.int(height))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsTrim crops away the margins of image that are within threshold of
+// background, releasing image.
+func vipsTrim(image *C.struct__VipsImage, threshold float64, background color.RGBA) (*C.struct__VipsImage, error) {
+	if threshold == 0 {
+		threshold = 10
+	}
+	if background == (color.RGBA{}) {
+		background = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	}
+
+	var left, top, width, height C.int
+	err := C.vips_find_trim_bridge(image, &left, &top, &width, &height, C.double(threshold), C.double(background.R), C.double(background.G), C.double(background.B))
+	if err != 0 {
+		C.g_object_unref(C.gpointer(image))
+		return nil, catchVipsError()
+	}
+
+	debug("trim to %dx%d+%d+%d", width, height, left, top)
+
+	// nothing but background, or nothing to trim
+	if width == 0 || height == 0 || (int(width) == int(image.Xsize) && int(height) == int(image.Ysize)) {
+		return image, nil
+	}
+
+	return vipsExtractArea(image, int(left), int(top), int(width), int(height))
+}
+
+func vipsMedian(image *C.struct__VipsImage, size int) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_median_colour(image, &out, C.int(size))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsConvolve filters the colour of image with k. It releases image.
+func vipsConvolve(image *C.struct__VipsImage, k Kernel) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	if err := k.validate(); err != nil {
+		return nil, err
+	}
+
+	values := make([]C.double, len(k.Values))
+	for i, v := range k.Values {
+		values[i] = C.double(v)
+	}
+
+	err := C.vips_conv_colour(image, &out, &values[0], C.int(k.Width), C.int(k.Height), C.double(k.scale()), C.double(k.Offset))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsFlatten removes the alpha of image by compositing it onto
+// background, white when zero. It releases image.
+func vipsFlatten(image *C.struct__VipsImage, background color.RGBA) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	if background == (color.RGBA{}) {
+		background = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	}
+
+	err := C.vips_flatten_background(image, &out, C.double(background.R), C.double(background.G), C.double(background.B))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+func vipsAdjust(image *C.struct__VipsImage, gamma, contrast, brightness float64) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	if contrast == 0 {
+		contrast = 1
+	}
+
+	err := C.vips_adjust(image, &out, C.double(gamma), C.double(contrast), C.double(brightness))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+func vipsModulate(image *C.struct__VipsImage, m Modulation) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	if m.Brightness == 0 {
+		m.Brightness = 1
+	}
+	if m.Saturation == 0 {
+		m.Saturation = 1
+	}
+
+	err := C.vips_modulate(image, &out, C.double(m.Brightness), C.double(m.Saturation), C.double(m.Hue))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+func vipsInvert(image *C.struct__VipsImage) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_invert_colour(image, &out)
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsGrain adds a GRAIN_TILE square of noise to image, repeated, and
+// releases it.
+func vipsGrain(image *C.struct__VipsImage, noise []float64) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	err := C.vips_grain(image, (*C.double)(&noise[0]), GRAIN_TILE, &out)
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsTint keeps the lightness of image and gives it the a and b of c in
+// CIELAB.
+func vipsTint(image *C.struct__VipsImage, c color.RGBA) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	_, a, b := labOf(c)
+	err := C.vips_tint(image, &out, C.double(a), C.double(b))
+	if err != 0 {
+		return nil, catchVipsError()
+	}
+
+	return out, nil
+}
+
+// vipsSimulateCVD shows image as seen with colour blindness c, and
+// releases it.
+func vipsSimulateCVD(image *C.struct__VipsImage, c ColourBlindness) (*C.struct__VipsImage, error) {
+	var out *C.VipsImage
+	defer C.g_object_unref(C.gpointer(image))
+
+	m := cvdMatrices[c]
+	err := C.vips_simulate_cvd(image, &out, (*C.double)(unsafe.Pointer(&m[0])))
 	if err != 0 {
 		return nil, catchVipsError()
 	}
@@ -547,45 +2505,51 @@ func rotateAndFlipImage(image *C.struct__VipsImage, o Options) (*C.struct__VipsI
 func AutoRotate(file string, o Options) ([]byte, error) {
 	debug("%#+v", o)
 
-   	// detect (if possible) the file type
-   	/*typ := UNKNOWN
-   	switch {
-   	case bytes.Equal(buf[:2], MARKER_JPEG):
-   		typ = JPEG
-   	case bytes.Equal(buf[:2], MARKER_PNG):
-   		typ = PNG
-   	default:
-   		return nil, errors.New("unknown image format")
-   	}
-
-   	// create an image instance
-   	var image, tmpImage *C.struct__VipsImage
-
-	// feed it
-   	switch typ {
-   	case JPEG:
-   		C.vips_jpegload_buffer_seq(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image)
-   	case PNG:
-   		C.vips_pngload_buffer_seq(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image)
-   	}*/
+	release, err := acquireAt(o.Priority)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// detect (if possible) the file type
+	/*typ := UNKNOWN
+	   	switch {
+	   	case bytes.Equal(buf[:2], MARKER_JPEG):
+	   		typ = JPEG
+	   	case bytes.Equal(buf[:2], MARKER_PNG):
+	   		typ = PNG
+	   	default:
+	   		return nil, errors.New("unknown image format")
+	   	}
+
+	   	// create an image instance
+	   	var image, tmpImage *C.struct__VipsImage
+
+		// feed it
+	   	switch typ {
+	   	case JPEG:
+	   		C.vips_jpegload_buffer_seq(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image)
+	   	case PNG:
+	   		C.vips_pngload_buffer_seq(unsafe.Pointer(&buf[0]), C.size_t(len(buf)), &image)
+	   	}*/
 	// create an image instance
-   	var image, tmpImage *C.struct__VipsImage
+	var image, tmpImage *C.struct__VipsImage
 
 	image = C.vips_load_from_file(C.CString(file))
 
 	// cleanup
-   	defer func() {
-   		C.vips_thread_shutdown()
-   		C.vips_error_clear()
-   	}()
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
 
 	if image == nil {
 		return nil, catchVipsError()
 	}
 
-	rotate,flip :=calculateRotationAndFlip(image, 0)
+	rotate, flip := calculateRotationAndFlip(image, 0)
 
-	if(rotate == 0 && !flip) {
+	if rotate == 0 && !flip {
 		return nil, nil //Remain unchanged
 	}
 
@@ -595,27 +2559,5 @@ func AutoRotate(file string, o Options) ([]byte, error) {
 		return nil, catchVipsError()
 	}
 
-	if o.Quality == 0 {
-		o.Quality = 100
-	}
-
-	length := C.size_t(0)
-	var ptr unsafe.Pointer
-
-	switch o.Savetype {
-		case WEBP:
-			C.vips_webpsave_custom(tmpImage, &ptr, &length, C.int(o.Quality))
-		case PNG:
-			C.vips_pngsave_custom(tmpImage, &ptr, &length, 1, C.int(o.Quality), 0)
-		default:
-			C.vips_jpegsave_custom(tmpImage, &ptr, &length, 1, C.int(o.Quality), 0)
-	}
-
-	C.g_object_unref(C.gpointer(tmpImage))
-
-	// get back the buffer
-	var buf []byte
-	buf = C.GoBytes(ptr, C.int(length))
-	C.g_free(C.gpointer(ptr))
-	return buf, nil
+	return saveImage(tmpImage, o)
 }
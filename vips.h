@@ -1,4 +1,5 @@
 #include <stdlib.h>
+#include <math.h>
 #include <vips/vips.h>
 #include <vips/vips7compat.h>
 
@@ -8,6 +9,26 @@ vips_initialize()
     return vips_init("govips");
 }
 
+extern void govipsLog(char *domain, int level, char *message);
+
+void
+vips_log_handler(const gchar *domain, GLogLevelFlags level, const gchar *message, gpointer data)
+{
+    govipsLog((char *) domain, level, (char *) message);
+}
+
+guint
+vips_log_handler_set()
+{
+    return g_log_set_handler("VIPS", G_LOG_LEVEL_MASK | G_LOG_FLAG_FATAL | G_LOG_FLAG_RECURSION, vips_log_handler, NULL);
+}
+
+void
+vips_log_handler_unset(guint id)
+{
+    g_log_remove_handler("VIPS", id);
+}
+
 int
 vips_affine_interpolator(VipsImage *in, VipsImage **out, double a, double b, double c, double d, VipsInterpolate *interpolator)
 {
@@ -15,32 +36,38 @@ vips_affine_interpolator(VipsImage *in, VipsImage **out, double a, double b, dou
 };
 
 int
-vips_jpegload_buffer_seq(void *buf, size_t len, VipsImage **out)
+vips_resize_kernel(VipsImage *in, VipsImage **out, double scale, VipsKernel kernel)
+{
+    return vips_resize(in, out, scale, "kernel", kernel, NULL);
+};
+
+int
+vips_jpegload_buffer_seq(void *buf, size_t len, VipsImage **out, int fail)
 {
-    return vips_jpegload_buffer(buf, len, out, "access", VIPS_ACCESS_SEQUENTIAL, NULL);
+    return vips_jpegload_buffer(buf, len, out, "access", VIPS_ACCESS_SEQUENTIAL, "fail", fail, NULL);
 };
 
 int
-vips_jpegload_buffer_shrink(void *buf, size_t len, VipsImage **out, int shrink)
+vips_jpegload_buffer_shrink(void *buf, size_t len, VipsImage **out, int shrink, int fail)
 {
-    return vips_jpegload_buffer(buf, len, out, "shrink", shrink, NULL);
+    return vips_jpegload_buffer(buf, len, out, "shrink", shrink, "fail", fail, NULL);
 };
 
 int
-vips_pngload_buffer_seq(void *buf, size_t len, VipsImage **out)
+vips_pngload_buffer_seq(void *buf, size_t len, VipsImage **out, int fail)
 {
-    return vips_pngload_buffer(buf, len, out, "access", VIPS_ACCESS_SEQUENTIAL, NULL);
+    return vips_pngload_buffer(buf, len, out, "access", VIPS_ACCESS_SEQUENTIAL, "fail", fail, NULL);
 };
 
 int
-vips_webpload_buffer_custom(void *buf, size_t len, VipsImage **out)
+vips_webpload_buffer_custom(void *buf, size_t len, VipsImage **out, int fail)
 {
-    return vips_webpload_buffer(buf, len, out, NULL);
+    return vips_webpload_buffer(buf, len, out, "fail", fail, NULL);
 };
 
 int
-vips_magickload_buffer_custom( void *buf, size_t len, VipsImage **out) {
-    return vips_magickload_buffer(buf, len, out, NULL);
+vips_magickload_buffer_custom( void *buf, size_t len, VipsImage **out, int fail) {
+    return vips_magickload_buffer(buf, len, out, "fail", fail, NULL);
 }
 
 int
@@ -80,15 +107,22 @@ vips_jpegsave_custom(VipsImage *in, void **buf, size_t *len, int strip, int qual
 }
 
 int
-vips_webpsave_custom(VipsImage *in, void **buf, size_t *len, int quality)
+vips_webpsave_custom(VipsImage *in, void **buf, size_t *len, int quality, int lossless, int near_lossless, int smart_subsample, int reduction_effort, int alpha_q)
 {
-    return vips_webpsave_buffer(in, buf, len, "Q", quality, NULL);
+    return vips_webpsave_buffer(in, buf, len,
+        "Q", quality,
+        "lossless", lossless,
+        "near_lossless", near_lossless,
+        "smart_subsample", smart_subsample,
+        "reduction_effort", reduction_effort,
+        "alpha_q", alpha_q,
+        NULL);
 }
 
 int
-vips_pngsave_custom(VipsImage *in, void **buf, size_t *len, int strip, int quality, int interlace)
+vips_pngsave_custom(VipsImage *in, void **buf, size_t *len, int compression, int interlace)
 {
-    return vips_pngsave_buffer(in, buf, len, "interlace", interlace, NULL);
+    return vips_pngsave_buffer(in, buf, len, "compression", compression, "interlace", interlace, NULL);
 }
 
 int
@@ -119,6 +153,53 @@ vips_rotate(VipsImage *in, VipsImage **out, int angle) {
     return vips_rot(in, out, rotate, NULL);
 }
 
+int
+vips_rotate_background(VipsImage *in, VipsImage **out, double angle, double r, double g, double b, double a) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 1);
+	double background[4] = {r, g, b, a};
+	VipsArrayDouble *bg;
+	int result;
+
+	// as for vips_embed_background, see-through corners need alpha
+	if (a < 255 && in->Bands == 3) {
+		if (vips_bandjoin_const1(in, &t[0], 255, NULL)) {
+			g_object_unref(base);
+			return -1;
+		}
+		in = t[0];
+	}
+
+	bg = vips_array_double_new(background, VIPS_MIN(in->Bands, 4));
+	result = vips_similarity(in, out, "angle", angle, "background", bg, "interpolate", vips_interpolate_bilinear_static(), NULL);
+	vips_area_unref(VIPS_AREA(bg));
+	g_object_unref(base);
+	return result;
+}
+
+int
+vips_affine_background(VipsImage *in, VipsImage **out, double a, double b, double c, double d, double dx, double dy, VipsInterpolate *interpolator, double r, double g, double bl, double alpha) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 1);
+	double background[4] = {r, g, bl, alpha};
+	VipsArrayDouble *bg;
+	int result;
+
+	if (alpha < 255 && in->Bands == 3) {
+		if (vips_bandjoin_const1(in, &t[0], 255, NULL)) {
+			g_object_unref(base);
+			return -1;
+		}
+		in = t[0];
+	}
+
+	bg = vips_array_double_new(background, VIPS_MIN(in->Bands, 4));
+	result = vips_affine(in, out, a, b, c, d, "odx", dx, "ody", dy, "interpolate", interpolator, "background", bg, NULL);
+	vips_area_unref(VIPS_AREA(bg));
+	g_object_unref(base);
+	return result;
+}
+
 int
 vips_autorotate(VipsImage *in, VipsImage **out) {
     return vips_autorot(in, out, NULL);
@@ -138,3 +219,979 @@ VipsImage*
 vips_load_from_file(char *file) {
     return vips_image_new_from_file(file, NULL);
 }
+
+VipsImage *
+vips_spill_to_disc(VipsImage *in) {
+	VipsImage *disc = vips_image_new_temp_file("%s.v");
+
+	if (!disc) {
+		return NULL;
+	}
+	if (vips_image_write(in, disc)) {
+		g_object_unref(disc);
+		return NULL;
+	}
+	return disc;
+}
+
+int
+vips_psnr(VipsImage *a, VipsImage *b, double *out) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 4);
+	// savers without alpha drop it, compare the bands both have
+	int bands = VIPS_MIN(a->Bands, b->Bands);
+	double mse;
+
+	if (
+		vips_extract_band(a, &t[0], 0, "n", bands, NULL) ||
+		vips_extract_band(b, &t[1], 0, "n", bands, NULL) ||
+		vips_subtract(t[0], t[1], &t[2], NULL) ||
+		vips_multiply(t[2], t[2], &t[3], NULL) ||
+		vips_avg(t[3], &mse, NULL)
+	) {
+		g_object_unref(base);
+		return -1;
+	}
+
+	g_object_unref(base);
+	*out = mse == 0 ? INFINITY : 10 * log10(255.0 * 255.0 / mse);
+	return 0;
+}
+
+int
+vips_circle_mask(VipsImage *in, VipsImage **out) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+	int radius = VIPS_MIN(in->Xsize, in->Ysize) / 2;
+
+	// drop any existing alpha, the circle becomes the new one
+	if (in->Bands == 2 || in->Bands == 4) {
+		if (vips_extract_band(in, &t[0], 0, "n", in->Bands - 1, NULL)) {
+			g_object_unref(base);
+			return -1;
+		}
+	} else {
+		t[0] = in;
+		g_object_ref(in);
+	}
+
+	if (
+		vips_black(&t[1], in->Xsize, in->Ysize, NULL) ||
+		vips_draw_circle1(t[1], 255, in->Xsize / 2, in->Ysize / 2, radius, "fill", TRUE, NULL) ||
+		vips_bandjoin2(t[0], t[1], out, NULL)
+	) {
+		g_object_unref(base);
+		return -1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int
+vips_initials_avatar(const char *text, const char *font, int size, double r, double g, double b, VipsImage **out) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 6);
+	double zero[3] = {0, 0, 0};
+	double background[3] = {r, g, b};
+	double white[3] = {255, 255, 255};
+
+	if (
+		vips_black(&t[0], size, size, "bands", 3, NULL) ||
+		vips_linear(t[0], &t[1], zero, background, 3, "uchar", TRUE, NULL) ||
+		vips_linear(t[0], &t[2], zero, white, 3, "uchar", TRUE, NULL) ||
+		vips_text(&t[3], text, "font", font, "align", VIPS_ALIGN_CENTRE, NULL) ||
+		vips_embed(t[3], &t[4], (size - t[3]->Xsize) / 2, (size - t[3]->Ysize) / 2, size, size, NULL) ||
+		vips_ifthenelse(t[4], t[2], t[1], &t[5], "blend", TRUE, NULL) ||
+		vips_circle_mask(t[5], out)
+	) {
+		g_object_unref(base);
+		return -1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+int
+vips_quadrant_colours(VipsImage *in, unsigned char *rgb) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+	void *mem;
+	size_t size;
+
+	if (in->Xsize < 2 || in->Ysize < 2 || in->Bands < 3) {
+		vips_error("vips_quadrant_colours", "need an RGB image of at least 2x2 pixels");
+		g_object_unref(base);
+		return -1;
+	}
+
+	if (
+		vips_extract_band(in, &t[0], 0, "n", 3, NULL) ||
+		vips_shrink(t[0], &t[1], in->Xsize / 2.0, in->Ysize / 2.0, NULL) ||
+		vips_cast(t[1], &t[2], VIPS_FORMAT_UCHAR, NULL) ||
+		!(mem = vips_image_write_to_memory(t[2], &size))
+	) {
+		g_object_unref(base);
+		return -1;
+	}
+
+	memcpy(rgb, mem, VIPS_MIN(size, 12));
+	g_free(mem);
+	g_object_unref(base);
+	return 0;
+}
+
+int
+vips_text_mask(VipsImage **out, const char *text, const char *font) {
+	return vips_text(out, text, "font", font, NULL);
+}
+
+int
+vips_blend_mask(VipsImage *in, VipsImage *mask, VipsImage **out, int left, int top, double r, double g, double b) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+	double zero[4] = {0, 0, 0, 0};
+	double ink[4] = {r, g, b, 255};
+	int bands = VIPS_MIN(in->Bands, 4);
+
+	// grey images take the red channel as ink, keeping alpha opaque
+	if (bands < 3) {
+		ink[1] = 255;
+	}
+
+	if (
+		vips_embed(mask, &t[0], left, top, in->Xsize, in->Ysize, NULL) ||
+		vips_black(&t[1], in->Xsize, in->Ysize, "bands", bands, NULL) ||
+		vips_linear(t[1], &t[2], zero, ink, bands, "uchar", TRUE, NULL) ||
+		vips_ifthenelse(t[0], t[2], in, out, "blend", TRUE, NULL)
+	) {
+		g_object_unref(base);
+		return -1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+VipsImage*
+vips_load_from_file_seq(char *file, int fail) {
+    return vips_image_new_from_file(file, "access", VIPS_ACCESS_SEQUENTIAL, "fail", fail, NULL);
+}
+
+VipsImage *
+vips_load_buffer_auto(void *buf, size_t len, int fail) {
+    return vips_image_new_from_buffer(buf, len, "", "access", VIPS_ACCESS_SEQUENTIAL, "fail", fail, NULL);
+}
+
+int
+vips_save_buffer_suffix(VipsImage *in, const char *suffix, void **buf, size_t *len) {
+    return vips_image_write_to_buffer(in, suffix, buf, len, NULL);
+}
+
+int
+vips_save_file_auto(VipsImage *in, const char *file) {
+    return vips_image_write_to_file(in, file, NULL);
+}
+
+int
+vips_dzsave_custom(VipsImage *in, const char *name, int tile_size, int overlap, const char *suffix, VipsForeignDzLayout layout, int zip)
+{
+    return vips_dzsave(in, name,
+        "tile_size", tile_size,
+        "overlap", overlap,
+        "suffix", suffix,
+        "layout", layout,
+        "container", zip ? VIPS_FOREIGN_DZ_CONTAINER_ZIP : VIPS_FOREIGN_DZ_CONTAINER_FS,
+        NULL);
+}
+
+int
+vips_jpegload_file_shrink(const char *file, VipsImage **out, int shrink, int fail)
+{
+    return vips_jpegload(file, out, "access", VIPS_ACCESS_SEQUENTIAL, "shrink", shrink, "fail", fail, NULL);
+}
+
+int
+vips_jpegsave_file_custom(VipsImage *in, const char *file, int strip, int quality, int interlace)
+{
+    return vips_jpegsave(in, file, "strip", strip, "Q", quality, "optimize_coding", TRUE, "interlace", interlace, NULL);
+}
+
+int
+vips_webpsave_file_custom(VipsImage *in, const char *file, int quality, int lossless, int near_lossless, int smart_subsample, int reduction_effort, int alpha_q)
+{
+    return vips_webpsave(in, file,
+        "Q", quality,
+        "lossless", lossless,
+        "near_lossless", near_lossless,
+        "smart_subsample", smart_subsample,
+        "reduction_effort", reduction_effort,
+        "alpha_q", alpha_q,
+        NULL);
+}
+
+int
+vips_pngsave_file_custom(VipsImage *in, const char *file, int compression, int interlace)
+{
+    return vips_pngsave(in, file, "compression", compression, "interlace", interlace, NULL);
+}
+
+int
+vips_decode_all(VipsImage *in) {
+	double avg;
+
+	// averaging touches every pixel, so the whole image gets decoded
+	return vips_avg(in, &avg, NULL);
+}
+
+int
+vips_verify_buffer(void *buf, size_t len) {
+	VipsImage *image;
+	double avg;
+
+	if (!(image = vips_image_new_from_buffer(buf, len, "", "access", VIPS_ACCESS_SEQUENTIAL, "fail", TRUE, NULL))) {
+		return -1;
+	}
+
+	// averaging touches every pixel, so the whole file gets decoded
+	if (vips_avg(image, &avg, NULL)) {
+		g_object_unref(image);
+		return -1;
+	}
+
+	g_object_unref(image);
+	return 0;
+}
+
+int
+vips_find_trim_bridge(VipsImage *in, int *left, int *top, int *width, int *height, double threshold, double r, double g, double b) {
+	double background[3] = {r, g, b};
+	VipsArrayDouble *bg;
+	int result;
+
+	// single band images are compared against the mean of the colour
+	if (in->Bands < 3) {
+		background[0] = (r + g + b) / 3;
+		bg = vips_array_double_new(background, 1);
+	} else {
+		bg = vips_array_double_new(background, 3);
+	}
+
+	result = vips_find_trim(in, left, top, width, height, "threshold", threshold, "background", bg, NULL);
+	vips_area_unref(VIPS_AREA(bg));
+	return result;
+}
+
+int
+vips_embed_background(VipsImage *in, VipsImage **out, int left, int top, int width, int height, double r, double g, double b, double a) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 2);
+	double background[4] = {r, g, b, a};
+	VipsArrayDouble *bg;
+	int result;
+
+	// the background is given in sRGB, and needs an alpha band to show
+	// through when it is not opaque
+	if (vips_colourspace(in, &t[0], VIPS_INTERPRETATION_sRGB, NULL)) {
+		g_object_unref(base);
+		return -1;
+	}
+	in = t[0];
+
+	if (a < 255 && in->Bands == 3) {
+		if (vips_bandjoin_const1(in, &t[1], 255, NULL)) {
+			g_object_unref(base);
+			return -1;
+		}
+		in = t[1];
+	}
+
+	bg = vips_array_double_new(background, VIPS_MIN(in->Bands, 4));
+	result = vips_embed(in, out, left, top, width, height, "extend", VIPS_EXTEND_BACKGROUND, "background", bg, NULL);
+	vips_area_unref(VIPS_AREA(bg));
+	g_object_unref(base);
+	return result;
+}
+
+int
+vips_flatten_background(VipsImage *in, VipsImage **out, double r, double g, double b) {
+	double background[3] = {r, g, b};
+	VipsArrayDouble *bg;
+	int result;
+
+	if (in->Bands != 2 && in->Bands != 4) {
+		return vips_copy(in, out, NULL);
+	}
+
+	if (in->Bands == 2) {
+		background[0] = (r + g + b) / 3;
+	}
+
+	bg = vips_array_double_new(background, in->Bands - 1);
+	result = vips_flatten(in, out, "background", bg, NULL);
+	vips_area_unref(VIPS_AREA(bg));
+	return result;
+}
+
+int
+vips_split_alpha(VipsObject *base, VipsImage *in, VipsImage **colour, VipsImage **alpha) {
+	VipsImage **t = (VipsImage **) vips_object_local_array(base, 2);
+
+	if (in->Bands != 2 && in->Bands != 4) {
+		*colour = in;
+		*alpha = NULL;
+		return 0;
+	}
+
+	if (
+		vips_extract_band(in, &t[0], 0, "n", in->Bands - 1, NULL) ||
+		vips_extract_band(in, &t[1], in->Bands - 1, "n", 1, NULL)
+	) {
+		return -1;
+	}
+
+	*colour = t[0];
+	*alpha = t[1];
+	return 0;
+}
+
+int
+vips_join_alpha(VipsImage *colour, VipsImage *alpha, VipsImage **out) {
+	if (alpha) {
+		return vips_bandjoin2(colour, alpha, out, NULL);
+	}
+	return vips_copy(colour, out, NULL);
+}
+
+int
+vips_adjust(VipsImage *in, VipsImage **out, double gamma, double contrast, double brightness) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 2);
+	VipsImage *colour, *alpha;
+	double a[1] = {contrast};
+	double b[1] = {128 * (1 - contrast) + brightness};
+	int result;
+
+	// leave alpha alone
+	if (vips_split_alpha(VIPS_OBJECT(base), in, &colour, &alpha)) {
+		g_object_unref(base);
+		return -1;
+	}
+
+	if (gamma > 0) {
+		if (vips_gamma(colour, &t[0], "exponent", gamma, NULL)) {
+			g_object_unref(base);
+			return -1;
+		}
+		colour = t[0];
+	}
+
+	// contrast pivots around mid grey
+	if (contrast != 1 || brightness != 0) {
+		if (vips_linear(colour, &t[1], a, b, 1, "uchar", TRUE, NULL)) {
+			g_object_unref(base);
+			return -1;
+		}
+		colour = t[1];
+	}
+
+	result = vips_join_alpha(colour, alpha, out);
+	g_object_unref(base);
+	return result;
+}
+
+int
+vips_modulate(VipsImage *in, VipsImage **out, double brightness, double saturation, double hue) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+	VipsImage *colour, *alpha;
+	double a[3] = {brightness, saturation, 1};
+	double b[3] = {0, 0, hue};
+	int result;
+
+	if (
+		vips_split_alpha(VIPS_OBJECT(base), in, &colour, &alpha) ||
+		vips_colourspace(colour, &t[0], VIPS_INTERPRETATION_LCH, NULL) ||
+		vips_linear(t[0], &t[1], a, b, 3, NULL) ||
+		vips_colourspace(t[1], &t[2], VIPS_INTERPRETATION_sRGB, NULL)
+	) {
+		g_object_unref(base);
+		return -1;
+	}
+
+	result = vips_join_alpha(t[2], alpha, out);
+	g_object_unref(base);
+	return result;
+}
+
+int
+vips_median_colour(VipsImage *in, VipsImage **out, int size) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 1);
+	VipsImage *colour, *alpha;
+	int result;
+
+	if (
+		vips_split_alpha(VIPS_OBJECT(base), in, &colour, &alpha) ||
+		vips_median(colour, &t[0], size, NULL)
+	) {
+		g_object_unref(base);
+		return -1;
+	}
+
+	result = vips_join_alpha(t[0], alpha, out);
+	g_object_unref(base);
+	return result;
+}
+
+int
+vips_conv_colour(VipsImage *in, VipsImage **out, double *values, int width, int height, double scale, double offset) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+	VipsImage *colour, *alpha;
+	int result;
+
+	if (!(t[0] = vips_image_new_matrix_from_array(width, height, values, width * height))) {
+		g_object_unref(base);
+		return -1;
+	}
+	vips_image_set_double(t[0], "scale", scale);
+	vips_image_set_double(t[0], "offset", offset);
+
+	if (
+		vips_split_alpha(VIPS_OBJECT(base), in, &colour, &alpha) ||
+		vips_conv(colour, &t[1], t[0], NULL) ||
+		vips_cast(t[1], &t[2], colour->BandFmt, NULL)
+	) {
+		g_object_unref(base);
+		return -1;
+	}
+
+	result = vips_join_alpha(t[2], alpha, out);
+	g_object_unref(base);
+	return result;
+}
+
+int
+vips_invert_colour(VipsImage *in, VipsImage **out) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 1);
+	VipsImage *colour, *alpha;
+	int result;
+
+	if (
+		vips_split_alpha(VIPS_OBJECT(base), in, &colour, &alpha) ||
+		vips_invert(colour, &t[0], NULL)
+	) {
+		g_object_unref(base);
+		return -1;
+	}
+
+	result = vips_join_alpha(t[0], alpha, out);
+	g_object_unref(base);
+	return result;
+}
+
+int
+vips_tint(VipsImage *in, VipsImage **out, double a, double b) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 5);
+	VipsImage *colour, *alpha;
+	double ab[2] = {a, b};
+	int result;
+
+	if (
+		vips_split_alpha(VIPS_OBJECT(base), in, &colour, &alpha) ||
+		vips_colourspace(colour, &t[0], VIPS_INTERPRETATION_LAB, NULL) ||
+		vips_extract_band(t[0], &t[1], 0, NULL) ||
+		vips_bandjoin_const(t[1], &t[2], ab, 2, NULL) ||
+		vips_copy(t[2], &t[3], "interpretation", VIPS_INTERPRETATION_LAB, NULL) ||
+		vips_colourspace(t[3], &t[4], VIPS_INTERPRETATION_sRGB, NULL)
+	) {
+		g_object_unref(base);
+		return -1;
+	}
+
+	result = vips_join_alpha(t[4], alpha, out);
+	g_object_unref(base);
+	return result;
+}
+
+// vips_simulate_cvd recombines the colour of in with the 3x3 matrix m in
+// linear light, leaving alpha alone.
+int
+vips_simulate_cvd(VipsImage *in, VipsImage **out, double *m) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 4);
+	VipsImage *colour, *alpha;
+	int result;
+
+	if (
+		vips_split_alpha(VIPS_OBJECT(base), in, &colour, &alpha) ||
+		vips_colourspace(colour, &t[0], VIPS_INTERPRETATION_scRGB, NULL) ||
+		!(t[1] = vips_image_new_matrix_from_array(3, 3, m, 9)) ||
+		vips_recomb(t[0], &t[2], t[1], NULL) ||
+		vips_colourspace(t[2], &t[3], VIPS_INTERPRETATION_sRGB, "source_space", VIPS_INTERPRETATION_scRGB, NULL)
+	) {
+		g_object_unref(base);
+		return -1;
+	}
+
+	result = vips_join_alpha(t[3], alpha, out);
+	g_object_unref(base);
+	return result;
+}
+
+int
+vips_grey_page(VipsImage *in, VipsImage **out, int bilevel, double threshold) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 4);
+	int result;
+
+	// go through the embedded profile, so grey levels match how the page
+	// looks rather than its raw device values
+	if (vips_image_get_typeof(in, VIPS_META_ICC_NAME)) {
+		if (vips_icc_import(in, &t[0], "embedded", TRUE, NULL)) {
+			g_object_unref(base);
+			return -1;
+		}
+		in = t[0];
+	}
+
+	if (
+		vips_flatten_background(in, &t[1], 255, 255, 255) ||
+		vips_colourspace(t[1], &t[2], VIPS_INTERPRETATION_B_W, NULL)
+	) {
+		g_object_unref(base);
+		return -1;
+	}
+	in = t[2];
+
+	if (bilevel) {
+		if (vips_moreeq_const1(in, &t[3], threshold, NULL)) {
+			g_object_unref(base);
+			return -1;
+		}
+		in = t[3];
+	}
+
+	result = vips_cast(in, out, VIPS_FORMAT_UCHAR, NULL);
+	g_object_unref(base);
+	return result;
+}
+
+int
+vips_pdfsave_pages(VipsImage **pages, int n, void **buf, size_t *len) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 2);
+	int result;
+
+	// a tall strip of equal pages with page-height set saves as one page
+	// each
+	if (
+		vips_arrayjoin(pages, &t[0], n, "across", 1, NULL) ||
+		vips_copy(t[0], &t[1], NULL)
+	) {
+		g_object_unref(base);
+		return -1;
+	}
+	vips_image_set_int(t[1], "page-height", pages[0]->Ysize);
+
+	result = vips_magicksave_buffer(t[1], buf, len, "format", "pdf", NULL);
+	g_object_unref(base);
+	return result;
+}
+
+VipsImage *
+vips_fitsload_memory(const char *file) {
+	VipsImage *image, *copy;
+
+	if (vips_fitsload(file, &image, NULL)) {
+		return NULL;
+	}
+
+	// read it all now, the file goes away after loading
+	copy = vips_image_copy_memory(image);
+	g_object_unref(image);
+	return copy;
+}
+
+int
+vips_cast_0(VipsImage *in, VipsImage **out, VipsBandFormat format) {
+	return vips_cast(in, out, format, NULL);
+}
+
+VipsImage *
+vips_niftiload_memory(const char *file) {
+	VipsImage *image, *copy;
+	int height;
+
+	if (vips_niftiload(file, &image, NULL)) {
+		return NULL;
+	}
+
+	copy = vips_image_copy_memory(image);
+	g_object_unref(image);
+	if (!copy) {
+		return NULL;
+	}
+
+	// slices are stacked page-height apart, count them like pages
+	if (
+		vips_image_get_typeof(copy, "page-height") &&
+		!vips_image_get_int(copy, "page-height", &height) &&
+		height > 0
+	) {
+		vips_image_set_int(copy, "n-pages", copy->Ysize / height);
+	}
+	return copy;
+}
+
+int
+vips_fitssave_bridge(VipsImage *in, const char *file) {
+	return vips_fitssave(in, file, NULL);
+}
+
+VipsImage *
+vips_matrixload_memory(const char *file, int csv) {
+	VipsImage *image, *copy;
+
+	if (csv ? vips_csvload(file, &image, NULL) : vips_matrixload(file, &image, NULL)) {
+		return NULL;
+	}
+
+	copy = vips_image_copy_memory(image);
+	g_object_unref(image);
+	return copy;
+}
+
+int
+vips_matrixsave_bridge(VipsImage *in, const char *file, int csv) {
+	return csv ? vips_csvsave(in, file, NULL) : vips_matrixsave(in, file, NULL);
+}
+
+int
+vips_matrixsave_array(double *values, int width, int height, double scale, double offset, const char *file, int csv) {
+	VipsImage *matrix;
+	int result;
+
+	if (!(matrix = vips_image_new_matrix_from_array(width, height, values, width * height))) {
+		return -1;
+	}
+	vips_image_set_double(matrix, "scale", scale);
+	vips_image_set_double(matrix, "offset", offset);
+
+	result = vips_matrixsave_bridge(matrix, file, csv);
+	g_object_unref(matrix);
+	return result;
+}
+
+double *
+vips_matrix_values(VipsImage *in, size_t *size) {
+	VipsImage *cast;
+	void *mem;
+
+	if (vips_cast(in, &cast, VIPS_FORMAT_DOUBLE, NULL)) {
+		return NULL;
+	}
+
+	mem = vips_image_write_to_memory(cast, size);
+	g_object_unref(cast);
+	return (double *) mem;
+}
+
+int
+vips_stretch(VipsImage *in, VipsImage **out, int curve) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 3);
+	double min, max, top;
+	int result;
+
+	if (vips_min(in, &min, NULL) || vips_max(in, &max, NULL)) {
+		g_object_unref(base);
+		return -1;
+	}
+	if (max <= min) {
+		max = min + 1;
+	}
+
+	// shift the data to start at 0, or 1 for the log, curve it, and
+	// scale what is left to 0-255
+	switch (curve) {
+	case 1:
+		top = log(max - min + 1);
+		if (
+			vips_linear1(in, &t[0], 1, 1 - min, NULL) ||
+			vips_log(t[0], &t[1], NULL)
+		) {
+			g_object_unref(base);
+			return -1;
+		}
+		break;
+	case 2:
+		top = sqrt(max - min);
+		if (
+			vips_linear1(in, &t[0], 1, -min, NULL) ||
+			vips_pow_const1(t[0], &t[1], 0.5, NULL)
+		) {
+			g_object_unref(base);
+			return -1;
+		}
+		break;
+	default:
+		top = max - min;
+		if (vips_linear1(in, &t[1], 1, -min, NULL)) {
+			g_object_unref(base);
+			return -1;
+		}
+	}
+
+	if (vips_linear1(t[1], &t[2], 255 / top, 0, NULL)) {
+		g_object_unref(base);
+		return -1;
+	}
+
+	result = vips_cast(t[2], out, VIPS_FORMAT_UCHAR, NULL);
+	g_object_unref(base);
+	return result;
+}
+
+// vips_watermark_means averages the lightness of in, alpha aside, over a
+// grid x grid raster of chips into means, on the 0-255 scale.
+int
+vips_watermark_means(VipsImage *in, int grid, double *means) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 4);
+	VipsImage *colour, *alpha;
+	void *mem;
+	size_t size;
+
+	if (
+		vips_split_alpha(VIPS_OBJECT(base), in, &colour, &alpha) ||
+		vips_colourspace(colour, &t[0], VIPS_INTERPRETATION_B_W, NULL) ||
+		vips_cast(t[0], &t[1], VIPS_FORMAT_DOUBLE, NULL) ||
+		vips_resize(t[1], &t[2], (double) grid / in->Xsize, "vscale", (double) grid / in->Ysize, NULL) ||
+		vips_linear1(t[2], &t[3], 255 / vips_interpretation_max_alpha(t[0]->Type), 0, NULL)
+	) {
+		g_object_unref(base);
+		return -1;
+	}
+
+	if (t[3]->Xsize != grid || t[3]->Ysize != grid) {
+		vips_error("vips_watermark_means", "resized to %dx%d", t[3]->Xsize, t[3]->Ysize);
+		g_object_unref(base);
+		return -1;
+	}
+
+	if (!(mem = vips_image_write_to_memory(t[3], &size))) {
+		g_object_unref(base);
+		return -1;
+	}
+
+	memcpy(means, mem, VIPS_MIN(size, grid * grid * sizeof(double)));
+	g_free(mem);
+	g_object_unref(base);
+	return 0;
+}
+
+// vips_watermark_add adds the grid x grid chips of pattern, given on the
+// 0-255 scale, to every colour band of in, stretched over the image.
+int
+vips_watermark_add(VipsImage *in, double *pattern, int grid, VipsImage **out) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 7);
+	VipsImage *colour, *alpha;
+	int result;
+
+	if (
+		vips_split_alpha(VIPS_OBJECT(base), in, &colour, &alpha) ||
+		!(t[0] = vips_image_new_from_memory_copy(pattern, grid * grid * sizeof(double), grid, grid, 1, VIPS_FORMAT_DOUBLE)) ||
+		vips_resize(t[0], &t[1], (double) in->Xsize / grid, "vscale", (double) in->Ysize / grid, "kernel", VIPS_KERNEL_NEAREST, NULL) ||
+		vips_embed(t[1], &t[2], 0, 0, in->Xsize, in->Ysize, "extend", VIPS_EXTEND_COPY, NULL) ||
+		vips_linear1(t[2], &t[3], vips_interpretation_max_alpha(colour->Type) / 255, 0, NULL) ||
+		vips_add(colour, t[3], &t[4], NULL) ||
+		vips_round(t[4], &t[5], VIPS_OPERATION_ROUND_RINT, NULL) ||
+		vips_cast(t[5], &t[6], colour->BandFmt, NULL)
+	) {
+		g_object_unref(base);
+		return -1;
+	}
+
+	result = vips_join_alpha(t[6], alpha, out);
+	g_object_unref(base);
+	return result;
+}
+
+// vips_grain adds the size x size tile of noise, given on the 0-255 scale,
+// to every colour band of in, repeated across the image.
+int
+vips_grain(VipsImage *in, double *noise, int size, VipsImage **out) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 7);
+	VipsImage *colour, *alpha;
+	int result;
+
+	if (
+		vips_split_alpha(VIPS_OBJECT(base), in, &colour, &alpha) ||
+		!(t[0] = vips_image_new_from_memory_copy(noise, size * size * sizeof(double), size, size, 1, VIPS_FORMAT_DOUBLE)) ||
+		vips_replicate(t[0], &t[1], VIPS_ROUND_UP(in->Xsize, size) / size, VIPS_ROUND_UP(in->Ysize, size) / size, NULL) ||
+		vips_extract_area(t[1], &t[2], 0, 0, in->Xsize, in->Ysize, NULL) ||
+		vips_linear1(t[2], &t[3], vips_interpretation_max_alpha(colour->Type) / 255, 0, NULL) ||
+		vips_add(colour, t[3], &t[4], NULL) ||
+		vips_round(t[4], &t[5], VIPS_OPERATION_ROUND_RINT, NULL) ||
+		vips_cast(t[5], &t[6], colour->BandFmt, NULL)
+	) {
+		g_object_unref(base);
+		return -1;
+	}
+
+	result = vips_join_alpha(t[6], alpha, out);
+	g_object_unref(base);
+	return result;
+}
+
+// vips_set_orientation is a copy of in with the orientation savers write
+// to EXIF set to orientation.
+int
+vips_set_orientation(VipsImage *in, VipsImage **out, int orientation) {
+	if (vips_copy(in, out, NULL)) {
+		return -1;
+	}
+	vips_image_set_int(*out, VIPS_META_ORIENTATION, orientation);
+	return 0;
+}
+
+// vips_sample_pixels writes every pixel of a grid of at most size x size
+// over in, as uchar, to memory the caller frees.
+void *
+vips_sample_pixels(VipsImage *in, int size, size_t *len) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 2);
+	void *mem;
+
+	if (
+		vips_subsample(in, &t[0], VIPS_ROUND_UP(in->Xsize, size) / size, VIPS_ROUND_UP(in->Ysize, size) / size, NULL) ||
+		vips_cast(t[0], &t[1], VIPS_FORMAT_UCHAR, "shift", in->BandFmt == VIPS_FORMAT_USHORT, NULL) ||
+		!(mem = vips_image_write_to_memory(t[1], len))
+	) {
+		g_object_unref(base);
+		return NULL;
+	}
+
+	g_object_unref(base);
+	return mem;
+}
+
+// vips_grey_pixels writes the grey of in, squashed to width x height, as
+// uchar to memory the caller frees. Alpha is dropped.
+void *
+vips_grey_pixels(VipsImage *in, int width, int height, size_t *len) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 4);
+	void *mem;
+
+	if (
+		vips_colourspace(in, &t[0], VIPS_INTERPRETATION_B_W, NULL) ||
+		vips_extract_band(t[0], &t[1], 0, NULL) ||
+		vips_resize(t[1], &t[2], (double) width / in->Xsize, "vscale", (double) height / in->Ysize, NULL) ||
+		vips_cast(t[2], &t[3], VIPS_FORMAT_UCHAR, "shift", t[2]->BandFmt == VIPS_FORMAT_USHORT, NULL) ||
+		!(mem = vips_image_write_to_memory(t[3], len))
+	) {
+		g_object_unref(base);
+		return NULL;
+	}
+
+	g_object_unref(base);
+	return mem;
+}
+
+// vips_from_pixels makes an image of a copy of the width x height pixels
+// of bands samples of format at data, as interpretation.
+int
+vips_from_pixels(const void *data, size_t size, int width, int height, int bands, VipsBandFormat format, VipsInterpretation interpretation, VipsImage **out) {
+	VipsImage *memory;
+	int result;
+
+	if (!(memory = vips_image_new_from_memory_copy(data, size, width, height, bands, format))) {
+		return -1;
+	}
+
+	result = vips_copy(memory, out, "interpretation", interpretation, NULL);
+	g_object_unref(memory);
+	return result;
+}
+
+// vips_rgba is in as 8-bit sRGB with alpha, opaque where in has none.
+int
+vips_rgba(VipsImage *in, VipsImage **out) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 2);
+	int result;
+
+	if (
+		vips_colourspace(in, &t[0], VIPS_INTERPRETATION_sRGB, NULL) ||
+		vips_cast(t[0], &t[1], VIPS_FORMAT_UCHAR, NULL)
+	) {
+		g_object_unref(base);
+		return -1;
+	}
+
+	if (t[1]->Bands >= 4) {
+		result = vips_extract_band(t[1], out, 0, "n", 4, NULL);
+	} else {
+		result = vips_bandjoin_const1(t[1], out, 255, NULL);
+	}
+	g_object_unref(base);
+	return result;
+}
+
+int
+vips_stats_0(VipsImage *in, VipsImage **out) {
+	return vips_stats(in, out, NULL);
+}
+
+// vips_histogram counts the samples of each band of in, cast to uchar with
+// 16 bit samples shifted down, in 256 bins.
+int
+vips_histogram(VipsImage *in, VipsImage **out) {
+	VipsImage *cast;
+	int result;
+
+	if (vips_cast(in, &cast, VIPS_FORMAT_UCHAR, "shift", in->BandFmt == VIPS_FORMAT_USHORT, NULL)) {
+		return -1;
+	}
+
+	result = vips_hist_find(cast, out, NULL);
+	g_object_unref(cast);
+	return result;
+}
+
+// vips_canvas is a width x height sRGB image with alpha, all of colour
+// r, g, b, a.
+int
+vips_canvas(VipsImage **out, int width, int height, double r, double g, double b, double a) {
+	VipsImage *base = vips_image_new();
+	VipsImage **t = (VipsImage **) vips_object_local_array(VIPS_OBJECT(base), 2);
+	double zero[4] = {0, 0, 0, 0};
+	double ink[4] = {r, g, b, a};
+
+	if (
+		vips_black(&t[0], width, height, "bands", 4, NULL) ||
+		vips_linear(t[0], &t[1], zero, ink, 4, "uchar", TRUE, NULL) ||
+		vips_copy(t[1], out, "interpretation", VIPS_INTERPRETATION_sRGB, NULL)
+	) {
+		g_object_unref(base);
+		return -1;
+	}
+
+	g_object_unref(base);
+	return 0;
+}
+
+// vips_composite_at draws overlay over base with its top left corner at
+// x, y, blending by the alpha of overlay.
+int
+vips_composite_at(VipsImage *base, VipsImage *overlay, VipsImage **out, int x, int y) {
+	return vips_composite2(base, overlay, out, VIPS_BLEND_MODE_OVER, "x", x, "y", y, NULL);
+}
@@ -2,10 +2,15 @@ package vips
 
 import (
 	"bytes"
+	"errors"
 	"image"
+	"image/color"
+	"image/draw"
 	"image/jpeg"
+	"image/png"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -122,3 +127,264 @@ func TestResize(t *testing.T) {
 		}
 	}
 }
+
+func TestMemoryStats(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Resize(buf, Options{Width: 100, Height: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := MemoryStats()
+	if stats.Mem < 0 || stats.MemHighwater < stats.Mem || stats.MemHighwater == 0 {
+		t.Errorf("MemoryStats() => %#v", stats)
+	}
+}
+
+func TestTryInitialize(t *testing.T) {
+	// started on import, so this leaves libvips running as it is
+	if err := TryInitialize(); err != nil {
+		t.Fatalf("TryInitialize() = %v", err)
+	}
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Resize(buf, Options{Width: 100}); err != nil {
+		t.Errorf("Resize() after TryInitialize() = %v", err)
+	}
+}
+
+func TestResizeFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vips")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out.png")
+	if err := ResizeFile("testdata/1.jpg", out, Options{Width: 100, Height: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	config, err := png.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Width != 100 && config.Height != 100 {
+		t.Errorf("ResizeFile() => %dx%d, want one side of 100", config.Width, config.Height)
+	}
+}
+
+func TestExtractArea(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 50, 40))
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := ExtractArea(buf.Bytes(), 10, 5, 20, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := png.DecodeConfig(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Width != 20 || config.Height != 30 {
+		t.Errorf("ExtractArea() => %dx%d, want 20x30", config.Width, config.Height)
+	}
+
+	if _, err := ExtractArea(buf.Bytes(), 40, 0, 20, 30); err == nil {
+		t.Errorf("ExtractArea() outside the image did not fail")
+	}
+}
+
+func TestTrim(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 80))
+	draw.Draw(img, img.Bounds(), image.White, image.ZP, draw.Src)
+	draw.Draw(img, image.Rect(30, 40, 50, 50), image.Black, image.ZP, draw.Src)
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Resize(buf.Bytes(), Options{Trim: true, Savetype: PNG})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := png.DecodeConfig(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Width != 20 || config.Height != 10 {
+		t.Errorf("Resize(Trim) => %dx%d, want 20x10", config.Width, config.Height)
+	}
+}
+
+func TestEmbedBackground(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	draw.Draw(img, img.Bounds(), image.White, image.ZP, draw.Src)
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Resize(buf.Bytes(), Options{
+		Width:      100,
+		Height:     100,
+		Embed:      true,
+		Extend:     EXTEND_BACKGROUND,
+		Background: color.RGBA{0xff, 0, 0, 0},
+		Savetype:   PNG,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outImg, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outImg.Bounds().Dx() != 100 || outImg.Bounds().Dy() != 100 {
+		t.Fatalf("Resize(EXTEND_BACKGROUND) => %v, want 100x100", outImg.Bounds())
+	}
+	if _, _, _, a := outImg.At(50, 5).RGBA(); a != 0 {
+		t.Errorf("Resize(EXTEND_BACKGROUND) padding alpha => %d, want 0", a)
+	}
+	if _, _, _, a := outImg.At(50, 50).RGBA(); a != 0xffff {
+		t.Errorf("Resize(EXTEND_BACKGROUND) image alpha => %d, want opaque", a)
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Resize(buf.Bytes(), Options{Flatten: true, Quality: 90})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outImg, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, b, _ := outImg.At(5, 5).RGBA(); r < 0xf000 || g < 0xf000 || b < 0xf000 {
+		t.Errorf("Resize(Flatten) => %v, want white", outImg.At(5, 5))
+	}
+}
+
+func TestColourspace(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Resize(buf, Options{Width: 100, Colourspace: COLOURSPACE_B_W})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := img.(*image.Gray); !ok {
+		t.Errorf("Resize(COLOURSPACE_B_W) => %T, want *image.Gray", img)
+	}
+}
+
+func TestSaveOptions(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a progressive JPEG starts its frame with SOF2
+	out, err := Resize(buf, Options{Width: 100, JPEG: JPEGOptions{Quality: 80, Interlace: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out, []byte{0xff, 0xc2}) {
+		t.Errorf("Resize(JPEG.Interlace) did not write a progressive JPEG")
+	}
+
+	low, err := Resize(buf, Options{Width: 100, Savetype: WEBP, Quality: 90, WebP: WebPOptions{Quality: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	high, err := Resize(buf, Options{Width: 100, Savetype: WEBP, Quality: 90})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(low) >= len(high) {
+		t.Errorf("WebP.Quality 10 => %d bytes, not smaller than Quality 90 => %d bytes", len(low), len(high))
+	}
+}
+
+func TestResizeWithInfo(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		savetype ImageType
+		channels int
+	}{
+		{PNG, 4},
+		{JPEG, 3},
+	}
+	for _, c := range cases {
+		r, err := ResizeWithInfo(buf.Bytes(), Options{Width: 20, Savetype: c.savetype})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Width != 20 || r.Height != 10 || r.Channels != c.channels || r.Format != c.savetype || r.Size != len(r.Buf) {
+			t.Errorf("ResizeWithInfo(%v) => %dx%d, %d channels, %v, %d bytes", c.savetype, r.Width, r.Height, r.Channels, r.Format, r.Size)
+		}
+		if detectType(r.Buf) != c.savetype {
+			t.Errorf("ResizeWithInfo(%v) wrote %v", c.savetype, detectType(r.Buf))
+		}
+	}
+}
+
+func TestResizeDamaged(t *testing.T) {
+	for _, buf := range [][]byte{nil, {}, {0xff}, {0x89, 'P'}, []byte("RIFF")} {
+		if _, err := Resize(buf, Options{Width: 10}); err == nil {
+			t.Errorf("Resize(%q) did not fail", buf)
+		}
+	}
+	if _, err := Resize(nil, Options{}); !errors.Is(err, ErrEmptyBuffer) {
+		t.Errorf("Resize(nil) = %v, want %v", err, ErrEmptyBuffer)
+	}
+
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := buf[:len(buf)/2]
+
+	if _, err := Resize(truncated, Options{Width: 100}); err != nil {
+		t.Errorf("Resize() of a truncated JPEG = %v, want it decoded as far as it goes", err)
+	}
+	if _, err := Resize(truncated, Options{Width: 100, FailOnError: true}); err == nil {
+		t.Errorf("Resize(FailOnError) of a truncated JPEG did not fail")
+	}
+}
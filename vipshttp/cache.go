@@ -0,0 +1,117 @@
+package vipshttp
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache keeps transform results in memory with stale-while-revalidate
+// semantics: entries are served as-is for TTL, then served stale for up to
+// StaleTTL more while a single background call refreshes them, so expiry
+// never puts a slow pipeline on the request path. The zero value caches
+// nothing; set TTL to enable it.
+type Cache struct {
+	TTL      time.Duration
+	StaleTTL time.Duration
+	// MaxEntries bounds the cache, 1000 by default. When full, the entry
+	// closest to expiry is evicted.
+	MaxEntries int
+
+	mu         sync.Mutex
+	entries    map[string]*cacheEntry
+	refreshing map[string]bool
+	now        func() time.Time
+}
+
+type cacheEntry struct {
+	buf     []byte
+	expires time.Time
+}
+
+// Get returns the cached result for key, calling fn to produce it when it
+// is missing or too stale to serve. Returned buffers are shared and must
+// not be modified.
+func (c *Cache) Get(key string, fn func() ([]byte, error)) ([]byte, error) {
+	if c.TTL <= 0 {
+		return fn()
+	}
+
+	c.mu.Lock()
+	now := c.clock()
+	if e, ok := c.entries[key]; ok {
+		switch {
+		case now.Before(e.expires):
+			c.mu.Unlock()
+			return e.buf, nil
+		case now.Before(e.expires.Add(c.StaleTTL)):
+			if !c.refreshing[key] {
+				if c.refreshing == nil {
+					c.refreshing = make(map[string]bool)
+				}
+				c.refreshing[key] = true
+				go c.refresh(key, fn)
+			}
+			c.mu.Unlock()
+			return e.buf, nil
+		}
+	}
+	c.mu.Unlock()
+
+	buf, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	c.put(key, buf)
+	return buf, nil
+}
+
+// refresh recomputes key in the background. On failure the stale entry is
+// kept until it runs out.
+func (c *Cache) refresh(key string, fn func() ([]byte, error)) {
+	buf, err := fn()
+	if err == nil {
+		c.put(key, buf)
+	}
+
+	c.mu.Lock()
+	delete(c.refreshing, key)
+	c.mu.Unlock()
+}
+
+func (c *Cache) put(key string, buf []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]*cacheEntry)
+	}
+
+	max := c.MaxEntries
+	if max <= 0 {
+		max = 1000
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= max {
+		c.evict()
+	}
+
+	c.entries[key] = &cacheEntry{buf: buf, expires: c.clock().Add(c.TTL)}
+}
+
+// evict drops the entry closest to expiry. Callers hold mu.
+func (c *Cache) evict() {
+	var oldest string
+	var expires time.Time
+	for key, e := range c.entries {
+		if oldest == "" || e.expires.Before(expires) {
+			oldest, expires = key, e.expires
+		}
+	}
+	delete(c.entries, oldest)
+}
+
+func (c *Cache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,94 @@
+package vipshttp
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	var mu sync.Mutex
+	now := time.Unix(0, 0)
+	c := &Cache{TTL: time.Minute, StaleTTL: time.Minute, now: func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}}
+	advance := func(d time.Duration) {
+		mu.Lock()
+		now = now.Add(d)
+		mu.Unlock()
+	}
+
+	version := 0
+	refreshed := make(chan struct{}, 1)
+	fn := func() ([]byte, error) {
+		version++
+		if version > 1 {
+			defer func() { refreshed <- struct{}{} }()
+		}
+		return []byte{byte(version)}, nil
+	}
+
+	get := func() byte {
+		buf, err := c.Get("k", fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buf[0]
+	}
+
+	if v := get(); v != 1 {
+		t.Fatalf("first Get() => %d, want 1", v)
+	}
+	advance(30 * time.Second)
+	if v := get(); v != 1 {
+		t.Fatalf("fresh Get() => %d, want 1", v)
+	}
+
+	// stale: served immediately, refreshed in the background
+	advance(time.Minute)
+	if v := get(); v != 1 {
+		t.Fatalf("stale Get() => %d, want 1", v)
+	}
+	<-refreshed
+	for i := 0; i < 100; i++ {
+		c.mu.Lock()
+		busy := c.refreshing["k"]
+		c.mu.Unlock()
+		if !busy {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if v := get(); v != 2 {
+		t.Fatalf("refreshed Get() => %d, want 2", v)
+	}
+
+	// past the stale window: computed synchronously
+	advance(3 * time.Minute)
+	if v := get(); v != 3 {
+		t.Fatalf("expired Get() => %d, want 3", v)
+	}
+	<-refreshed
+}
+
+func TestCacheErrorsAndEviction(t *testing.T) {
+	c := &Cache{TTL: time.Minute, MaxEntries: 2}
+
+	if _, err := c.Get("err", func() ([]byte, error) { return nil, errors.New("boom") }); err == nil {
+		t.Errorf("Get() did not return the error of fn")
+	}
+
+	for _, key := range []string{"a", "b", "c"} {
+		c.Get(key, func() ([]byte, error) { return []byte(key), nil })
+		time.Sleep(time.Millisecond)
+	}
+	if len(c.entries) != 2 {
+		t.Errorf("cache holds %d entries, want 2", len(c.entries))
+	}
+	if _, ok := c.entries["a"]; ok {
+		t.Errorf("oldest entry was not evicted")
+	}
+}
@@ -0,0 +1,86 @@
+package vipshttp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/daddye/vips"
+)
+
+// Coalescer collapses identical in-flight transforms, so a burst of
+// requests for the same thumbnail runs a single vips pipeline and every
+// caller gets its result. The zero value is ready to use.
+type Coalescer struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	wg  sync.WaitGroup
+	buf []byte
+	err error
+	// waiters counts the callers waiting on the call, under Coalescer.mu.
+	waiters int
+}
+
+// Do runs fn unless a call with the same key is already running, in which
+// case it waits for and returns that call's result instead. shared reports
+// whether the result went to more than one caller; shared buffers must not
+// be modified. If fn panics, the panic goes on in the caller running it and
+// the others get an error.
+func (c *Coalescer) Do(key string, fn func() ([]byte, error)) (buf []byte, err error, shared bool) {
+	c.mu.Lock()
+	if c.calls == nil {
+		c.calls = make(map[string]*call)
+	}
+	if running, ok := c.calls[key]; ok {
+		running.waiters++
+		c.mu.Unlock()
+		running.wg.Wait()
+		return running.buf, running.err, true
+	}
+	cl := new(call)
+	cl.wg.Add(1)
+	c.calls[key] = cl
+	c.mu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			cl.buf, cl.err = nil, fmt.Errorf("vipshttp: coalesced call panicked: %v", r)
+			c.finish(key, cl)
+			panic(r)
+		}
+	}()
+
+	cl.buf, cl.err = fn()
+	return cl.buf, cl.err, c.finish(key, cl)
+}
+
+// finish releases the callers waiting on cl, reporting whether there were
+// any.
+func (c *Coalescer) finish(key string, cl *call) bool {
+	c.mu.Lock()
+	delete(c.calls, key)
+	shared := cl.waiters > 0
+	c.mu.Unlock()
+	cl.wg.Done()
+	return shared
+}
+
+// Resize is vips.Resize with identical concurrent calls coalesced by Key.
+func (c *Coalescer) Resize(buf []byte, o vips.Options) ([]byte, error) {
+	out, err, _ := c.Do(Key(buf, o), func() ([]byte, error) {
+		return vips.Resize(buf, o)
+	})
+	return out, err
+}
+
+// Key identifies transforming buf with o by the content hash of buf and the
+// Fingerprint of o. Options with a CropRegion get a new Key every time, so
+// they are never coalesced or served from a Cache.
+func Key(buf []byte, o vips.Options) string {
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:]) + "-" + o.Fingerprint()
+}
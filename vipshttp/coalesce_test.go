@@ -0,0 +1,110 @@
+package vipshttp
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daddye/vips"
+)
+
+func TestCoalescerDo(t *testing.T) {
+	var c Coalescer
+	var runs, shared int32
+	start := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			buf, err, s := c.Do("key", func() ([]byte, error) {
+				atomic.AddInt32(&runs, 1)
+				time.Sleep(50 * time.Millisecond)
+				return []byte("done"), nil
+			})
+			if err != nil || string(buf) != "done" {
+				t.Errorf("Do() => %q, %v", buf, err)
+			}
+			if s {
+				atomic.AddInt32(&shared, 1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if runs != 1 {
+		t.Errorf("Do() ran %d times, want 1", runs)
+	}
+	// the caller running fn shares its buffer too
+	if shared != 10 {
+		t.Errorf("Do() shared %d results, want 10", shared)
+	}
+
+	_, _, s := c.Do("key", func() ([]byte, error) {
+		atomic.AddInt32(&runs, 1)
+		return nil, nil
+	})
+	if runs != 2 || s {
+		t.Errorf("Do() after completion ran %d times, shared %v, want 2 and unshared", runs, s)
+	}
+}
+
+func TestCoalescerDoPanic(t *testing.T) {
+	var c Coalescer
+	running := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("Do() recovered %v, want the panic of fn", r)
+			}
+			close(done)
+		}()
+		c.Do("key", func() ([]byte, error) {
+			close(running)
+			<-release
+			panic("boom")
+		})
+	}()
+
+	<-running
+	follower := make(chan error)
+	go func() {
+		_, err, _ := c.Do("key", func() ([]byte, error) { return nil, nil })
+		follower <- err
+	}()
+	// let the follower join the running call
+	for {
+		c.mu.Lock()
+		waiters := c.calls["key"].waiters
+		c.mu.Unlock()
+		if waiters > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	if err := <-follower; err == nil {
+		t.Errorf("Do() => nil error for a call that panicked")
+	}
+	<-done
+}
+
+func TestKey(t *testing.T) {
+	buf := []byte("image")
+	a := Key(buf, vips.Options{Width: 100, Caption: &vips.Caption{Location: "Lisbon"}})
+	b := Key(buf, vips.Options{Width: 100, Caption: &vips.Caption{Location: "Lisbon"}})
+	if a != b {
+		t.Errorf("Key() differs for equal options: %s != %s", a, b)
+	}
+	if a == Key(buf, vips.Options{Width: 200}) || a == Key([]byte("other"), vips.Options{Width: 100}) {
+		t.Errorf("Key() collides for different transforms")
+	}
+}
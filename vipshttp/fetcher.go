@@ -0,0 +1,271 @@
+// Package vipshttp serves images transformed by the vips package over
+// HTTP.
+package vipshttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrHostNotAllowed = errors.New("vipshttp: origin host not allowed")
+	ErrRateLimited    = errors.New("vipshttp: origin rate limit exceeded")
+	ErrTooLarge       = errors.New("vipshttp: origin response too large")
+	ErrBadContentType = errors.New("vipshttp: origin content type not allowed")
+	ErrUnsupportedURL = errors.New("vipshttp: only http and https origins are supported")
+	ErrPrivateAddress = errors.New("vipshttp: origin address is private")
+)
+
+// maxRedirects is how many redirects Fetch follows, as net/http does.
+const maxRedirects = 10
+
+// Fetcher downloads source images from origin servers. It bounds how long
+// and how much it reads, checks what it receives, and rate limits each
+// origin host, redirects included, so a resizing endpoint can be neither
+// used as an open proxy nor stalled by slow origins. The zero value is
+// usable with the defaults documented on each field.
+type Fetcher struct {
+	// Client performs the requests. By default it is one that refuses to
+	// connect to loopback, private and link-local addresses, such as cloud
+	// metadata endpoints, checking the address dialled so DNS rebinding
+	// can't get around it. A Client given here dials as it is set up to,
+	// but its redirects are checked all the same.
+	Client *http.Client
+	// AllowPrivate lets the default Client connect to private addresses,
+	// for origins on the local network.
+	AllowPrivate bool
+	// Timeout bounds a whole fetch including reading the body, 10 seconds
+	// by default.
+	Timeout time.Duration
+	// MaxBodySize is the largest accepted response, 20Mb by default.
+	MaxBodySize int64
+	// AllowedHosts restricts fetching to these hosts. Empty allows any.
+	AllowedHosts []string
+	// AllowedTypes lists accepted media types or type prefixes ending in
+	// "/", "image/" by default.
+	AllowedTypes []string
+	// RequestsPerSecond per origin host with bursts of up to Burst
+	// requests. Zero disables rate limiting.
+	RequestsPerSecond float64
+	Burst             int
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	pruned  time.Time
+	now     func() time.Time
+
+	once   sync.Once
+	client *http.Client
+}
+
+// bucket is a token bucket for one origin host.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Fetch downloads rawurl and returns its body.
+func (f *Fetcher) Fetch(ctx context.Context, rawurl string) ([]byte, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.check(u); err != nil {
+		return nil, err
+	}
+
+	timeout := f.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	// every hop is checked as the first was
+	client := *f.httpClient()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("vipshttp: stopped after %d redirects", maxRedirects)
+		}
+		return f.check(req.URL)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vipshttp: origin returned %s", resp.Status)
+	}
+	if !f.typeAllowed(resp.Header.Get("Content-Type")) {
+		return nil, ErrBadContentType
+	}
+
+	max := f.MaxBodySize
+	if max == 0 {
+		max = 20 << 20
+	}
+	if resp.ContentLength > max {
+		return nil, ErrTooLarge
+	}
+
+	buf, err := ioutil.ReadAll(io.LimitReader(resp.Body, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(buf)) > max {
+		return nil, ErrTooLarge
+	}
+	return buf, nil
+}
+
+// check refuses to fetch u from hosts not allowed or over their rate.
+func (f *Fetcher) check(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrUnsupportedURL
+	}
+	host := strings.ToLower(u.Hostname())
+	if !f.hostAllowed(host) {
+		return ErrHostNotAllowed
+	}
+	if !f.allow(host) {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// httpClient is Client, or the default one refusing private addresses
+// unless AllowPrivate.
+func (f *Fetcher) httpClient() *http.Client {
+	if f.Client != nil {
+		return f.Client
+	}
+	f.once.Do(func() {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		if !f.AllowPrivate {
+			dialer.Control = refusePrivate
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		transport.DialContext = dialer.DialContext
+		f.client = &http.Client{Transport: transport}
+	})
+	return f.client
+}
+
+// cgnat is the shared address space of carrier-grade NAT, RFC 6598.
+var cgnat = &net.IPNet{IP: net.IP{100, 64, 0, 0}, Mask: net.CIDRMask(10, 32)}
+
+// refusePrivate is a net.Dialer Control refusing addresses that aren't
+// public, once the host name is resolved.
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		cgnat.Contains(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+	return nil
+}
+
+func (f *Fetcher) hostAllowed(host string) bool {
+	if len(f.AllowedHosts) == 0 {
+		return true
+	}
+	for _, h := range f.AllowedHosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Fetcher) typeAllowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	allowed := f.AllowedTypes
+	if len(allowed) == 0 {
+		allowed = []string{"image/"}
+	}
+	for _, t := range allowed {
+		if mediaType == t || strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// allow takes a token from the bucket of host.
+func (f *Fetcher) allow(host string) bool {
+	if f.RequestsPerSecond <= 0 {
+		return true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	if f.now != nil {
+		now = f.now()
+	}
+
+	burst := float64(f.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	if f.buckets == nil {
+		f.buckets = make(map[string]*bucket)
+	}
+	// buckets idle long enough to fill up again are as good as new ones
+	if now.Sub(f.pruned) >= time.Minute {
+		for host, b := range f.buckets {
+			if now.Sub(b.last).Seconds()*f.RequestsPerSecond >= burst {
+				delete(f.buckets, host)
+			}
+		}
+		f.pruned = now
+	}
+	b, ok := f.buckets[host]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		f.buckets[host] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * f.RequestsPerSecond
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,156 @@
+package vipshttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image":
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Write([]byte("0123456789"))
+		case "/redirect":
+			_, port, _ := net.SplitHostPort(r.Host)
+			http.Redirect(w, r, "http://localhost:"+port+"/image", http.StatusFound)
+		case "/html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+
+	var testCases = []struct {
+		fetcher *Fetcher
+		path    string
+		err     string
+	}{
+		{&Fetcher{AllowPrivate: true}, "/image", ""},
+		{&Fetcher{AllowPrivate: true}, "/html", ErrBadContentType.Error()},
+		{&Fetcher{AllowPrivate: true}, "/missing", "404"},
+		{&Fetcher{AllowPrivate: true, MaxBodySize: 5}, "/image", ErrTooLarge.Error()},
+		{&Fetcher{AllowPrivate: true, AllowedHosts: []string{"example.com"}}, "/image", ErrHostNotAllowed.Error()},
+		{&Fetcher{AllowPrivate: true, AllowedHosts: []string{u.Hostname()}}, "/image", ""},
+		{&Fetcher{AllowPrivate: true, AllowedTypes: []string{"image/png"}}, "/image", ErrBadContentType.Error()},
+		// the test server listens on loopback
+		{&Fetcher{}, "/image", ErrPrivateAddress.Error()},
+		// redirects are checked like the first request
+		{&Fetcher{AllowPrivate: true, AllowedHosts: []string{u.Hostname()}}, "/redirect", ErrHostNotAllowed.Error()},
+		{&Fetcher{AllowPrivate: true, AllowedHosts: []string{u.Hostname(), "localhost"}}, "/redirect", ""},
+	}
+
+	for index, tc := range testCases {
+		buf, err := tc.fetcher.Fetch(context.Background(), ts.URL+tc.path)
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("%d. Fetch(%s) error: %v", index, tc.path, err)
+		case tc.err == "" && string(buf) != "0123456789":
+			t.Errorf("%d. Fetch(%s) => %q", index, tc.path, buf)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("%d. Fetch(%s) error: %v, want %s", index, tc.path, err, tc.err)
+		}
+	}
+
+	if _, err := (&Fetcher{}).Fetch(context.Background(), "file:///etc/passwd"); err != ErrUnsupportedURL {
+		t.Errorf("Fetch(file://) error: %v, want %v", err, ErrUnsupportedURL)
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	f := &Fetcher{AllowPrivate: true, Timeout: 20 * time.Millisecond}
+	if _, err := f.Fetch(context.Background(), ts.URL); err == nil {
+		t.Errorf("Fetch() of a slow origin did not time out")
+	}
+}
+
+func TestFetcherRateLimit(t *testing.T) {
+	now := time.Unix(0, 0)
+	f := &Fetcher{RequestsPerSecond: 2, Burst: 2, now: func() time.Time { return now }}
+
+	var got []bool
+	for i := 0; i < 3; i++ {
+		got = append(got, f.allow("a"))
+	}
+	got = append(got, f.allow("b"))
+	now = now.Add(500 * time.Millisecond)
+	got = append(got, f.allow("a"), f.allow("a"))
+
+	want := []bool{true, true, false, true, true, false}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("allow() => %v, want %v", got, want)
+		}
+	}
+}
+
+func TestFetcherRedirectRateLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/again", http.StatusFound)
+	}))
+	defer ts.Close()
+
+	// the redirects use up the burst
+	f := &Fetcher{AllowPrivate: true, RequestsPerSecond: 0.001, Burst: 2}
+	if _, err := f.Fetch(context.Background(), ts.URL); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Fetch() through redirects => %v, want ErrRateLimited", err)
+	}
+}
+
+func TestFetcherPruneBuckets(t *testing.T) {
+	now := time.Unix(0, 0)
+	// buckets fill up in 40 seconds
+	f := &Fetcher{RequestsPerSecond: 0.05, Burst: 2, now: func() time.Time { return now }}
+
+	f.allow("a")
+	now = now.Add(30 * time.Second)
+	f.allow("b")
+	now = now.Add(31 * time.Second)
+	f.allow("c")
+	// a filled up again and went, b is still refilling
+	if _, ok := f.buckets["a"]; ok || len(f.buckets) != 2 {
+		t.Errorf("buckets after pruning: %v", f.buckets)
+	}
+}
+
+func TestRefusePrivate(t *testing.T) {
+	var testCases = []struct {
+		address string
+		ok      bool
+	}{
+		{"93.184.216.34:80", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", true},
+		{"127.0.0.1:80", false},
+		{"10.1.2.3:80", false},
+		{"192.168.0.1:80", false},
+		{"169.254.169.254:80", false},
+		{"100.64.0.1:80", false},
+		{"0.0.0.0:80", false},
+		{"[::1]:80", false},
+		{"[fe80::1]:80", false},
+		{"[fd00::1]:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+	}
+
+	for index, tc := range testCases {
+		if err := refusePrivate("tcp", tc.address, nil); (err == nil) != tc.ok {
+			t.Errorf("%d. refusePrivate(%s) => %v, want ok %v", index, tc.address, err, tc.ok)
+		}
+	}
+}
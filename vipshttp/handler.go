@@ -0,0 +1,278 @@
+package vipshttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/daddye/vips"
+)
+
+// Handler is an image proxy. It transforms the source named by the url
+// query parameter, or POSTed as the request body, as the other parameters
+// ask:
+//
+//	w, h     width and height in pixels, up to MaxSize
+//	fit      contain (the default) fits within w x h, cover crops to fill
+//	         it and pad embeds the image in it
+//	crop     centre, north, east, south or west; implies fit=cover
+//	format   jpeg, png, webp, another registered extension, or auto for
+//	         the best the Accept header and the tenant policy allow
+//	quality  1-100, or auto
+//
+// With a SigningKey, only parameters signed by Sign are accepted.
+//
+// The zero value fetches with a zero Fetcher, serves up to DefaultMaxSize
+// and applies no other policy.
+type Handler struct {
+	// Defaults are the options the parameters are applied to.
+	Defaults vips.Options
+	// Fetcher downloads sources named by url. Nil uses a zero Fetcher.
+	Fetcher *Fetcher
+	// Policies, when set, limits the transforms of the tenant whose API
+	// key is in the X-API-Key header.
+	Policies *Policies
+	// Cache and Coalescer, when set, keep and share results by Key.
+	Cache     *Cache
+	Coalescer *Coalescer
+	// MaxBodySize is the largest accepted POSTed source, 20Mb by default.
+	MaxBodySize int64
+	// MaxSize is the largest width and height served, DefaultMaxSize when
+	// zero, whatever the tenant, so no request can pad or enlarge into a
+	// canvas of any size.
+	MaxSize int
+	// SigningKey, when set, refuses requests whose parameters were not
+	// signed with it, so only transforms the application generated are
+	// served. See Sign.
+	SigningKey []byte
+}
+
+// DefaultMaxSize is the largest width and height a Handler serves unless
+// its MaxSize says otherwise.
+const DefaultMaxSize = 4096
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parse := ParseOptions
+	if h.SigningKey != nil {
+		parse = func(q url.Values, o vips.Options) (vips.Options, bool, error) {
+			return ParseSigned(h.SigningKey, q, o)
+		}
+	}
+	o, auto, err := parse(r.URL.Query(), h.Defaults)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err, http.StatusBadRequest))
+		return
+	}
+
+	// refuse what isn't allowed before fetching anything
+	var policy Policy
+	if h.Policies != nil {
+		policy = h.Policies.For(r.Header.Get("X-API-Key"))
+	}
+	if err := h.enforce(policy, &o, auto); err != nil {
+		http.Error(w, err.Error(), statusOf(err, http.StatusInternalServerError))
+		return
+	}
+
+	buf, err := h.source(r)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err, http.StatusBadGateway))
+		return
+	}
+
+	if auto {
+		m, err := vips.Size(buf)
+		if err != nil {
+			http.Error(w, err.Error(), statusOf(err, http.StatusInternalServerError))
+			return
+		}
+		o.Savetype = vips.BestSaveTypeOf(r.Header.Get("Accept"), m.HasAlpha, policy.AllowedFormats)
+		w.Header().Add("Vary", "Accept")
+		if err := policy.enforceFormat(&o); err != nil {
+			http.Error(w, err.Error(), statusOf(err, http.StatusInternalServerError))
+			return
+		}
+	}
+
+	out, err := h.transform(buf, o)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err, http.StatusInternalServerError))
+		return
+	}
+
+	// what was saved, which Savetype AUTO only decides on the pixels
+	w.Header().Set("Content-Type", vips.TypeOf(out).MIME())
+	w.Header().Set("Content-Length", strconv.Itoa(len(out)))
+	w.Write(out)
+}
+
+// enforce checks o against MaxSize and policy. With auto the format is
+// only known once the source is, and left to the caller to check.
+func (h *Handler) enforce(policy Policy, o *vips.Options, auto bool) error {
+	max := h.MaxSize
+	if max == 0 {
+		max = DefaultMaxSize
+	}
+	if o.Width > max || o.Height > max {
+		return &PolicyError{fmt.Sprintf("%dx%d is over %dx%d", o.Width, o.Height, max, max)}
+	}
+	// an unbounded side follows the aspect ratio of the source
+	if o.Enlarge && (o.Width == 0 || o.Height == 0) {
+		return &PolicyError{"enlarging needs both width and height"}
+	}
+
+	if err := policy.enforceLimits(o); err != nil {
+		return err
+	}
+	// Savetype AUTO picks among the allowed formats unless told otherwise
+	if o.Savetype == vips.AUTO && len(o.AutoFormats) == 0 {
+		o.AutoFormats = policy.AllowedFormats
+	}
+	if auto {
+		return nil
+	}
+	return policy.enforceFormat(o)
+}
+
+// source reads the POSTed body, or fetches the url parameter.
+func (h *Handler) source(r *http.Request) ([]byte, error) {
+	if r.Method == "POST" || r.Method == "PUT" {
+		max := h.MaxBodySize
+		if max == 0 {
+			max = 20 << 20
+		}
+		buf, err := ioutil.ReadAll(io.LimitReader(r.Body, max+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(buf)) > max {
+			return nil, ErrTooLarge
+		}
+		return buf, nil
+	}
+
+	rawurl := r.URL.Query().Get("url")
+	if rawurl == "" {
+		return nil, errMissingSource
+	}
+	f := h.Fetcher
+	if f == nil {
+		f = &Fetcher{}
+	}
+	return f.Fetch(r.Context(), rawurl)
+}
+
+// transform resizes buf through the cache and coalescer, when set.
+func (h *Handler) transform(buf []byte, o vips.Options) ([]byte, error) {
+	resize := func() ([]byte, error) {
+		if h.Coalescer != nil {
+			return h.Coalescer.Resize(buf, o)
+		}
+		return vips.Resize(buf, o)
+	}
+	if h.Cache != nil {
+		return h.Cache.Get(Key(buf, o), resize)
+	}
+	return resize()
+}
+
+var errMissingSource = errors.New("vipshttp: no url parameter or request body")
+
+var gravities = map[string]vips.Gravity{
+	"centre": vips.CENTRE,
+	"center": vips.CENTRE,
+	"north":  vips.NORTH,
+	"east":   vips.EAST,
+	"south":  vips.SOUTH,
+	"west":   vips.WEST,
+}
+
+// ParseOptions applies the transform parameters of q, as documented on
+// Handler, to o. auto reports format=auto, which leaves Savetype for the
+// caller to negotiate.
+func ParseOptions(q url.Values, o vips.Options) (_ vips.Options, auto bool, err error) {
+	for _, p := range []struct {
+		name string
+		to   *int
+	}{{"w", &o.Width}, {"h", &o.Height}} {
+		if v := q.Get(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return o, false, fmt.Errorf("vipshttp: bad %s %q", p.name, v)
+			}
+			*p.to = n
+		}
+	}
+
+	switch fit := q.Get("fit"); fit {
+	case "", "contain":
+	case "cover":
+		o.Crop = true
+	case "pad":
+		o.Embed = true
+	default:
+		return o, false, fmt.Errorf("vipshttp: bad fit %q", fit)
+	}
+
+	if v := q.Get("crop"); v != "" {
+		g, ok := gravities[strings.ToLower(v)]
+		if !ok {
+			return o, false, fmt.Errorf("vipshttp: bad crop %q", v)
+		}
+		o.Crop, o.Gravity = true, g
+	}
+
+	switch v := strings.ToLower(q.Get("format")); v {
+	case "":
+	case "auto":
+		auto = true
+	default:
+		if o.Savetype = vips.TypeOfExt(v); o.Savetype == vips.UNKNOWN {
+			return o, false, fmt.Errorf("vipshttp: bad format %q", v)
+		}
+	}
+
+	switch v := q.Get("quality"); v {
+	case "":
+	case "auto":
+		o.Quality = vips.QUALITY_AUTO
+	default:
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			return o, false, fmt.Errorf("vipshttp: bad quality %q", v)
+		}
+		o.Quality = n
+	}
+
+	return o, auto, o.Validate()
+}
+
+// statusOf maps the errors of fetching and transforming to a status code,
+// fallback for the unknown ones.
+func statusOf(err error, fallback int) int {
+	var policy *PolicyError
+	switch {
+	case errors.As(err, &policy), errors.Is(err, ErrHostNotAllowed), errors.Is(err, ErrPrivateAddress),
+		errors.Is(err, ErrBadSignature), errors.Is(err, ErrExpired):
+		return http.StatusForbidden
+	case errors.Is(err, vips.ErrTimeout):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrTooLarge), errors.Is(err, vips.ErrLimitExceeded):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, errMissingSource), errors.Is(err, ErrUnsupportedURL),
+		errors.Is(err, vips.ErrInvalidOption), errors.Is(err, vips.ErrInvalidDimensions),
+		errors.Is(err, vips.ErrUnsupportedSaveType):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrBadContentType), errors.Is(err, vips.ErrUnknownFormat),
+		errors.Is(err, vips.ErrEmptyBuffer):
+		return http.StatusUnsupportedMediaType
+	}
+	return fallback
+}
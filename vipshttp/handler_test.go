@@ -0,0 +1,123 @@
+package vipshttp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/daddye/vips"
+)
+
+func TestParseOptions(t *testing.T) {
+	q, _ := url.ParseQuery("w=200&h=100&crop=north&format=webp&quality=80")
+	o, auto, err := ParseOptions(q, vips.Options{Enlarge: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := vips.Options{Width: 200, Height: 100, Crop: true, Gravity: vips.NORTH, Savetype: vips.WEBP, Quality: 80, Enlarge: true}
+	if auto || o.Width != want.Width || o.Height != want.Height || o.Crop != want.Crop || o.Gravity != want.Gravity ||
+		o.Savetype != want.Savetype || o.Quality != want.Quality || !o.Enlarge {
+		t.Errorf("ParseOptions() = %+v, %v", o, auto)
+	}
+
+	q, _ = url.ParseQuery("fit=pad&format=auto&quality=auto")
+	if o, auto, err := ParseOptions(q, vips.Options{}); err != nil || !auto || !o.Embed || o.Quality != vips.QUALITY_AUTO {
+		t.Errorf("ParseOptions(auto) = %+v, %v, %v", o, auto, err)
+	}
+
+	for _, bad := range []string{"w=-1", "h=x", "fit=stretch", "crop=up", "format=doc", "quality=101"} {
+		q, _ := url.ParseQuery(bad)
+		if _, _, err := ParseOptions(q, vips.Options{}); err == nil {
+			t.Errorf("ParseOptions(%s) accepted", bad)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	src, err := ioutil.ReadFile("../testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(src)
+	}))
+	defer origin.Close()
+
+	h := &Handler{
+		// the origin listens on loopback
+		Fetcher: &Fetcher{AllowPrivate: true},
+		Policies: &Policies{Tenants: map[string]Policy{
+			"small":  {MaxWidth: 50},
+			"jpeg":   {AllowedFormats: []vips.ImageType{vips.JPEG}},
+			"capped": {MaxQuality: 80},
+		}},
+		Cache: &Cache{},
+	}
+	serve := func(method, query string, body []byte, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/?"+query, bytes.NewReader(body))
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	cases := []struct {
+		method, query string
+		body          []byte
+		header        http.Header
+		status        int
+		contentType   string
+	}{
+		{"GET", "w=100&url=" + url.QueryEscape(origin.URL), nil, nil, 200, "image/jpeg"},
+		{"POST", "w=100&format=png", src, nil, 200, "image/png"},
+		{"POST", "w=100&format=auto", src, http.Header{"Accept": {"image/webp,*/*"}}, 200, "image/webp"},
+		{"POST", "w=100", src, http.Header{"X-Api-Key": {"small"}}, 403, ""},
+		{"POST", "w=100&format=auto", src, http.Header{"Accept": {"image/webp,*/*"}, "X-Api-Key": {"jpeg"}}, 200, "image/jpeg"},
+		{"POST", "w=100&quality=auto", src, http.Header{"X-Api-Key": {"capped"}}, 403, ""},
+		{"POST", "w=100", []byte("not an image"), nil, 415, ""},
+		{"GET", "w=100", nil, nil, 400, ""},
+		{"GET", "w=100&url=ftp://example.com/a.jpg", nil, nil, 400, ""},
+		{"GET", "fit=squash", nil, nil, 400, ""},
+		{"POST", "w=60000&h=60000&fit=pad", src, nil, 403, ""},
+	}
+	for _, c := range cases {
+		w := serve(c.method, c.query, c.body, c.header)
+		if w.Code != c.status {
+			t.Errorf("%s ?%s = %d %s, want %d", c.method, c.query, w.Code, w.Body.String(), c.status)
+			continue
+		}
+		if c.status != 200 {
+			continue
+		}
+		if got := w.Header().Get("Content-Type"); got != c.contentType {
+			t.Errorf("%s ?%s Content-Type = %q, want %q", c.method, c.query, got, c.contentType)
+		}
+		m, err := vips.Size(w.Body.Bytes())
+		if err != nil || m.Width != 100 {
+			t.Errorf("%s ?%s => %d wide, %v", c.method, c.query, m.Width, err)
+		}
+	}
+
+	// the type saved, which AUTO picks from the pixels
+	h.Defaults.Savetype = vips.AUTO
+	if w := serve("POST", "w=100", src, nil); w.Code != 200 || w.Header().Get("Content-Type") != "image/jpeg" {
+		t.Errorf("POST with Savetype AUTO = %d, Content-Type %q, want 200 image/jpeg", w.Code, w.Header().Get("Content-Type"))
+	}
+	h.Defaults.Savetype = vips.UNKNOWN
+
+	// refused before the source is fetched
+	before := atomic.LoadInt32(&fetches)
+	query := "w=100&url=" + url.QueryEscape(origin.URL)
+	if w := serve("GET", query, nil, http.Header{"X-Api-Key": {"small"}}); w.Code != 403 || atomic.LoadInt32(&fetches) != before {
+		t.Errorf("GET ?%s over policy = %d after %d fetches, want 403 after none", query, w.Code, atomic.LoadInt32(&fetches)-before)
+	}
+}
@@ -0,0 +1,139 @@
+package vipshttp
+
+import (
+	"fmt"
+
+	"github.com/daddye/vips"
+)
+
+// Policy limits the transforms a tenant may request. Zero fields are not
+// limited.
+type Policy struct {
+	MaxWidth  int
+	MaxHeight int
+	// AllowedFormats lists the output formats a tenant may ask for.
+	AllowedFormats []vips.ImageType
+	// MaxOperations bounds the number of steps in one transform, counted
+	// by Operations.
+	MaxOperations int
+	// MaxQuality caps the encoder quality; requests above it are lowered
+	// rather than refused. Automatic quality, which searches up to
+	// vips.AUTO_QUALITY_MAX, is refused.
+	MaxQuality int
+}
+
+// PolicyError is returned by Enforce for requests outside a Policy.
+type PolicyError struct {
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return "vipshttp: request exceeds policy: " + e.Reason
+}
+
+// Policies picks the Policy of a tenant by API key.
+type Policies struct {
+	// Default applies to keys without a policy of their own.
+	Default Policy
+	Tenants map[string]Policy
+}
+
+// For returns the policy of the tenant with apiKey.
+func (p Policies) For(apiKey string) Policy {
+	if policy, ok := p.Tenants[apiKey]; ok {
+		return policy
+	}
+	return p.Default
+}
+
+// Enforce checks o against p before anything is decoded, capping its
+// quality in place.
+func (p Policy) Enforce(o *vips.Options) error {
+	if err := p.enforceLimits(o); err != nil {
+		return err
+	}
+	return p.enforceFormat(o)
+}
+
+// enforceLimits is Enforce but for the output format, which may only be
+// known once the source is.
+func (p Policy) enforceLimits(o *vips.Options) error {
+	if p.MaxWidth > 0 && o.Width > p.MaxWidth {
+		return &PolicyError{fmt.Sprintf("width %d is over %d", o.Width, p.MaxWidth)}
+	}
+	if p.MaxHeight > 0 && o.Height > p.MaxHeight {
+		return &PolicyError{fmt.Sprintf("height %d is over %d", o.Height, p.MaxHeight)}
+	}
+	// an unbounded side can grow past the limit when enlarging
+	if o.Enlarge && (p.MaxWidth > 0 && o.Width == 0 || p.MaxHeight > 0 && o.Height == 0) {
+		return &PolicyError{"enlarging needs both width and height"}
+	}
+
+	if n := Operations(*o); p.MaxOperations > 0 && n > p.MaxOperations {
+		return &PolicyError{fmt.Sprintf("%d operations is over %d", n, p.MaxOperations)}
+	}
+
+	if p.MaxQuality > 0 {
+		if o.Quality == vips.QUALITY_AUTO {
+			return &PolicyError{fmt.Sprintf("automatic quality may go over %d", p.MaxQuality)}
+		}
+		for _, q := range []*int{&o.Quality, &o.JPEG.Quality, &o.WebP.Quality} {
+			if *q > p.MaxQuality {
+				*q = p.MaxQuality
+			}
+		}
+		if o.Quality == 0 {
+			o.Quality = p.MaxQuality
+		}
+	}
+
+	return nil
+}
+
+// enforceFormat checks the output format of o against p, every format
+// AUTO may pick for Savetype AUTO.
+func (p Policy) enforceFormat(o *vips.Options) error {
+	if len(p.AllowedFormats) == 0 {
+		return nil
+	}
+	formats := []vips.ImageType{savetype(o)}
+	if o.Savetype == vips.AUTO {
+		formats = o.AutoFormats
+		if len(formats) == 0 {
+			formats = autoFormats
+		}
+	}
+	for _, t := range formats {
+		if !p.allows(t) {
+			return &PolicyError{fmt.Sprintf("format %s is not allowed", t)}
+		}
+	}
+	return nil
+}
+
+// allows reports whether t is among the AllowedFormats of p.
+func (p Policy) allows(t vips.ImageType) bool {
+	for _, allowed := range p.AllowedFormats {
+		if t == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// autoFormats are the formats vips.AUTO picks from without AutoFormats.
+var autoFormats = []vips.ImageType{vips.JPEG, vips.PNG}
+
+// savetype is the format vips.Resize will encode o as.
+func savetype(o *vips.Options) vips.ImageType {
+	if o.Savetype == vips.UNKNOWN {
+		return vips.JPEG
+	}
+	return o.Savetype
+}
+
+// Operations counts the pipeline steps o asks for, as vips.Explain lists
+// them, load and save included.
+func Operations(o vips.Options) int {
+	return len(vips.Explain(o))
+}
@@ -0,0 +1,89 @@
+package vipshttp
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/daddye/vips"
+)
+
+func TestPolicyEnforce(t *testing.T) {
+	policy := Policy{
+		MaxWidth:       1000,
+		MaxHeight:      1000,
+		AllowedFormats: []vips.ImageType{vips.JPEG, vips.WEBP},
+		MaxOperations:  6,
+		MaxQuality:     85,
+	}
+
+	var testCases = []struct {
+		options vips.Options
+		ok      bool
+	}{
+		{vips.Options{Width: 800, Height: 600}, true},
+		{vips.Options{Width: 1200}, false},
+		{vips.Options{Height: 1200}, false},
+		{vips.Options{Width: 800, Enlarge: true}, false},
+		{vips.Options{Width: 800, Savetype: vips.PNG}, false},
+		{vips.Options{Width: 800, Savetype: vips.WEBP}, true},
+		{vips.Options{Width: 800, Crop: true}, true},
+		{vips.Options{Width: 800, Crop: true, Flip: true}, false},
+		{vips.Options{Width: 800, Invert: true, Median: 3}, false},
+		{vips.Options{Width: 800, Savetype: vips.AUTO, AutoFormats: []vips.ImageType{vips.JPEG, vips.WEBP}}, true},
+		{vips.Options{Width: 800, Savetype: vips.AUTO, AutoFormats: []vips.ImageType{vips.WEBP, vips.PNG}}, false},
+		{vips.Options{Width: 800, Savetype: vips.AUTO}, false},
+		{vips.Options{Width: 800, Quality: vips.QUALITY_AUTO}, false},
+	}
+
+	for index, tc := range testCases {
+		o := tc.options
+		if err := policy.Enforce(&o); (err == nil) != tc.ok {
+			t.Errorf("%d. Enforce(%+v) => %v", index, tc.options, err)
+		}
+	}
+
+	q, _ := url.ParseQuery("w=100&quality=auto")
+	o, _, err := ParseOptions(q, vips.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var policyErr *PolicyError
+	if err := policy.Enforce(&o); !errors.As(err, &policyErr) {
+		t.Errorf("Enforce(quality=auto) => %v, want a PolicyError under MaxQuality", err)
+	}
+
+	o = vips.Options{Width: 100, Quality: 95, WebP: vips.WebPOptions{Quality: 90}}
+	policy.Enforce(&o)
+	if o.Quality != 85 || o.WebP.Quality != 85 {
+		t.Errorf("Enforce() quality => %d and WebP %d, want 85", o.Quality, o.WebP.Quality)
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	p := Policies{
+		Default: Policy{MaxWidth: 100},
+		Tenants: map[string]Policy{"pro": {MaxWidth: 4000}},
+	}
+	if p.For("pro").MaxWidth != 4000 || p.For("free").MaxWidth != 100 {
+		t.Errorf("Policies.For() did not pick the tenant policy")
+	}
+}
+
+func TestOperations(t *testing.T) {
+	var testCases = []struct {
+		options vips.Options
+		n       int
+	}{
+		// load, extract_page, resize, colourspace and save
+		{vips.Options{}, 5},
+		{vips.Options{Width: 100, Crop: true}, 6},
+		{vips.Options{RotateDegrees: 45, Invert: true, Grain: 2}, 8},
+	}
+
+	for index, tc := range testCases {
+		if n := Operations(tc.options); n != tc.n {
+			t.Errorf("%d. Operations(%+v) => %d, want %d", index, tc.options, n, tc.n)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package vipshttp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/daddye/vips"
+)
+
+var (
+	ErrBadSignature = errors.New("vipshttp: missing or bad signature")
+	ErrExpired      = errors.New("vipshttp: signature expired")
+)
+
+// Sign adds a sig parameter to q, an HMAC-SHA256 with key of every other
+// parameter, source url included, so ParseSigned only accepts the
+// transforms the application generated. Setting exp to a Unix time first
+// makes the signature expire then.
+func Sign(key []byte, q url.Values) url.Values {
+	signed := make(url.Values, len(q)+1)
+	for k, v := range q {
+		if k != "sig" {
+			signed[k] = v
+		}
+	}
+	signed.Set("sig", signature(key, signed))
+	return signed
+}
+
+// ParseSigned verifies the signature Sign put in q, and its expiry, before
+// applying the parameters to o like ParseOptions.
+func ParseSigned(key []byte, q url.Values, o vips.Options) (_ vips.Options, auto bool, err error) {
+	sig, err := base64.RawURLEncoding.DecodeString(q.Get("sig"))
+	if err != nil || len(sig) == 0 {
+		return o, false, ErrBadSignature
+	}
+	want, _ := base64.RawURLEncoding.DecodeString(signature(key, q))
+	if !hmac.Equal(sig, want) {
+		return o, false, ErrBadSignature
+	}
+
+	if v := q.Get("exp"); v != "" {
+		exp, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return o, false, ErrBadSignature
+		}
+		if time.Now().Unix() > exp {
+			return o, false, ErrExpired
+		}
+	}
+
+	return ParseOptions(q, o)
+}
+
+// signature is the base64 HMAC of the parameters of q but sig, in the
+// sorted order url.Values.Encode writes them.
+func signature(key []byte, q url.Values) string {
+	unsigned := make(url.Values, len(q))
+	for k, v := range q {
+		if k != "sig" {
+			unsigned[k] = v
+		}
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(unsigned.Encode()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,58 @@
+package vipshttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/daddye/vips"
+)
+
+func TestParseSigned(t *testing.T) {
+	key := []byte("secret")
+	q, _ := url.ParseQuery("url=https://images.example.com/a.jpg&w=200&format=webp")
+	signed := Sign(key, q)
+
+	o, _, err := ParseSigned(key, signed, vips.Options{})
+	if err != nil || o.Width != 200 || o.Savetype != vips.WEBP {
+		t.Errorf("ParseSigned() = %+v, %v", o, err)
+	}
+	if q.Get("sig") != "" {
+		t.Errorf("Sign() changed its argument")
+	}
+
+	tampered, _ := url.ParseQuery(signed.Encode())
+	tampered.Set("w", "5000")
+	if _, _, err := ParseSigned(key, tampered, vips.Options{}); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("ParseSigned() of a changed width = %v, want %v", err, ErrBadSignature)
+	}
+	if _, _, err := ParseSigned([]byte("other"), signed, vips.Options{}); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("ParseSigned() with another key = %v, want %v", err, ErrBadSignature)
+	}
+	if _, _, err := ParseSigned(key, q, vips.Options{}); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("ParseSigned() unsigned = %v, want %v", err, ErrBadSignature)
+	}
+
+	q.Set("exp", strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10))
+	if _, _, err := ParseSigned(key, Sign(key, q), vips.Options{}); !errors.Is(err, ErrExpired) {
+		t.Errorf("ParseSigned() expired = %v, want %v", err, ErrExpired)
+	}
+	q.Set("exp", strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+	if _, _, err := ParseSigned(key, Sign(key, q), vips.Options{}); err != nil {
+		t.Errorf("ParseSigned() before expiry = %v", err)
+	}
+}
+
+func TestHandlerSigned(t *testing.T) {
+	h := &Handler{SigningKey: []byte("secret")}
+	r := httptest.NewRequest("GET", "/?url=https://images.example.com/a.jpg&w=5000", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("unsigned request => %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,159 @@
+package vipstest
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+)
+
+// Pattern is the content of a generated image.
+type Pattern int
+
+const (
+	// GRADIENT blends four corner colours, smooth content that resizes
+	// predictably.
+	GRADIENT Pattern = iota
+	// NOISE sets every pixel at random, the worst case for encoders.
+	NOISE
+	// TEXT lays dark glyph-sized blocks out in lines on white, sharp edges
+	// like a scanned page.
+	TEXT
+	// ALPHA is a gradient with alpha ramping left to right and fully
+	// transparent checkerboard squares.
+	ALPHA
+)
+
+// Format is the encoding of a generated image.
+type Format int
+
+const (
+	PNG Format = iota
+	JPEG
+)
+
+// Spec describes a generated image. Equal specs always give the same
+// pixels.
+type Spec struct {
+	Width, Height int
+	Pattern       Pattern
+	// Seed picks the colours, noise and layout.
+	Seed   int64
+	Format Format
+}
+
+// Generate renders s as an image.
+func Generate(s Spec) image.Image {
+	rnd := rand.New(rand.NewSource(s.Seed))
+	img := image.NewNRGBA(image.Rect(0, 0, s.Width, s.Height))
+
+	switch s.Pattern {
+	case NOISE:
+		rnd.Read(img.Pix)
+		for i := 3; i < len(img.Pix); i += 4 {
+			img.Pix[i] = 0xff
+		}
+	case TEXT:
+		drawText(img, rnd)
+	default:
+		drawGradient(img, rnd)
+		if s.Pattern == ALPHA {
+			drawAlpha(img, 4+rnd.Intn(28))
+		}
+	}
+
+	return img
+}
+
+// Encode renders s and encodes it in s.Format.
+func Encode(s Spec) []byte {
+	var buf bytes.Buffer
+	img := Generate(s)
+	if s.Format == JPEG {
+		jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	} else {
+		png.Encode(&buf, img)
+	}
+	return buf.Bytes()
+}
+
+func randomColor(rnd *rand.Rand) [3]float64 {
+	return [3]float64{float64(rnd.Intn(256)), float64(rnd.Intn(256)), float64(rnd.Intn(256))}
+}
+
+func drawGradient(img *image.NRGBA, rnd *rand.Rand) {
+	tl, tr, bl, br := randomColor(rnd), randomColor(rnd), randomColor(rnd), randomColor(rnd)
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+
+	for y := 0; y < h; y++ {
+		fy := float64(y) / float64(maxInt(h-1, 1))
+		for x := 0; x < w; x++ {
+			fx := float64(x) / float64(maxInt(w-1, 1))
+			var c [3]uint8
+			for i := range c {
+				top := tl[i] + (tr[i]-tl[i])*fx
+				bottom := bl[i] + (br[i]-bl[i])*fx
+				c[i] = uint8(top + (bottom-top)*fy + 0.5)
+			}
+			img.SetNRGBA(x, y, color.NRGBA{c[0], c[1], c[2], 0xff})
+		}
+	}
+}
+
+func drawAlpha(img *image.NRGBA, square int) {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := img.PixOffset(x, y) + 3
+			if (x/square+y/square)%2 == 1 {
+				img.Pix[i] = 0
+			} else {
+				img.Pix[i] = uint8(255 * x / maxInt(w-1, 1))
+			}
+		}
+	}
+}
+
+func drawText(img *image.NRGBA, rnd *rand.Rand) {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+
+	size := maxInt(h/40, 4)
+	ink := color.NRGBA{uint8(rnd.Intn(64)), uint8(rnd.Intn(64)), uint8(rnd.Intn(64)), 0xff}
+	for top := size; top+size <= h-size; top += size * 2 {
+		for left := size; left+size/2 <= w-size; {
+			glyph := size/2 + rnd.Intn(size/2+1)
+			if rnd.Intn(6) > 0 {
+				// a glyph: a block with a random stroke punched out
+				hole := rnd.Intn(glyph)
+				for y := top; y < top+size; y++ {
+					for x := left; x < minInt(left+glyph, w-size); x++ {
+						if x-left != hole {
+							img.SetNRGBA(x, y, ink)
+						}
+					}
+				}
+			}
+			left += glyph + size/4 + 1
+		}
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
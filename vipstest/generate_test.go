@@ -0,0 +1,44 @@
+package vipstest
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	for _, p := range []Pattern{GRADIENT, NOISE, TEXT, ALPHA} {
+		for _, f := range []Format{PNG, JPEG} {
+			s := Spec{Width: 123, Height: 45, Pattern: p, Seed: 7, Format: f}
+			buf := Encode(s)
+			if !bytes.Equal(buf, Encode(s)) {
+				t.Errorf("Encode(%+v) is not deterministic", s)
+			}
+
+			img, _, err := image.Decode(bytes.NewReader(buf))
+			if err != nil {
+				t.Fatalf("Encode(%+v) did not decode: %v", s, err)
+			}
+			if b := img.Bounds(); b.Dx() != 123 || b.Dy() != 45 {
+				t.Errorf("Encode(%+v) is %dx%d", s, b.Dx(), b.Dy())
+			}
+		}
+	}
+
+	a := Generate(Spec{Width: 16, Height: 16, Pattern: NOISE, Seed: 1})
+	b := Generate(Spec{Width: 16, Height: 16, Pattern: NOISE, Seed: 2})
+	if d, _ := CompareImages(a, b); d.MeanError == 0 {
+		t.Errorf("different seeds gave the same image")
+	}
+
+	alpha := Generate(Spec{Width: 64, Height: 64, Pattern: ALPHA}).(*image.NRGBA)
+	transparent := 0
+	for i := 3; i < len(alpha.Pix); i += 4 {
+		if alpha.Pix[i] == 0 {
+			transparent++
+		}
+	}
+	if transparent == 0 {
+		t.Errorf("ALPHA pattern has no transparent pixels")
+	}
+}
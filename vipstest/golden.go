@@ -0,0 +1,133 @@
+// Package vipstest compares images produced with the vips package against
+// golden files. Encoders differ slightly between libvips, libjpeg and
+// libpng versions, so images are compared decoded, pixel by pixel, within
+// a perceptual tolerance rather than byte for byte. Generate and Encode
+// make deterministic synthetic inputs, so tests need no binary fixtures.
+package vipstest
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+)
+
+// UpdateEnv names the environment variable that makes Golden write the
+// images it is given as the new golden files instead of comparing them.
+const UpdateEnv = "VIPSTEST_UPDATE"
+
+// Tolerance bounds how far an image may drift from its golden file.
+type Tolerance struct {
+	// MinPSNR is the lowest peak signal-to-noise ratio accepted, in dB.
+	MinPSNR float64
+	// MaxMeanError is the largest mean absolute difference accepted per
+	// channel, on the 0-255 scale.
+	MaxMeanError float64
+}
+
+// DefaultTolerance accepts the re-encoding noise of lossy formats while
+// catching crops, shifts and colour changes.
+var DefaultTolerance = Tolerance{MinPSNR: 35, MaxMeanError: 2}
+
+// Diff measures the difference between two images of the same size.
+type Diff struct {
+	// PSNR is the peak signal-to-noise ratio in dB, +Inf for identical
+	// images.
+	PSNR float64
+	// MeanError is the mean absolute difference per channel, 0-255.
+	MeanError float64
+}
+
+// Within reports whether d is inside t.
+func (d Diff) Within(t Tolerance) bool {
+	return d.PSNR >= t.MinPSNR && d.MeanError <= t.MaxMeanError
+}
+
+// Compare decodes two JPEG or PNG images and measures their difference
+// over the red, green, blue and alpha channels. Images of different sizes
+// are an error.
+func Compare(got, want []byte) (Diff, error) {
+	a, _, err := image.Decode(bytes.NewReader(got))
+	if err != nil {
+		return Diff{}, fmt.Errorf("vipstest: decoding image: %v", err)
+	}
+	b, _, err := image.Decode(bytes.NewReader(want))
+	if err != nil {
+		return Diff{}, fmt.Errorf("vipstest: decoding golden image: %v", err)
+	}
+	return CompareImages(a, b)
+}
+
+// CompareImages is Compare for decoded images.
+func CompareImages(a, b image.Image) (Diff, error) {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Dx() != bb.Dx() || ab.Dy() != bb.Dy() {
+		return Diff{}, fmt.Errorf("vipstest: size %dx%d, want %dx%d", ab.Dx(), ab.Dy(), bb.Dx(), bb.Dy())
+	}
+
+	var sum, sumSq float64
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			r1, g1, b1, a1 := a.At(ab.Min.X+x, ab.Min.Y+y).RGBA()
+			r2, g2, b2, a2 := b.At(bb.Min.X+x, bb.Min.Y+y).RGBA()
+			for _, d := range [4]float64{
+				channelDiff(r1, r2), channelDiff(g1, g2), channelDiff(b1, b2), channelDiff(a1, a2),
+			} {
+				sum += d
+				sumSq += d * d
+			}
+		}
+	}
+
+	n := float64(ab.Dx() * ab.Dy() * 4)
+	if n == 0 || sumSq == 0 {
+		return Diff{PSNR: math.Inf(1)}, nil
+	}
+	return Diff{
+		PSNR:      10 * math.Log10(255*255/(sumSq/n)),
+		MeanError: sum / n,
+	}, nil
+}
+
+// channelDiff is the absolute difference of two 16 bit channel values on
+// the 0-255 scale.
+func channelDiff(a, b uint32) float64 {
+	return math.Abs(float64(a)-float64(b)) / 257
+}
+
+// Golden fails t unless got is within tol of the golden image at path.
+// When the UpdateEnv variable is set, got is written to path instead. On a
+// mismatch got is saved next to the golden file with a ".got" suffix for
+// inspection.
+func Golden(t testing.TB, path string, got []byte, tol Tolerance) {
+	t.Helper()
+
+	if os.Getenv(UpdateEnv) != "" {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (set %s=1 to create it)", err, UpdateEnv)
+	}
+
+	d, err := Compare(got, want)
+	if err == nil && d.Within(tol) {
+		return
+	}
+
+	ioutil.WriteFile(path+".got", got, 0644)
+	if err != nil {
+		t.Errorf("%s: %v", path, err)
+	} else {
+		t.Errorf("%s: PSNR %.1fdB, mean error %.2f, want at least %.1fdB and at most %.2f", path, d.PSNR, d.MeanError, tol.MinPSNR, tol.MaxMeanError)
+	}
+}
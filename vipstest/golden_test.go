@@ -0,0 +1,79 @@
+package vipstest
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func pngOf(t *testing.T, w, h int, c func(x, y int) color.RGBA) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c(x, y))
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCompare(t *testing.T) {
+	gradient := pngOf(t, 32, 32, func(x, y int) color.RGBA {
+		return color.RGBA{uint8(x * 8), uint8(y * 8), 128, 255}
+	})
+	noisy := pngOf(t, 32, 32, func(x, y int) color.RGBA {
+		return color.RGBA{uint8(x*8 + (x+y)%2), uint8(y * 8), 128, 255}
+	})
+	shifted := pngOf(t, 32, 32, func(x, y int) color.RGBA {
+		return color.RGBA{uint8(x*8 + 64), uint8(y * 8), 128, 255}
+	})
+
+	d, err := Compare(gradient, gradient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsInf(d.PSNR, 1) || d.MeanError != 0 {
+		t.Errorf("Compare() of identical images = %+v", d)
+	}
+
+	if d, _ := Compare(noisy, gradient); !d.Within(DefaultTolerance) {
+		t.Errorf("Compare() of noisy image = %+v, want within tolerance", d)
+	}
+	if d, _ := Compare(shifted, gradient); d.Within(DefaultTolerance) {
+		t.Errorf("Compare() of shifted colours = %+v, want outside tolerance", d)
+	}
+
+	small := pngOf(t, 16, 32, func(x, y int) color.RGBA { return color.RGBA{} })
+	if _, err := Compare(small, gradient); err == nil {
+		t.Errorf("Compare() of different sizes did not fail")
+	}
+	if _, err := Compare([]byte("garbage"), gradient); err == nil {
+		t.Errorf("Compare() of garbage did not fail")
+	}
+}
+
+func TestGoldenUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vipstest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "gradient.png")
+	img := pngOf(t, 8, 8, func(x, y int) color.RGBA { return color.RGBA{uint8(x), uint8(y), 0, 255} })
+
+	os.Setenv(UpdateEnv, "1")
+	Golden(t, path, img, DefaultTolerance)
+	os.Unsetenv(UpdateEnv)
+
+	Golden(t, path, img, DefaultTolerance)
+}
@@ -0,0 +1,54 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+// Warning is a machine-readable notice that the output lost something the
+// source had, for APIs to pass on to their users.
+type Warning string
+
+const (
+	// WARNING_ALPHA_DROPPED: transparency was dropped by a format without
+	// alpha, such as JPEG.
+	WARNING_ALPHA_DROPPED Warning = "alpha_dropped"
+	// WARNING_DEPTH_REDUCED: a 16 bit or float source was saved with 8
+	// bits per sample.
+	WARNING_DEPTH_REDUCED Warning = "depth_reduced"
+	// WARNING_CMYK_CONVERTED: a CMYK source was converted to RGB.
+	WARNING_CMYK_CONVERTED Warning = "cmyk_converted"
+)
+
+// source is what conversions are checked against, and how it was decoded.
+type source struct {
+	format         C.VipsBandFormat
+	interpretation C.VipsInterpretation
+	decoder        Decoder
+	width, height  int
+}
+
+func sourceOf(image *C.struct__VipsImage) source {
+	return source{image.BandFmt, image.Type, DECODER_NATIVE, int(image.Xsize), int(image.Ysize)}
+}
+
+// conversionWarnings lists what saving image, made from src, as f loses.
+func conversionWarnings(src source, image *C.struct__VipsImage, f *Format, o Options) []Warning {
+	var warnings []Warning
+
+	if !f.Alpha && C.vips_image_hasalpha(image) != 0 {
+		warnings = append(warnings, WARNING_ALPHA_DROPPED)
+	}
+
+	deep := src.format != C.VIPS_FORMAT_UCHAR && src.format != C.VIPS_FORMAT_CHAR
+	shallow := image.BandFmt == C.VIPS_FORMAT_UCHAR || f.Type == JPEG || f.Type == WEBP
+	if deep && shallow {
+		warnings = append(warnings, WARNING_DEPTH_REDUCED)
+	}
+
+	if src.interpretation == C.VIPS_INTERPRETATION_CMYK && o.Colourspace != COLOURSPACE_CMYK {
+		warnings = append(warnings, WARNING_CMYK_CONVERTED)
+	}
+
+	return warnings
+}
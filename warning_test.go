@@ -0,0 +1,44 @@
+package vips
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+func TestConversionWarnings(t *testing.T) {
+	encode := func(img image.Image) []byte {
+		buf := new(bytes.Buffer)
+		if err := png.Encode(buf, img); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	rgba := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	rgba.Set(0, 0, color.NRGBA{255, 0, 0, 128})
+	deep := image.NewRGBA64(image.Rect(0, 0, 8, 8))
+	deep.Set(0, 0, color.RGBA64{1, 2, 3, 0xffff})
+
+	cases := []struct {
+		buf  []byte
+		o    Options
+		want []Warning
+	}{
+		{encode(rgba), Options{Savetype: PNG}, nil},
+		{encode(rgba), Options{Savetype: JPEG}, []Warning{WARNING_ALPHA_DROPPED}},
+		{encode(rgba), Options{Savetype: JPEG, Flatten: true}, nil},
+		{encode(deep), Options{Savetype: JPEG}, []Warning{WARNING_ALPHA_DROPPED, WARNING_DEPTH_REDUCED}},
+	}
+	for _, c := range cases {
+		r, err := ResizeWithInfo(c.buf, c.o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(r.Warnings, c.want) {
+			t.Errorf("ResizeWithInfo(%v) warnings = %v, want %v", c.o.Savetype, r.Warnings, c.want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package vips
+
+/*
+#include <vips/vips.h>
+*/
+import "C"
+
+import (
+	"hash/fnv"
+	"math/rand"
+)
+
+// Watermark invisibly marks an image with a number, such as the tenant or
+// request it was served to, so leaked copies can be traced with
+// ReadWatermark. The number is spread over the whole image as faint
+// brightness changes across a 64x64 grid, and survives re-encoding down to
+// low JPEG quality and moderate resizing, but not cropping. Images smaller
+// than the grid can't be marked.
+type Watermark struct {
+	// Payload is the number embedded.
+	Payload uint32
+	// Key seeds the patterns the payload is spread with; only readers
+	// with the same key find it.
+	Key string
+	// Strength is how far each bit is pushed past what the image itself
+	// suggests, WATERMARK_STRENGTH when zero. Stronger marks survive
+	// harsher compression but start to show in flat areas.
+	Strength float64
+}
+
+const (
+	// WATERMARK_GRID is the number of chips across and down a watermark.
+	WATERMARK_GRID = 64
+	// WATERMARK_STRENGTH is the default Watermark.Strength, changing the
+	// average chip by about three levels out of 255.
+	WATERMARK_STRENGTH = 1500
+
+	// watermarkBits are the payload followed by 16 check bits.
+	watermarkBits = 48
+	// watermarkRounds refine the amplitudes, as the patterns of the bits
+	// interfere with each other.
+	watermarkRounds = 4
+)
+
+// ReadWatermark looks for a Watermark embedded with key in buf, reporting
+// whether one was found. Images marked with another key, or not at all,
+// are not found, but in about one in 65536 read as a random payload.
+func ReadWatermark(buf []byte, key string) (payload uint32, ok bool, err error) {
+	release, err := acquire()
+	if err != nil {
+		return 0, false, err
+	}
+	defer release()
+
+	// cleanup
+	defer func() {
+		C.vips_thread_shutdown()
+		C.vips_error_clear()
+	}()
+
+	image, _, err := resizeImage(buf, Options{})
+	if err != nil {
+		return 0, false, err
+	}
+	defer C.g_object_unref(C.gpointer(image))
+
+	means, err := vipsWatermarkMeans(image)
+	if err != nil {
+		return 0, false, err
+	}
+	payload, ok = readWatermark(means, key)
+	return payload, ok, nil
+}
+
+// embedWatermark marks image with w and releases it.
+func embedWatermark(image *C.struct__VipsImage, w Watermark) (*C.struct__VipsImage, error) {
+	debug("watermark %08x", w.Payload)
+
+	// the means and the mark both read every pixel, which a sequential
+	// source only allows once
+	image, err := decodeToMemory(image)
+	if err != nil {
+		return nil, err
+	}
+
+	means, err := vipsWatermarkMeans(image)
+	if err != nil {
+		C.g_object_unref(C.gpointer(image))
+		return nil, err
+	}
+
+	strength := w.Strength
+	if strength == 0 {
+		strength = WATERMARK_STRENGTH
+	}
+	return vipsWatermarkAdd(image, watermarkPattern(means, w.Payload, w.Key, strength))
+}
+
+// watermarkWord is payload followed by 16 check bits hashed from it.
+func watermarkWord(payload uint32) uint64 {
+	h := fnv.New32a()
+	h.Write([]byte{byte(payload >> 24), byte(payload >> 16), byte(payload >> 8), byte(payload)})
+	return uint64(payload)<<16 | uint64(h.Sum32()&0xffff)
+}
+
+// watermarkBit is bit i of word, counting from the top, as -1 or 1.
+func watermarkBit(word uint64, i int) float64 {
+	if word>>(watermarkBits-1-uint(i))&1 == 1 {
+		return 1
+	}
+	return -1
+}
+
+// watermarkPatterns are the pseudo-random ±1 chip patterns key spreads
+// each bit with.
+func watermarkPatterns(key string) [][]float64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	r := rand.New(rand.NewSource(int64(h.Sum64())))
+
+	patterns := make([][]float64, watermarkBits)
+	for i := range patterns {
+		p := make([]float64, WATERMARK_GRID*WATERMARK_GRID)
+		for c := range p {
+			p[c] = float64(2*r.Intn(2) - 1)
+		}
+		patterns[i] = p
+	}
+	return patterns
+}
+
+// highPass subtracts from every chip the mean of its neighbours, leaving
+// the detail the patterns are read from rather than the picture.
+func highPass(means []float64) []float64 {
+	n := WATERMARK_GRID
+	out := make([]float64, len(means))
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			sum, count := 0.0, 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if (dx != 0 || dy != 0) && x+dx >= 0 && x+dx < n && y+dy >= 0 && y+dy < n {
+						sum += means[(y+dy)*n+x+dx]
+						count++
+					}
+				}
+			}
+			out[y*n+x] = means[y*n+x] - sum/float64(count)
+		}
+	}
+	return out
+}
+
+// correlations of the high passed means with each pattern.
+func correlations(means []float64, patterns [][]float64) []float64 {
+	hp := highPass(means)
+	c := make([]float64, len(patterns))
+	for i, p := range patterns {
+		for k := range hp {
+			c[i] += hp[k] * p[k]
+		}
+	}
+	return c
+}
+
+// readWatermark decodes the payload from the chip means of an image,
+// reporting whether the check bits match.
+func readWatermark(means []float64, key string) (uint32, bool) {
+	var word uint64
+	for _, c := range correlations(means, watermarkPatterns(key)) {
+		word <<= 1
+		if c > 0 {
+			word |= 1
+		}
+	}
+	payload := uint32(word >> 16)
+	return payload, watermarkWord(payload) == word
+}
+
+// watermarkPattern is what to add to each chip of an image with means so
+// every bit of payload correlates with its pattern by at least strength.
+// Rather than adding the same amount for each bit, it only adds what the
+// image lacks, so bits the picture already agrees with cost nothing and
+// those it fights get what they need.
+func watermarkPattern(means []float64, payload uint32, key string, strength float64) []float64 {
+	word := watermarkWord(payload)
+	patterns := watermarkPatterns(key)
+
+	amps := make([]float64, len(patterns))
+	pattern := make([]float64, len(means))
+	marked := make([]float64, len(means))
+	for round := 0; round < watermarkRounds; round++ {
+		for k := range marked {
+			marked[k] = means[k] + pattern[k]
+		}
+		for i, c := range correlations(marked, patterns) {
+			if sign := watermarkBit(word, i); sign*c < strength {
+				amps[i] += (sign*strength - c) / float64(len(means))
+			}
+		}
+
+		for k := range pattern {
+			pattern[k] = 0
+		}
+		for i, p := range patterns {
+			for k := range pattern {
+				pattern[k] += amps[i] * p[k]
+			}
+		}
+	}
+	return pattern
+}
@@ -0,0 +1,66 @@
+package vips
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+func TestWatermarkPattern(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	means := make([]float64, WATERMARK_GRID*WATERMARK_GRID)
+	for i := range means {
+		means[i] = 64 + 128*r.Float64()
+	}
+
+	if _, ok := readWatermark(means, "tenant"); ok {
+		t.Errorf("readWatermark() found a mark in noise")
+	}
+
+	pattern := watermarkPattern(means, 0xdeadbeef, "tenant", WATERMARK_STRENGTH)
+	for i := range means {
+		means[i] += pattern[i]
+	}
+	if payload, ok := readWatermark(means, "tenant"); !ok || payload != 0xdeadbeef {
+		t.Errorf("readWatermark() = %08x, %v, want deadbeef", payload, ok)
+	}
+	if _, ok := readWatermark(means, "other"); ok {
+		t.Errorf("readWatermark() found the mark with another key")
+	}
+}
+
+func TestWatermark(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/1.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Watermark{Payload: 42, Key: "tenant"}
+	out, err := Resize(buf, Options{Width: 800, Quality: 75, Watermark: w})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload, ok, err := ReadWatermark(out, "tenant"); err != nil || !ok || payload != 42 {
+		t.Errorf("ReadWatermark() = %d, %v, %v, want 42", payload, ok, err)
+	}
+
+	// scaled down and compressed again
+	small, err := Resize(out, Options{Width: 400, Quality: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload, ok, err := ReadWatermark(small, "tenant"); err != nil || !ok || payload != 42 {
+		t.Errorf("ReadWatermark() of a copy = %d, %v, %v, want 42", payload, ok, err)
+	}
+
+	if _, ok, err := ReadWatermark(buf, "tenant"); err != nil || ok {
+		t.Errorf("ReadWatermark() of the source = %v, %v, want not found", ok, err)
+	}
+	if _, err := Resize(buf, Options{Width: 32, Watermark: w}); err == nil {
+		t.Errorf("Resize() marked an image smaller than the grid")
+	}
+	if err := (Options{Watermark: &Watermark{Strength: -1}}).Validate(); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Validate() of a negative strength = %v", err)
+	}
+}